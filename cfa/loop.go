@@ -0,0 +1,179 @@
+package cfa
+
+import (
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/gonum/graph"
+)
+
+// loop represents a natural loop of a control flow graph.
+type loop struct {
+	// Loop header, which dominates every node of the loop.
+	header graph.Node
+	// Loop latches; i.e. the sources of back edges to the header.
+	latches []graph.Node
+	// Nodes of the loop, including the header; mapping from node ID to node.
+	nodes map[int]graph.Node
+}
+
+// CutLoopEdges removes the break and continue edges of the natural loops in g,
+// and reports whether any edge was removed.
+//
+// A break edge branches from a conditional node of a loop to the loop exit,
+// and a continue edge branches from a conditional node of a loop back to the
+// loop header, while the loop is exited or iterated through another node. Such
+// edges prevent the loop body from being reduced into a single node. With the
+// edges removed, the loop may be recovered as a pre-test or post-test loop,
+// and the branches of the removed edges are expected to be emitted as break
+// and continue statements.
+//
+// Only edges of nodes whose innermost loop is the loop of the edge target are
+// removed, as break and continue statements apply to the innermost loop.
+//
+// The break edges of break nodes are removed as well. A break node is outside
+// of the loop, as it does not reach the header, but is only entered from within
+// the loop and branches unconditionally to the loop exit; e.g. the node of C
+// in
+//
+//    while (A) {
+//       if (B) {
+//          C
+//          break
+//       }
+//       D
+//    }
+//
+// With the break edge removed, the break node has no successors, and may thus
+// be recovered as the body of a 1-way conditional with a body return
+// statement, ending with the break statement.
+func CutLoopEdges(g *cfg.Graph, dom cfg.Dom) bool {
+	loops := findLoops(g, dom)
+	cut := false
+	for _, l := range loops {
+		// Locate the exit of the loop, as the single successor outside of the
+		// loop of the header (pre-test) or the latch (post-test).
+		exiting := l.header
+		exit, ok := loopExit(g, l, exiting)
+		if !ok && len(l.latches) == 1 {
+			exiting = l.latches[0]
+			exit, ok = loopExit(g, l, exiting)
+		}
+		if !ok {
+			continue
+		}
+
+		// Remove break edges.
+		for _, n := range l.nodes {
+			if n == exiting || innermost(loops, n) != l || len(g.From(n)) != 2 {
+				continue
+			}
+			if e := g.Edge(n, exit); e != nil {
+				g.RemoveEdge(e)
+				cut = true
+			}
+		}
+		for _, n := range g.To(exit) {
+			if !isBreakNode(g, loops, l, n) {
+				continue
+			}
+			g.RemoveEdge(g.Edge(n, exit))
+			cut = true
+		}
+
+		// Remove continue edges, while keeping at least one back edge to the
+		// header.
+		var conts []graph.Node
+		for _, latch := range l.latches {
+			if latch == exiting || latch == l.header || innermost(loops, latch) != l || len(g.From(latch)) != 2 {
+				continue
+			}
+			conts = append(conts, latch)
+		}
+		if len(conts) == len(l.latches) {
+			conts = conts[1:]
+		}
+		for _, latch := range conts {
+			g.RemoveEdge(g.Edge(latch, l.header))
+			cut = true
+		}
+	}
+	return cut
+}
+
+// isBreakNode reports whether n is a break node of the given loop; i.e. a node
+// outside of the loop with a single successor, whose predecessors are in the
+// loop and have the loop as innermost loop.
+func isBreakNode(g graph.Directed, loops []*loop, l *loop, n graph.Node) bool {
+	if _, ok := l.nodes[n.ID()]; ok || len(g.From(n)) != 1 {
+		return false
+	}
+	preds := g.To(n)
+	if len(preds) == 0 {
+		return false
+	}
+	for _, pred := range preds {
+		if innermost(loops, pred) != l {
+			return false
+		}
+	}
+	return true
+}
+
+// findLoops returns the natural loops of g, one per loop header.
+func findLoops(g graph.Directed, dom cfg.Dom) []*loop {
+	var loops []*loop
+	for _, header := range g.Nodes() {
+		l := &loop{header: header, nodes: map[int]graph.Node{header.ID(): header}}
+		for _, pred := range g.To(header) {
+			if dom.Dominates(header, pred) {
+				l.latches = append(l.latches, pred)
+			}
+		}
+		if len(l.latches) == 0 {
+			continue
+		}
+		// The nodes of the loop are the nodes reaching a latch without passing
+		// through the header.
+		work := append([]graph.Node(nil), l.latches...)
+		for len(work) > 0 {
+			n := work[len(work)-1]
+			work = work[:len(work)-1]
+			if _, ok := l.nodes[n.ID()]; ok {
+				continue
+			}
+			l.nodes[n.ID()] = n
+			work = append(work, g.To(n)...)
+		}
+		loops = append(loops, l)
+	}
+	return loops
+}
+
+// loopExit returns the single successor outside of the given loop of the
+// exiting node, and a boolean indicating if such a successor was found.
+func loopExit(g graph.Directed, l *loop, exiting graph.Node) (graph.Node, bool) {
+	var exit graph.Node
+	for _, succ := range g.From(exiting) {
+		if _, ok := l.nodes[succ.ID()]; ok {
+			continue
+		}
+		if exit != nil {
+			return nil, false
+		}
+		exit = succ
+	}
+	return exit, exit != nil
+}
+
+// innermost returns the innermost loop containing n.
+func innermost(loops []*loop, n graph.Node) *loop {
+	var inner *loop
+	for _, l := range loops {
+		if _, ok := l.nodes[n.ID()]; !ok {
+			continue
+		}
+		if inner == nil || len(l.nodes) < len(inner.nodes) {
+			inner = l
+		}
+	}
+	return inner
+}
@@ -118,10 +118,10 @@ func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
 	}
 
 	// Get functions set by `-funcs` or all functions if `-funcs` not used.
-	var funcs []*ir.Function
+	var funcs []*ir.Func
 	for _, f := range module.Funcs {
-		if len(funcNames) > 0 && !funcNames[f.Name] {
-			dbg.Printf("skipping function %q.", f.Name)
+		if len(funcNames) > 0 && !funcNames[f.Name()] {
+			dbg.Printf("skipping function %q.", f.Name())
 			continue
 		}
 		funcs = append(funcs, f)
@@ -139,11 +139,14 @@ func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
 		}
 
 		// Generate control flow graph.
-		dbg.Printf("parsing function %q.", f.Name)
-		g := cfg.New(f)
+		dbg.Printf("parsing function %q.", f.Name())
+		g, err := cfg.New(f)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 
 		// Store DOT graph.
-		if err := storeCFG(g, f.Name, dotDir, img); err != nil {
+		if err := storeCFG(g, f.Name(), dotDir, img); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -0,0 +1,750 @@
+//go:generate mango -plain main.go
+
+// The ll2go tool decompiles LLVM IR assembly to Go source code (*.ll -> *.go).
+//
+// The input of ll2go is LLVM IR assembly and the output is unpolished Go source
+// code, which may be post-processed using go-post to make it more idiomatic.
+// For a source file "foo.ll" the Go source file "foo.go" is generated.
+//
+// LLVM IR bitcode files (e.g. "foo.bc") are detected by their magic bytes and
+// rejected, as bitcode cannot yet be parsed; they may be disassembled into LLVM
+// IR assembly using llvm-dis.
+//
+// ll2go relies on the high-level control flow primitives recovered by
+// restructure. For a source file "foo.ll" containing the functions "bar" and
+// "baz" the control flow primitives are read from the following JSON files.
+//
+//    * foo_graphs/bar.json
+//    * foo_graphs/baz.json
+//
+// If a JSON file is not present, the control flow primitives are recovered
+// from the control flow graph of the function, and cached to disk if -cache is
+// set.
+//
+// Functions which fail to decompile (e.g. due to unsupported LLVM IR
+// constructs) do not prevent the remaining functions from being decompiled. A
+// summary of the failed functions is written to standard error, and ll2go exits
+// with status 3.
+//
+// Usage:
+//
+//    ll2go [OPTION]... FILE.ll...
+//
+// Flags:
+//
+//    -cache
+//          cache recovered control flow primitives as JSON files next to the source file
+//    -debug
+//          output debug messages to standard error
+//    -demangle
+//          derive Go identifiers of C++ symbols from their demangled names, keeping mangled names in comments
+//    -f    force overwrite existing Go source files
+//    -funcs string
+//          comma-separated list of functions to decompile
+//    -g    emit source line comments, as specified by !dbg metadata
+//    -gofmt
+//          format Go source code as by gofmt (default true)
+//    -i8ptr string
+//          Go type of i8 pointers (*int8, []byte or unsafe.Pointer) (default "*int8")
+//    -inline-temps
+//          inline single-use temporaries and declare the rest in a var block per function
+//    -keep-ir-comments
+//          precede Go statements by the LLVM IR instruction they originate from
+//    -max-func-size int
+//          maximum number of instructions of functions to decompile; larger functions are stubbed (default: no limit)
+//    -names string
+//          JSON file mapping LLVM IR global and local names to Go identifiers
+//    -no-phi-propagation
+//          declare PHI variables up front and assign them at the end of predecessor basic blocks
+//    -o string
+//          output directory of Go source files
+//    -pkg string
+//          package name of Go source files (default: source file base name)
+//    -pkgname string
+//          alias of -pkg
+//    -q    suppress non-error messages
+//    -range-loops
+//          rewrite loops over the indices of arrays into for-range loops
+//    -regen
+//          regenerate control flow primitives, even if JSON files are present
+//    -report
+//          report unsupported LLVM IR constructs per function as JSON, instead of decompiling
+//    -runnable
+//          generate a Go main function calling the LLVM IR main function, in package main
+//    -stats
+//          write decompilation coverage statistics to standard error
+//    -stats-json
+//          write decompilation coverage statistics to standard error as JSON
+//    -stdout
+//          write Go source code to standard output
+//    -tail-calls
+//          rewrite tail-recursive self-calls into loops
+//    -target string
+//          target platform of C-derived LLVM IR (e.g. linux-amd64); maps pointer-sized integers to int, and to uintptr in unsigned operations and zeroext parameters; pointer sizes of data layouts and target triples take precedence
+//    -v    alias of -debug
+//    -verify
+//          type-check the generated Go source code
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/ll2go"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/mewkiz/pkg/osutil"
+	"github.com/mewkiz/pkg/pathutil"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// dbg represents a logger with the "ll2go:" prefix, which logs debug messages
+// to standard error if `-debug` is set.
+var dbg = log.New(ioutil.Discard, term.GreenBold("ll2go:")+" ", 0)
+
+func usage() {
+	const use = `
+Decompile LLVM IR assembly to Go source code (*.ll -> *.go).
+
+Usage:
+
+	ll2go [OPTION]... FILE.ll...
+
+Flags:
+`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line flags.
+	var (
+		// cache specifies whether to cache recovered control flow primitives to
+		// disk.
+		cache bool
+		// debug specifies whether to output debug messages to standard error.
+		debug bool
+		// demangle specifies whether to derive the Go identifiers of C++ symbols
+		// from their demangled names.
+		demangle bool
+		// force specifies whether to force overwrite existing Go source files.
+		force bool
+		// funcs represents a comma-separated list of functions to decompile.
+		funcs string
+		// lineComments specifies whether to emit source line comments.
+		lineComments bool
+		// gofmt specifies whether to format the Go source code as by gofmt.
+		gofmt bool
+		// i8Ptr specifies the Go type of i8 pointers.
+		i8Ptr string
+		// inlineTemps specifies whether to inline single-use temporaries into
+		// their use, and declare the remaining temporaries in a var block at the
+		// start of each function.
+		inlineTemps bool
+		// irComments specifies whether to precede Go statements by the LLVM IR
+		// instruction they originate from.
+		irComments bool
+		// maxFuncSize specifies the maximum number of instructions of functions
+		// to decompile.
+		maxFuncSize int
+		// namesPath specifies the JSON file mapping LLVM IR names to Go
+		// identifiers.
+		namesPath string
+		// noPhiPropagation specifies whether to declare PHI variables up front
+		// and assign them at the end of predecessor basic blocks.
+		noPhiPropagation bool
+		// outDir specifies the output directory of Go source files.
+		outDir string
+		// pkgName specifies the package name of Go source files.
+		pkgName string
+		// quiet specifies whether to suppress non-error messages.
+		quiet bool
+		// rangeLoops specifies whether to rewrite loops over the indices of
+		// arrays into for-range loops.
+		rangeLoops bool
+		// regen specifies whether to regenerate control flow primitives, even
+		// if JSON files are present.
+		regen bool
+		// report specifies whether to report the unsupported LLVM IR constructs
+		// of each function as JSON, instead of decompiling.
+		report bool
+		// runnable specifies whether to generate a Go main function which calls
+		// the LLVM IR main function, in package main.
+		runnable bool
+		// stats specifies whether to write decompilation coverage statistics to
+		// standard error.
+		stats bool
+		// statsJSON specifies whether to write decompilation coverage
+		// statistics to standard error as JSON.
+		statsJSON bool
+		// stdout specifies whether to write the Go source code to standard output.
+		stdout bool
+		// tailCalls specifies whether to rewrite tail-recursive self-calls into
+		// loops.
+		tailCalls bool
+		// target specifies the target platform of C-derived LLVM IR.
+		target string
+		// verify specifies whether to type-check the generated Go source code.
+		verify bool
+	)
+	flag.BoolVar(&cache, "cache", false, "cache recovered control flow primitives as JSON files next to the source file")
+	flag.BoolVar(&debug, "debug", false, "output debug messages to standard error")
+	flag.BoolVar(&demangle, "demangle", false, "derive Go identifiers of C++ symbols from their demangled names, keeping mangled names in comments")
+	flag.BoolVar(&force, "f", false, "force overwrite existing Go source files")
+	flag.StringVar(&funcs, "funcs", "", "comma-separated list of functions to decompile")
+	flag.BoolVar(&lineComments, "g", false, "emit source line comments, as specified by !dbg metadata")
+	flag.BoolVar(&gofmt, "gofmt", true, "format Go source code as by gofmt")
+	flag.StringVar(&i8Ptr, "i8ptr", "*int8", "Go type of i8 pointers (*int8, []byte or unsafe.Pointer)")
+	flag.BoolVar(&inlineTemps, "inline-temps", false, "inline single-use temporaries and declare the rest in a var block per function")
+	flag.BoolVar(&irComments, "keep-ir-comments", false, "precede Go statements by the LLVM IR instruction they originate from")
+	flag.IntVar(&maxFuncSize, "max-func-size", 0, "maximum number of instructions of functions to decompile; larger functions are stubbed (default: no limit)")
+	flag.StringVar(&namesPath, "names", "", "JSON file mapping LLVM IR global and local names to Go identifiers")
+	flag.BoolVar(&noPhiPropagation, "no-phi-propagation", false, "declare PHI variables up front and assign them at the end of predecessor basic blocks")
+	flag.StringVar(&outDir, "o", "", "output directory of Go source files")
+	flag.StringVar(&pkgName, "pkg", "", "package name of Go source files (default: source file base name)")
+	flag.StringVar(&pkgName, "pkgname", "", "alias of -pkg")
+	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	flag.BoolVar(&rangeLoops, "range-loops", false, "rewrite loops over the indices of arrays into for-range loops")
+	flag.BoolVar(&regen, "regen", false, "regenerate control flow primitives, even if JSON files are present")
+	flag.BoolVar(&report, "report", false, "report unsupported LLVM IR constructs per function as JSON, instead of decompiling")
+	flag.BoolVar(&runnable, "runnable", false, "generate a Go main function calling the LLVM IR main function, in package main")
+	flag.BoolVar(&stats, "stats", false, "write decompilation coverage statistics to standard error")
+	flag.BoolVar(&statsJSON, "stats-json", false, "write decompilation coverage statistics to standard error as JSON")
+	flag.BoolVar(&stdout, "stdout", false, "write Go source code to standard output")
+	flag.BoolVar(&tailCalls, "tail-calls", false, "rewrite tail-recursive self-calls into loops")
+	flag.StringVar(&target, "target", "", "target platform of C-derived LLVM IR (e.g. linux-amd64); maps pointer-sized integers to int, and to uintptr in unsigned operations and zeroext parameters; pointer sizes of data layouts and target triples take precedence")
+	flag.BoolVar(&debug, "v", false, "alias of -debug")
+	flag.BoolVar(&verify, "verify", false, "type-check the generated Go source code")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	// Decompile specified functions if `-funcs` is set.
+	funcNames := make(map[string]bool)
+	for _, funcName := range strings.Split(funcs, ",") {
+		if len(funcName) < 1 {
+			continue
+		}
+		funcNames[funcName] = true
+	}
+	switch i8Ptr {
+	case "*int8", "[]byte", "unsafe.Pointer":
+		// valid Go type of i8 pointers.
+	default:
+		log.Fatalf("invalid -i8ptr type %q; expected *int8, []byte or unsafe.Pointer", i8Ptr)
+	}
+	if maxFuncSize < 0 {
+		log.Fatalf("invalid -max-func-size %d; expected non-negative number of instructions", maxFuncSize)
+	}
+	if len(target) > 0 {
+		if _, err := ll2go.TargetPtrSize(target); err != nil {
+			log.Fatalf("invalid -target platform; %v", err)
+		}
+	}
+	// Output debug messages to standard error if `-debug` is set, unless muted
+	// by `-q`. Standard output is reserved for Go source code.
+	if debug && !quiet {
+		dbg.SetOutput(os.Stderr)
+		ll2go.SetDebugOutput(os.Stderr)
+	}
+
+	// Validate package name if `-pkg` is set.
+	if len(pkgName) > 0 && ll2go.Sanitize(pkgName) != pkgName {
+		log.Fatalf("invalid -pkg package name %q; expected Go identifier", pkgName)
+	}
+	if runnable && len(pkgName) > 0 && pkgName != "main" {
+		log.Fatalf("invalid -pkg package name %q; runnable programs are in package main", pkgName)
+	}
+	// Create output directory if `-o` is set.
+	if len(outDir) > 0 && !stdout && !report {
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+	}
+
+	// Decompile LLVM IR files.
+	d := ll2go.NewDecompiler()
+	d.I8Ptr = i8Ptr
+	d.LineComments = lineComments
+	d.IRComments = irComments
+	d.NoPhiPropagation = noPhiPropagation
+	d.Target = target
+	d.RangeLoops = rangeLoops
+	d.TailCalls = tailCalls
+	d.InlineTemps = inlineTemps
+	d.Runnable = runnable
+	d.MaxFuncSize = maxFuncSize
+	d.Demangle = demangle
+	// Record decompilation coverage statistics if `-stats` or `-stats-json` is
+	// set.
+	if stats || statsJSON {
+		d.Stats = &ll2go.Stats{}
+	}
+	// Rename identifiers if `-names` is set.
+	if len(namesPath) > 0 {
+		names, err := readSymbolMap(namesPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		d.Names = names
+	}
+	goPaths := outputPaths(flag.Args(), outDir)
+	var reports []*fileReport
+	var failures []*fileFailure
+	for _, llPath := range flag.Args() {
+		// Report unsupported LLVM IR constructs if `-report` is set.
+		if report {
+			r, err := reportFile(d, llPath, funcNames)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			reports = append(reports, r)
+			continue
+		}
+		file, err := decompile(d, llPath, funcNames, regen, cache)
+		if err != nil {
+			// Emit the functions which were successfully decompiled, and
+			// summarize the functions which failed to decompile at the end.
+			funcErrs, ok := errors.Cause(err).(ll2go.FuncErrors)
+			if !ok {
+				log.Fatalf("%+v", err)
+			}
+			failures = append(failures, &fileFailure{File: llPath, Errs: funcErrs})
+		}
+		// Override package name if `-pkg` is set.
+		if len(pkgName) > 0 {
+			file.Name = ast.NewIdent(pkgName)
+		}
+		if err := storeFile(goPaths[llPath], file, stdout, gofmt, force); err != nil {
+			log.Fatalf("%+v", err)
+		}
+		// Type-check Go source file if `-verify` is set.
+		if verify {
+			if err := ll2go.Verify(goPaths[llPath], file); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+	}
+	if report {
+		if err := writeReports(os.Stdout, reports); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	if d.Stats != nil {
+		if err := writeStats(os.Stderr, d.Stats, statsJSON); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	if len(failures) > 0 {
+		writeFailures(os.Stderr, failures)
+		os.Exit(exitFuncErrors)
+	}
+}
+
+// exitFuncErrors is the exit code of ll2go when one or more functions failed to
+// decompile, as distinguished from fatal errors (exit code 1) and invalid
+// command line flags (exit code 2).
+const exitFuncErrors = 3
+
+// decompile decompiles the provided LLVM IR assembly file into a corresponding
+// Go source file, using the given decompiler. The regen argument specifies
+// whether to regenerate control flow primitives, even if JSON files are
+// present, and the cache argument whether to cache recovered control flow
+// primitives to disk.
+//
+// If some functions failed to decompile, or their control flow primitives
+// could not be parsed, the Go source file of the remaining functions is
+// returned along with an error of type ll2go.FuncErrors.
+func decompile(d *ll2go.Decompiler, llPath string, funcNames map[string]bool, regen, cache bool) (*ast.File, error) {
+	module, err := parseModule(llPath, funcNames)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse control flow primitives. Functions whose control flow primitives
+	// could not be parsed are skipped, and reported as failed to decompile.
+	prims := make(map[string][]*primitive.Primitive)
+	var primsErrs ll2go.FuncErrors
+	var funcs []*ir.Func
+	for _, f := range module.Funcs {
+		// Skip function declarations, and control flow recovery of functions
+		// which are replaced by stubs, as set by `-max-func-size`.
+		if len(f.Blocks) == 0 || d.ExceedsMaxFuncSize(f) {
+			funcs = append(funcs, f)
+			continue
+		}
+		fprims, err := parsePrims(llPath, f, regen, cache)
+		if err != nil {
+			primsErrs = append(primsErrs, &ll2go.FuncError{Func: f.Name(), Err: err})
+			continue
+		}
+		prims[f.Name()] = fprims
+		funcs = append(funcs, f)
+	}
+	module.Funcs = funcs
+
+	// Decompile module. If some functions failed to decompile, the Go source
+	// file of the remaining functions is returned along with the error.
+	file, err := d.Decompile(module, prims)
+	if file == nil {
+		return nil, errors.WithStack(err)
+	}
+	// Runnable programs are in package main.
+	if !d.Runnable {
+		file.Name = ast.NewIdent(packageName(llPath))
+	}
+	if len(primsErrs) > 0 {
+		funcErrs, _ := err.(ll2go.FuncErrors)
+		err = append(primsErrs, funcErrs...)
+	}
+	if err != nil {
+		return file, errors.WithStack(err)
+	}
+	return file, nil
+}
+
+// readSymbolMap reads the JSON file mapping LLVM IR names to Go identifiers,
+// as specified by `-names`; e.g.
+//
+//    {
+//       "globals": {"func_1234": "ParseHeader"},
+//       "locals": {"func_1234": {"0": "hdr", "n.addr": "n"}}
+//    }
+func readSymbolMap(jsonPath string) (*ll2go.SymbolMap, error) {
+	buf, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := &ll2go.SymbolMap{}
+	if err := json.Unmarshal(buf, names); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse symbol map %q", jsonPath)
+	}
+	check := func(name, ident string) error {
+		if ll2go.Sanitize(ident) != ident {
+			return errors.Errorf("invalid Go identifier %q of %q in symbol map %q", ident, name, jsonPath)
+		}
+		return nil
+	}
+	for name, ident := range names.Globals {
+		if err := check("@"+name, ident); err != nil {
+			return nil, err
+		}
+	}
+	for funcName, locals := range names.Locals {
+		for name, ident := range locals {
+			if err := check("@"+funcName+" %"+name, ident); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return names, nil
+}
+
+// A fileFailure records the functions of an LLVM IR assembly file which failed
+// to decompile.
+type fileFailure struct {
+	// LLVM IR assembly file path.
+	File string
+	// Errors of the functions which failed to decompile.
+	Errs ll2go.FuncErrors
+}
+
+// writeFailures writes a summary of the functions which failed to decompile to
+// w, one function per line.
+func writeFailures(w io.Writer, failures []*fileFailure) {
+	n := 0
+	for _, failure := range failures {
+		n += len(failure.Errs)
+	}
+	fmt.Fprintf(w, "ll2go: unable to decompile %d function(s):\n", n)
+	for _, failure := range failures {
+		for _, e := range failure.Errs {
+			fmt.Fprintf(w, "\t%s: %s: %v\n", failure.File, e.Func, e.Err)
+		}
+	}
+}
+
+// writeStats writes the given decompilation coverage statistics to w, either as
+// a human-readable summary or as JSON; e.g.
+//
+//    ll2go: decompiled 10 function(s):
+//    	recovered:  7
+//    	partial:    1 (goto fallback)
+//    	stubbed:    0
+//    	failed:     2
+//    ll2go: unsupported LLVM IR constructs:
+//    	2	instruction *ir.InstFreeze
+func writeStats(w io.Writer, stats *ll2go.Stats, asJSON bool) error {
+	if asJSON {
+		buf, err := json.MarshalIndent(stats, "", "\t")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf = append(buf, '\n')
+		if _, err := w.Write(buf); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "ll2go: decompiled %d function(s):\n", stats.Funcs)
+	fmt.Fprintf(buf, "\trecovered:  %d\n", stats.Recovered)
+	fmt.Fprintf(buf, "\tpartial:    %d (goto fallback)\n", stats.Partial)
+	fmt.Fprintf(buf, "\tstubbed:    %d\n", stats.Stubbed)
+	fmt.Fprintf(buf, "\tfailed:     %d\n", stats.Failed)
+	if len(stats.Unsupported) > 0 {
+		fmt.Fprintln(buf, "ll2go: unsupported LLVM IR constructs:")
+		for _, u := range stats.Unsupported {
+			fmt.Fprintf(buf, "\t%d\t%s %s\n", u.Count, u.Kind, u.Type)
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// parseModule parses the provided LLVM IR assembly file, keeping only the
+// functions set by `-funcs`, or all functions if `-funcs` is not used. LLVM IR
+// bitcode files are rejected.
+func parseModule(llPath string, funcNames map[string]bool) (*ir.Module, error) {
+	buf, err := ioutil.ReadFile(llPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if isBitcode(buf) {
+		return nil, errors.Errorf("support for LLVM IR bitcode file %q not yet implemented; disassemble it using llvm-dis", llPath)
+	}
+	dbg.Printf("parsing file %q.", llPath)
+	module, err := asm.ParseBytes(llPath, buf)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var funcs []*ir.Func
+	for _, f := range module.Funcs {
+		if len(funcNames) > 0 && !funcNames[f.Name()] {
+			dbg.Printf("skipping function %q.", f.Name())
+			continue
+		}
+		funcs = append(funcs, f)
+	}
+	module.Funcs = funcs
+	return module, nil
+}
+
+// Magic bytes of LLVM IR bitcode files, either raw or wrapped (e.g. as emitted
+// for Darwin targets).
+var (
+	bitcodeMagic        = []byte{'B', 'C', 0xC0, 0xDE}
+	bitcodeWrapperMagic = []byte{0xDE, 0xC0, 0x17, 0x0B}
+)
+
+// isBitcode reports whether the given file contents are LLVM IR bitcode, based
+// on their magic bytes.
+func isBitcode(buf []byte) bool {
+	return bytes.HasPrefix(buf, bitcodeMagic) || bytes.HasPrefix(buf, bitcodeWrapperMagic)
+}
+
+// A fileReport reports the unsupported LLVM IR constructs of each function of
+// an LLVM IR assembly file.
+type fileReport struct {
+	// LLVM IR assembly file path.
+	File string `json:"file"`
+	// Reports of the function definitions of the file.
+	Funcs []*ll2go.FuncReport `json:"funcs"`
+}
+
+// reportFile reports the unsupported LLVM IR constructs of each function of
+// the provided LLVM IR assembly file, using the given decompiler. The control
+// flow primitives of each function are recovered without being cached to disk,
+// as `-report` is a dry run.
+func reportFile(d *ll2go.Decompiler, llPath string, funcNames map[string]bool) (*fileReport, error) {
+	module, err := parseModule(llPath, funcNames)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileReport{File: llPath, Funcs: d.Report(module)}, nil
+}
+
+// writeReports writes the given reports of LLVM IR assembly files to w, as an
+// indented JSON array, to be aggregated across files.
+func writeReports(w io.Writer, reports []*fileReport) error {
+	if reports == nil {
+		reports = []*fileReport{}
+	}
+	buf, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	if _, err := w.Write(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// outputPaths returns the output paths of the Go source files corresponding to
+// the given LLVM IR files; mapping from LLVM IR path to Go source path.
+//
+// For a source file "foo.ll" the Go source file "foo.go" is created in the same
+// directory, or in the output directory if set. Within the output directory,
+// source files sharing a base name are disambiguated by the name of their
+// parent directory; e.g. "a/foo.ll" and "b/foo.ll" are decompiled into
+// "a_foo.go" and "b_foo.go", respectively.
+func outputPaths(llPaths []string, outDir string) map[string]string {
+	goPaths := make(map[string]string)
+	if len(outDir) == 0 {
+		for _, llPath := range llPaths {
+			goPaths[llPath] = pathutil.TrimExt(llPath) + ".go"
+		}
+		return goPaths
+	}
+	baseNames := make(map[string]int)
+	for _, llPath := range llPaths {
+		baseNames[pathutil.FileName(llPath)]++
+	}
+	used := make(map[string]bool)
+	for _, llPath := range llPaths {
+		name := pathutil.FileName(llPath)
+		if baseNames[name] > 1 {
+			parent := filepath.Base(filepath.Dir(llPath))
+			name = ll2go.Sanitize(parent) + "_" + name
+		}
+		// Disambiguate any remaining name collisions by a numeric suffix.
+		goName := name + ".go"
+		for i := 2; used[goName]; i++ {
+			goName = fmt.Sprintf("%s_%d.go", name, i)
+		}
+		used[goName] = true
+		goPaths[llPath] = filepath.Join(outDir, goName)
+	}
+	return goPaths
+}
+
+// storeFile stores the given Go source file to the given path, formatted as by
+// gofmt if the `-gofmt` flag is set. If the `-stdout` flag is set, the Go
+// source file is instead written to standard output. Existing Go source files
+// are only overwritten if the `-f` flag is set.
+func storeFile(goPath string, file *ast.File, stdout, gofmt, force bool) error {
+	src, err := render(file, gofmt)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if stdout {
+		if _, err := os.Stdout.Write(src); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	if !force {
+		if ok, _ := osutil.Exists(goPath); ok {
+			return errors.Errorf("output file %q already exists; use -f to overwrite", goPath)
+		}
+	}
+	dbg.Printf("creating file %q.", goPath)
+	if err := ioutil.WriteFile(goPath, src, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// render returns the Go source code of the given Go source file, formatted as
+// by gofmt if gofmt is set.
+//
+// Should formatting fail (e.g. as the decompiled Go source file is invalid),
+// the unformatted Go source code is returned, preceded by a comment describing
+// the formatting error.
+func render(file *ast.File, gofmt bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, ll2go.FileSet(), file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !gofmt {
+		return buf.Bytes(), nil
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		dbg.Printf("unable to format Go source code of package %q; %v", file.Name, err)
+		note := fmt.Sprintf("// ll2go: unable to format Go source code; %v\n\n", err)
+		return append([]byte(note), buf.Bytes()...), nil
+	}
+	return src, nil
+}
+
+// packageName returns a valid Go package name based on the base name of the
+// given LLVM IR file; e.g. "foo" for "foo.ll" and "_123_foo" for "123-foo.ll".
+func packageName(llPath string) string {
+	return ll2go.Sanitize(pathutil.FileName(llPath))
+}
+
+// parsePrims parses the high-level control flow primitives of the given
+// function, as recovered by restructure.
+//
+// For a source file "foo.ll" containing the functions "bar" and "baz" the
+// following JSON files are parsed:
+//
+//    foo_graphs/bar.json
+//    foo_graphs/baz.json
+//
+// If the JSON file is not present, or regen is set, the control flow primitives
+// are recovered from the control flow graph of the function, and cached to disk
+// if cache is set.
+func parsePrims(llPath string, f *ir.Func, regen, cache bool) ([]*primitive.Primitive, error) {
+	jsonName := f.Name() + ".json"
+	graphsDir := pathutil.TrimExt(llPath) + "_graphs"
+	jsonPath := filepath.Join(graphsDir, jsonName)
+	if !regen {
+		buf, err := ioutil.ReadFile(jsonPath)
+		switch {
+		case err == nil:
+			dbg.Printf("parsing file %q.", jsonPath)
+			var prims []*primitive.Primitive
+			if err := json.Unmarshal(buf, &prims); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return prims, nil
+		case !os.IsNotExist(err):
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// Recover control flow primitives.
+	dbg.Printf("recovering control flow primitives of function %q.", f.Name())
+	prims, err := ll2go.RecoverPrims(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !cache {
+		return prims, nil
+	}
+
+	// Cache control flow primitives; failure is not fatal.
+	buf, err := json.Marshal(prims)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(graphsDir, 0755); err != nil {
+		dbg.Printf("unable to cache control flow primitives; %v", err)
+		return prims, nil
+	}
+	dbg.Printf("creating file %q.", jsonPath)
+	if err := ioutil.WriteFile(jsonPath, append(buf, '\n'), 0644); err != nil {
+		dbg.Printf("unable to cache control flow primitives; %v", err)
+	}
+	return prims, nil
+}
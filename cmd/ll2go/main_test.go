@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/decomp/decomp/ll2go"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+func TestOutputPaths(t *testing.T) {
+	golden := []struct {
+		llPaths []string
+		outDir  string
+		want    map[string]string
+	}{
+		// Output next to the source files.
+		{
+			llPaths: []string{"foo.ll", "a/bar.ll", "b/bar.ll"},
+			want: map[string]string{
+				"foo.ll":   "foo.go",
+				"a/bar.ll": "a/bar.go",
+				"b/bar.ll": "b/bar.go",
+			},
+		},
+		// Output directory.
+		{
+			llPaths: []string{"foo.ll", "a/bar.ll"},
+			outDir:  "out",
+			want: map[string]string{
+				"foo.ll":   "out/foo.go",
+				"a/bar.ll": "out/bar.go",
+			},
+		},
+		// Output directory with shared base names.
+		{
+			llPaths: []string{"a/foo.ll", "b/foo.ll", "c/a/foo.ll", "bar.ll"},
+			outDir:  "out",
+			want: map[string]string{
+				"a/foo.ll":   "out/a_foo.go",
+				"b/foo.ll":   "out/b_foo.go",
+				"c/a/foo.ll": "out/a_foo_2.go",
+				"bar.ll":     "out/bar.go",
+			},
+		},
+	}
+	for _, g := range golden {
+		got := outputPaths(g.llPaths, g.outDir)
+		for llPath, want := range g.want {
+			if got[llPath] != want {
+				t.Errorf("%q: output path mismatch; expected %q, got %q", llPath, want, got[llPath])
+			}
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	golden := []struct {
+		llPath string
+		want   string
+	}{
+		{llPath: "foo.ll", want: "foo"},
+		{llPath: "dir/foo.ll", want: "foo"},
+		{llPath: "123-foo.ll", want: "_123_foo"},
+		{llPath: "foo-bar.baz.ll", want: "foo_bar_baz"},
+		{llPath: "func.ll", want: "_func"},
+	}
+	for _, g := range golden {
+		if got := packageName(g.llPath); got != g.want {
+			t.Errorf("%q: package name mismatch; expected %q, got %q", g.llPath, g.want, got)
+		}
+	}
+}
+
+func TestStoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := ir.NewModule()
+	m.NewTypeDef("struct.point", types.NewStruct(types.I32, types.I32))
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 42))
+	file, err := ll2go.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	file.Name = ast.NewIdent("foo")
+	goPath := filepath.Join(dir, "foo.go")
+	if err := storeFile(goPath, file, false, true, false); err != nil {
+		t.Fatalf("unable to store file; %v", err)
+	}
+	buf, err := ioutil.ReadFile(goPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package foo
+
+type point struct {
+	Field0 int32
+	Field1 int32
+}
+
+func f() int32 {
+	return 42
+}
+`
+	if got := string(buf); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	// Existing Go source files are only overwritten if forced.
+	if err := storeFile(goPath, file, false, true, false); err == nil {
+		t.Errorf("expected error for existing output file %q", goPath)
+	}
+	if err := storeFile(goPath, file, false, true, true); err != nil {
+		t.Errorf("unable to overwrite file; %v", err)
+	}
+}
+
+func TestParsePrims(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I1)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	exit := f.NewBlock("exit")
+	entry.NewCondBr(x, a, b)
+	a.NewBr(exit)
+	b.NewBr(exit)
+	exit.NewRet(constant.NewInt(types.I32, 0))
+
+	// Recover control flow primitives, without caching them.
+	llPath := filepath.Join(dir, "foo.ll")
+	if _, err := parsePrims(llPath, f, false, false); err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "foo_graphs")); !os.IsNotExist(err) {
+		t.Fatalf("control flow primitives cached without -cache; %v", err)
+	}
+
+	// Recover and cache control flow primitives.
+	prims, err := parsePrims(llPath, f, false, true)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	if len(prims) == 0 {
+		t.Fatalf("no control flow primitives recovered")
+	}
+	if got, want := prims[0].Prim, "if_else"; got != want {
+		t.Errorf("primitive mismatch; expected %q, got %q", want, got)
+	}
+	jsonPath := filepath.Join(dir, "foo_graphs", "f.json")
+	if _, err := os.Stat(jsonPath); err != nil {
+		t.Fatalf("control flow primitives not cached; %v", err)
+	}
+
+	// Parse cached control flow primitives.
+	cached, err := parsePrims(llPath, f, false, true)
+	if err != nil {
+		t.Fatalf("unable to parse control flow primitives; %v", err)
+	}
+	if len(cached) != len(prims) {
+		t.Errorf("number of cached primitives mismatch; expected %d, got %d", len(prims), len(cached))
+	}
+}
+
+func TestDecompileMaxFuncSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const src = `
+define i32 @f() {
+entry:
+	ret i32 0
+}
+
+define i32 @g(i1 %x) {
+entry:
+	br i1 %x, label %a, label %b
+a:
+	br label %exit
+b:
+	br label %exit
+exit:
+	ret i32 1
+}
+`
+	llPath := filepath.Join(dir, "foo.ll")
+	if err := ioutil.WriteFile(llPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := ll2go.NewDecompiler()
+	d.MaxFuncSize = 2
+	file, err := decompile(d, llPath, nil, false, true)
+	if err != nil {
+		t.Fatalf("unable to decompile %q; %v", llPath, err)
+	}
+	if n := len(file.Decls); n != 2 {
+		t.Fatalf("number of declarations mismatch; expected 2, got %d", n)
+	}
+	// The control flow primitives of functions exceeding the maximum function
+	// size are neither recovered nor cached.
+	graphsDir := filepath.Join(dir, "foo_graphs")
+	if _, err := os.Stat(filepath.Join(graphsDir, "f.json")); err != nil {
+		t.Errorf("control flow primitives of f not cached; %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(graphsDir, "g.json")); !os.IsNotExist(err) {
+		t.Errorf("control flow primitives of stubbed function g cached; %v", err)
+	}
+}
+
+func TestDecompilePrimsError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const src = `
+define i32 @f() {
+entry:
+	ret i32 0
+}
+
+define i32 @g() {
+entry:
+	ret i32 1
+}
+`
+	llPath := filepath.Join(dir, "foo.ll")
+	if err := ioutil.WriteFile(llPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid control flow primitives of g.
+	graphsDir := filepath.Join(dir, "foo_graphs")
+	if err := os.MkdirAll(graphsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(graphsDir, "g.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := ll2go.NewDecompiler()
+	file, err := decompile(d, llPath, nil, false, false)
+	funcErrs, ok := errors.Cause(err).(ll2go.FuncErrors)
+	if !ok {
+		t.Fatalf("error type mismatch; expected ll2go.FuncErrors, got %T (%v)", err, err)
+	}
+	if len(funcErrs) != 1 || funcErrs[0].Func != "g" {
+		t.Errorf("function errors mismatch; expected error of g, got %v", funcErrs)
+	}
+	// The remaining functions are decompiled.
+	if file == nil {
+		t.Fatalf("expected Go source file of the remaining functions")
+	}
+	if n := len(file.Decls); n != 1 {
+		t.Errorf("number of declarations mismatch; expected 1, got %d", n)
+	}
+}
+
+func TestRender(t *testing.T) {
+	//    int32_t f(int32_t x, int64_t y) {
+	//       int64_t z = (int64_t)x + y;
+	//       return (int32_t)z;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I64)
+	f := m.NewFunc("f", types.I32, x, y)
+	entry := f.NewBlock("entry")
+	ext := entry.NewSExt(x, types.I64)
+	sum := entry.NewAdd(ext, y)
+	entry.NewRet(entry.NewTrunc(sum, types.I32))
+	file, err := ll2go.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	file.Name = ast.NewIdent("foo")
+	got, err := render(file, true)
+	if err != nil {
+		t.Fatalf("unable to render Go source file; %v", err)
+	}
+	const goldenPath = "testdata/render.go.golden"
+	want, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+
+	// Invalid Go source files are rendered unformatted.
+	file.Decls = append(file.Decls, &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent("1x")}}}})
+	got, err = render(file, true)
+	if err != nil {
+		t.Fatalf("unable to render Go source file; %v", err)
+	}
+	const prefix = "// ll2go: unable to format Go source code; "
+	if !bytes.HasPrefix(got, []byte(prefix)) {
+		t.Errorf("missing formatting error note; expected prefix %q, got %q", prefix, got)
+	}
+}
+
+func TestReadSymbolMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	golden := []struct {
+		json string
+		err  bool
+	}{
+		{json: `{"globals": {"func_1234": "ParseHeader"}, "locals": {"func_1234": {"0": "hdr"}}}`},
+		// Invalid Go identifiers.
+		{json: `{"globals": {"f": "a-b"}}`, err: true},
+		{json: `{"locals": {"f": {"0": "range"}}}`, err: true},
+		// Invalid JSON.
+		{json: `{"globals": `, err: true},
+	}
+	for i, g := range golden {
+		jsonPath := filepath.Join(dir, fmt.Sprintf("names_%d.json", i))
+		if err := ioutil.WriteFile(jsonPath, []byte(g.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names, err := readSymbolMap(jsonPath)
+		if g.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got nil", g.json)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unable to read symbol map; %v", g.json, err)
+			continue
+		}
+		if got := names.Globals["func_1234"]; got != "ParseHeader" {
+			t.Errorf("global name mismatch; expected %q, got %q", "ParseHeader", got)
+		}
+		if got := names.Locals["func_1234"]["0"]; got != "hdr" {
+			t.Errorf("local name mismatch; expected %q, got %q", "hdr", got)
+		}
+	}
+}
+
+func TestWriteFailures(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	h := m.NewFunc("h", types.I32)
+	entry := h.NewBlock("entry")
+	entry.NewRet(entry.NewLoad(types.I32, constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))))
+	file, err := ll2go.NewDecompiler().Decompile(m, nil)
+	funcErrs, ok := err.(ll2go.FuncErrors)
+	if !ok {
+		t.Fatalf("error type mismatch; expected ll2go.FuncErrors, got %T (%v)", err, err)
+	}
+	if file == nil {
+		t.Fatalf("expected Go source file of the supported function")
+	}
+	buf := &bytes.Buffer{}
+	writeFailures(buf, []*fileFailure{{File: "foo.ll", Errs: funcErrs}})
+	const want = "ll2go: unable to decompile 1 function(s):\n\tfoo.ll: h: support for constant expression *constant.ExprAddrSpaceCast not yet implemented\n"
+	if got := buf.String(); got != want {
+		t.Errorf("summary mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	f := m.NewFunc("f", types.I32)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewLoad(types.I32, constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))))
+	reports := []*fileReport{
+		{File: "foo.ll", Funcs: ll2go.NewDecompiler().Report(m)},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeReports(buf, reports); err != nil {
+		t.Fatalf("unable to write reports; %v", err)
+	}
+	want := `[
+	{
+		"file": "foo.ll",
+		"funcs": [
+			{
+				"func": "f",
+				"unsupported": [
+					{
+						"kind": "constant expression",
+						"type": "*constant.ExprAddrSpaceCast",
+						"count": 1
+					}
+				]
+			}
+		]
+	}
+]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("report mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	stats := &ll2go.Stats{
+		Funcs:     4,
+		Recovered: 2,
+		Partial:   1,
+		Failed:    1,
+		Unsupported: []*ll2go.Unsupported{
+			{Kind: "instruction", Type: "*ir.InstFreeze", Count: 2},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeStats(buf, stats, false); err != nil {
+		t.Fatalf("unable to write statistics; %v", err)
+	}
+	want := "ll2go: decompiled 4 function(s):\n\trecovered:  2\n\tpartial:    1 (goto fallback)\n\tstubbed:    0\n\tfailed:     1\nll2go: unsupported LLVM IR constructs:\n\t2\tinstruction *ir.InstFreeze\n"
+	if got := buf.String(); got != want {
+		t.Errorf("summary mismatch; expected %q, got %q", want, got)
+	}
+	buf.Reset()
+	if err := writeStats(buf, stats, true); err != nil {
+		t.Fatalf("unable to write statistics as JSON; %v", err)
+	}
+	want = `{
+	"funcs": 4,
+	"recovered": 2,
+	"partial": 1,
+	"stubbed": 0,
+	"failed": 1,
+	"unsupported": [
+		{
+			"kind": "instruction",
+			"type": "*ir.InstFreeze",
+			"count": 2
+		}
+	]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("JSON mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestParseModuleBitcode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ll2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// LLVM IR bitcode files, either raw or wrapped, are rejected.
+	for i, magic := range [][]byte{bitcodeMagic, bitcodeWrapperMagic} {
+		bcPath := filepath.Join(dir, fmt.Sprintf("bitcode_%d.bc", i))
+		if err := ioutil.WriteFile(bcPath, magic, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseModule(bcPath, nil); err == nil {
+			t.Errorf("%q: expected error for LLVM IR bitcode file", bcPath)
+		}
+	}
+}
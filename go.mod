@@ -0,0 +1,9 @@
+module github.com/decomp/decomp
+
+go 1.13
+
+require (
+	github.com/llir/llvm v0.3.6
+	github.com/pkg/errors v0.9.1
+	golang.org/x/tools v0.1.4
+)
@@ -3,45 +3,65 @@ package cfg
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/gonum/graph"
 	"github.com/gonum/graph/simple"
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
 )
 
 // New returns a new control flow graph based on the given function.
-func New(f *ir.Function) *Graph {
-	// Force generate local IDs.
-	_ = f.String()
+func New(f *ir.Func) (*Graph, error) {
+	// Force generate local IDs, without the cost of printing the function.
+	if err := f.AssignIDs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	g := newGraph()
 	for _, block := range f.Blocks {
-		from := g.NewNodeWithLabel(block.Name)
+		from := g.NewNodeWithLabel(block.Name())
 		switch term := block.Term.(type) {
 		case *ir.TermRet:
 			// nothing to do.
 		case *ir.TermBr:
-			to := g.NewNodeWithLabel(term.Target.Name)
+			to := g.NewNodeWithLabel(blockName(term.Target))
 			g.NewEdgeWithLabel(from, to, "")
 		case *ir.TermCondBr:
-			to := g.NewNodeWithLabel(term.TargetTrue.Name)
+			to := g.NewNodeWithLabel(blockName(term.TargetTrue))
 			g.NewEdgeWithLabel(from, to, "true")
-			to = g.NewNodeWithLabel(term.TargetFalse.Name)
+			to = g.NewNodeWithLabel(blockName(term.TargetFalse))
 			g.NewEdgeWithLabel(from, to, "false")
 		case *ir.TermSwitch:
 			for _, c := range term.Cases {
-				to := g.NewNodeWithLabel(c.Target.Name)
+				to := g.NewNodeWithLabel(blockName(c.Target))
 				label := fmt.Sprintf("case (x=%v)", c.X.Ident())
 				g.NewEdgeWithLabel(from, to, label)
 			}
-			to := g.NewNodeWithLabel(term.TargetDefault.Name)
+			to := g.NewNodeWithLabel(blockName(term.TargetDefault))
 			g.NewEdgeWithLabel(from, to, "default case")
-		case *ir.TermUnreachable:
+		case *ir.TermIndirectBr:
+			for _, target := range term.ValidTargets {
+				to := g.NewNodeWithLabel(blockName(target))
+				g.NewEdgeWithLabel(from, to, "")
+			}
+		case *ir.TermInvoke:
+			to := g.NewNodeWithLabel(blockName(term.NormalRetTarget))
+			g.NewEdgeWithLabel(from, to, "normal")
+			to = g.NewNodeWithLabel(blockName(term.ExceptionRetTarget))
+			g.NewEdgeWithLabel(from, to, "unwind")
+		case *ir.TermResume, *ir.TermUnreachable:
 			// nothing to do.
 		default:
-			panic(fmt.Sprintf("support for terminator %T not yet implemented", term))
+			return nil, errors.Errorf("support for terminator %T not yet implemented", term)
 		}
 	}
-	return g
+	return g, nil
+}
+
+// blockName returns the name of the given branch target basic block.
+func blockName(target value.Value) string {
+	return target.(*ir.Block).Name()
 }
 
 // Graph represents a control flow graph, and implements the
@@ -70,6 +90,40 @@ func (g *Graph) RemoveNode(n graph.Node) {
 	g.DirectedGraph.RemoveNode(n)
 }
 
+// Nodes returns all the nodes in the graph, sorted by node ID.
+//
+// The nodes are sorted to make control flow analysis deterministic, as the
+// underlying graph stores its nodes in maps without a defined iteration order.
+func (g *Graph) Nodes() []graph.Node {
+	return sortByID(g.DirectedGraph.Nodes())
+}
+
+// From returns all the nodes that can be reached directly from n, sorted by
+// node ID.
+func (g *Graph) From(n graph.Node) []graph.Node {
+	return sortByID(g.DirectedGraph.From(n))
+}
+
+// To returns all the nodes that can reach n directly, sorted by node ID.
+func (g *Graph) To(n graph.Node) []graph.Node {
+	return sortByID(g.DirectedGraph.To(n))
+}
+
+// sortByID sorts the given nodes in place by node ID, and returns them.
+func sortByID(nodes []graph.Node) []graph.Node {
+	// Avoid the reflection-based swapper of sort.Slice, as nodes are sorted on
+	// every graph query.
+	sort.Sort(byID(nodes))
+	return nodes
+}
+
+// byID implements sort.Interface, sorting nodes by node ID.
+type byID []graph.Node
+
+func (ns byID) Len() int           { return len(ns) }
+func (ns byID) Less(i, j int) bool { return ns[i].ID() < ns[j].ID() }
+func (ns byID) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
+
 // NodeByLabel returns the node in the graph with the given label.
 func (g *Graph) NodeByLabel(label string) *Node {
 	return g.nodes[label]
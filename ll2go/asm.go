@@ -0,0 +1,58 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// inlineAsm returns the Go expression of the given LLVM IR inline assembly
+// expression; a stub function literal of the same signature, which panics when
+// called, as Go has no inline assembly; e.g.
+//
+//    func(int32) int32 {
+//       panic("inline asm not supported")
+//    }
+//
+// The assembly of calls to inline assembly is documented by asmComment.
+func (d *Decompiler) inlineAsm(asm *ir.InlineAsm) (ast.Expr, error) {
+	ptr, ok := asm.Typ.(*types.PointerType)
+	if !ok {
+		return nil, errors.Errorf("invalid inline asm type %v; expected function pointer", asm.Typ)
+	}
+	sig, ok := ptr.ElemType.(*types.FuncType)
+	if !ok {
+		return nil, errors.Errorf("invalid inline asm type %v; expected function pointer", asm.Typ)
+	}
+	panicCall := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("inline asm not supported")}},
+	}
+	return &ast.FuncLit{
+		Type: d.GoType(sig).(*ast.FuncType),
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: panicCall}}},
+	}, nil
+}
+
+// asmComment returns a comment with the assembly and constraints of the given
+// LLVM IR call instruction, if calling inline assembly; e.g.
+//
+//    // inline asm: "cpuid", "={ax},{ax}"
+//
+// A nil list of statements is returned if the callee is not inline assembly, or
+// if comments are not tracked.
+func (d *Decompiler) asmComment(inst interface{}) []ast.Stmt {
+	call, ok := inst.(*ir.InstCall)
+	if !ok {
+		return nil
+	}
+	asm, ok := call.Callee.(*ir.InlineAsm)
+	if !ok {
+		return nil
+	}
+	return d.commentStmts("inline asm: " + strconv.Quote(asm.Asm) + ", " + strconv.Quote(asm.Constraint))
+}
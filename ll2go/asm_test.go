@@ -0,0 +1,29 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestInlineAsm(t *testing.T) {
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	sig := types.NewFunc(types.I32, types.I32)
+	asm := ir.NewInlineAsm(types.NewPointer(sig), "bswap $0", "=r,r")
+	result := entry.NewCall(asm, x)
+	entry.NewRet(result)
+
+	d := withComments(NewDecompiler())
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	const want = "func f(x int32) int32 {\n\t// inline asm: \"bswap $0\", \"=r,r\"\n\t_0 := func(int32) int32 {\n\t\tpanic(\"inline asm not supported\")\n\t}(x)\n\treturn _0\n}"
+	if got := commentString(t, d, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
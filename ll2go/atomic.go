@@ -0,0 +1,223 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math/big"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// atomicSuffix returns the type suffix of the sync/atomic functions which
+// operate on values of the given LLVM IR type (e.g. "Int32" of AddInt32), and a
+// boolean indicating if such functions exist. The sync/atomic package only
+// supports 32- and 64-bit integers.
+func atomicSuffix(t types.Type) (string, bool) {
+	typ, ok := t.(*types.IntType)
+	if !ok || (typ.BitSize != 32 && typ.BitSize != 64) {
+		return "", false
+	}
+	return fmt.Sprintf("Int%d", typ.BitSize), true
+}
+
+// atomicCall returns a call to the given function of the sync/atomic package.
+func (d *Decompiler) atomicCall(name string, args ...ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  d.pkgSel("sync/atomic", name),
+		Args: args,
+	}
+}
+
+// instAtomicRMW converts the given LLVM IR atomicrmw instruction into a
+// corresponding list of Go statements, using the sync/atomic function of the
+// operation, which returns the old value stored at the address; e.g.
+//
+//    _0 := atomic.SwapInt32(p, x)       // atomicrmw xchg i32* p, i32 x
+//    _0 := atomic.AddInt32(p, x) - x    // atomicrmw add i32* p, i32 x
+//    _0 := atomic.OrInt32(p, x)         // atomicrmw or i32* p, i32 x
+//
+// Operations without a sync/atomic equivalent (xor, nand, max, min, umax and
+// umin) are lowered into a compare-and-swap loop.
+//
+//    var _0 int32
+//    for {
+//       _0 = atomic.LoadInt32(p)
+//       if atomic.CompareAndSwapInt32(p, _0, max(_0, x)) {
+//          break
+//       }
+//    }
+//
+// Operands of types unsupported by sync/atomic are read and written
+// non-atomically, which is noted by a comment.
+func (d *Decompiler) instAtomicRMW(inst *ir.InstAtomicRMW) ([]ast.Stmt, error) {
+	ops, err := d.values(inst.Dst, inst.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p, x := ops[0], ops[1]
+	old := d.local(inst.Name())
+	suffix, ok := atomicSuffix(inst.X.Type())
+	if !ok {
+		// Non-atomic read-modify-write.
+		dst, err := d.deref(inst.Dst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		val, err := d.atomicOp(inst.Op, old, inst.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		load := d.define(inst.Name(), d.commented(dst, "non-atomic"))
+		store := &ast.AssignStmt{
+			Lhs: []ast.Expr{dst},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{val},
+		}
+		return []ast.Stmt{load, store}, nil
+	}
+	switch inst.Op {
+	case enum.AtomicOpXChg:
+		return []ast.Stmt{d.define(inst.Name(), d.atomicCall("Swap"+suffix, p, x))}, nil
+	case enum.AtomicOpAdd:
+		// AddInt32 returns the new value.
+		expr := &ast.BinaryExpr{X: d.atomicCall("Add"+suffix, p, x), Op: token.SUB, Y: x}
+		return []ast.Stmt{d.define(inst.Name(), expr)}, nil
+	case enum.AtomicOpSub:
+		var neg ast.Expr = &ast.UnaryExpr{Op: token.SUB, X: x}
+		if c, ok := inst.X.(*constant.Int); ok {
+			neg = &ast.BasicLit{Kind: token.INT, Value: new(big.Int).Neg(c.X).String()}
+		}
+		expr := &ast.BinaryExpr{X: d.atomicCall("Add"+suffix, p, neg), Op: token.ADD, Y: x}
+		return []ast.Stmt{d.define(inst.Name(), expr)}, nil
+	case enum.AtomicOpAnd:
+		return []ast.Stmt{d.define(inst.Name(), d.atomicCall("And"+suffix, p, x))}, nil
+	case enum.AtomicOpOr:
+		return []ast.Stmt{d.define(inst.Name(), d.atomicCall("Or"+suffix, p, x))}, nil
+	}
+	// Compare-and-swap loop.
+	val, err := d.atomicOp(inst.Op, old, inst.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cas := d.atomicCall("CompareAndSwap"+suffix, p, old, val)
+	loop := &ast.ForStmt{
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			d.assign(inst.Name(), d.atomicCall("Load"+suffix, p)),
+			&ast.IfStmt{
+				Cond: cas,
+				Body: &ast.BlockStmt{List: []ast.Stmt{&ast.BranchStmt{Tok: token.BREAK}}},
+			},
+		}},
+	}
+	return []ast.Stmt{d.varDecl(inst.Name(), d.GoType(inst.X.Type())), loop}, nil
+}
+
+// atomicOp returns the Go expression of the new value of the given atomicrmw
+// operation, based on the old value and the operand x.
+func (d *Decompiler) atomicOp(op enum.AtomicOp, old ast.Expr, x value.Value) (ast.Expr, error) {
+	y, err := d.Value(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch op {
+	case enum.AtomicOpXChg:
+		return y, nil
+	case enum.AtomicOpAdd:
+		return &ast.BinaryExpr{X: old, Op: token.ADD, Y: y}, nil
+	case enum.AtomicOpSub:
+		return &ast.BinaryExpr{X: old, Op: token.SUB, Y: y}, nil
+	case enum.AtomicOpAnd:
+		return &ast.BinaryExpr{X: old, Op: token.AND, Y: y}, nil
+	case enum.AtomicOpNAnd:
+		return &ast.UnaryExpr{Op: token.XOR, X: &ast.ParenExpr{X: &ast.BinaryExpr{X: old, Op: token.AND, Y: y}}}, nil
+	case enum.AtomicOpOr:
+		return &ast.BinaryExpr{X: old, Op: token.OR, Y: y}, nil
+	case enum.AtomicOpXor:
+		return &ast.BinaryExpr{X: old, Op: token.XOR, Y: y}, nil
+	case enum.AtomicOpMax:
+		return &ast.CallExpr{Fun: ast.NewIdent("max"), Args: []ast.Expr{old, y}}, nil
+	case enum.AtomicOpMin:
+		return &ast.CallExpr{Fun: ast.NewIdent("min"), Args: []ast.Expr{old, y}}, nil
+	case enum.AtomicOpUMax, enum.AtomicOpUMin:
+		name := "max"
+		if op == enum.AtomicOpUMin {
+			name = "min"
+		}
+		ux, err := d.unsigned(x)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		typ, err := d.unsignedType(x.Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		call := &ast.CallExpr{Fun: ast.NewIdent(name), Args: []ast.Expr{d.conv(typ, old), ux}}
+		return d.conv(d.GoType(x.Type()), call), nil
+	default:
+		return nil, errors.Errorf("support for atomicrmw operation %v not yet implemented", op)
+	}
+}
+
+// instCmpXchg converts the given LLVM IR cmpxchg instruction into a
+// corresponding list of Go statements. The {old, success} struct of the
+// cmpxchg instruction is assigned the result of the sync/atomic
+// compare-and-swap, and the old value is loaded if the swap failed.
+//
+//    var _0 struct { Field0 int32; Field1 bool }
+//    _0.Field1 = atomic.CompareAndSwapInt32(p, cmp, new)
+//    _0.Field0 = cmp
+//    if !_0.Field1 {
+//       _0.Field0 = atomic.LoadInt32(p)
+//    }
+//
+// Operands of types unsupported by sync/atomic are compared and swapped
+// non-atomically, which is noted by a comment.
+func (d *Decompiler) instCmpXchg(inst *ir.InstCmpXchg) ([]ast.Stmt, error) {
+	ops, err := d.values(inst.Ptr, inst.Cmp, inst.New)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result := d.local(inst.Name())
+	oldField := &ast.SelectorExpr{X: result, Sel: fieldName(0)}
+	okField := &ast.SelectorExpr{X: result, Sel: fieldName(1)}
+	decl := d.varDecl(inst.Name(), d.GoType(inst.Type()))
+	p, cmp, x := ops[0], ops[1], ops[2]
+	suffix, ok := atomicSuffix(inst.Cmp.Type())
+	if !ok {
+		// Non-atomic compare-and-swap.
+		//
+		//    _0.Field0 = *p /* non-atomic */
+		//    _0.Field1 = _0.Field0 == cmp
+		//    if _0.Field1 {
+		//       *p = new
+		//    }
+		dst, err := d.deref(inst.Ptr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		load := &ast.AssignStmt{Lhs: []ast.Expr{oldField}, Tok: token.ASSIGN, Rhs: []ast.Expr{d.commented(dst, "non-atomic")}}
+		eq := &ast.AssignStmt{Lhs: []ast.Expr{okField}, Tok: token.ASSIGN, Rhs: []ast.Expr{&ast.BinaryExpr{X: oldField, Op: token.EQL, Y: cmp}}}
+		store := &ast.IfStmt{
+			Cond: okField,
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				&ast.AssignStmt{Lhs: []ast.Expr{dst}, Tok: token.ASSIGN, Rhs: []ast.Expr{x}},
+			}},
+		}
+		return []ast.Stmt{decl, load, eq, store}, nil
+	}
+	cas := &ast.AssignStmt{Lhs: []ast.Expr{okField}, Tok: token.ASSIGN, Rhs: []ast.Expr{d.atomicCall("CompareAndSwap"+suffix, p, cmp, x)}}
+	old := &ast.AssignStmt{Lhs: []ast.Expr{oldField}, Tok: token.ASSIGN, Rhs: []ast.Expr{cmp}}
+	load := &ast.IfStmt{
+		Cond: &ast.UnaryExpr{Op: token.NOT, X: okField},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.AssignStmt{Lhs: []ast.Expr{oldField}, Tok: token.ASSIGN, Rhs: []ast.Expr{d.atomicCall("Load"+suffix, p)}},
+		}},
+	}
+	return []ast.Stmt{decl, cas, old, load}, nil
+}
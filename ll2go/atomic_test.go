@@ -0,0 +1,92 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestAtomic(t *testing.T) {
+	m := ir.NewModule()
+	counter := m.NewGlobalDef("counter", constant.NewInt(types.I32, 0))
+
+	// int inc(void) {
+	//    return atomic_fetch_add(&counter, 1);
+	// }
+	inc := m.NewFunc("inc", types.I32)
+	entry := inc.NewBlock("entry")
+	entry.NewRet(entry.NewAtomicRMW(enum.AtomicOpAdd, counter, constant.NewInt(types.I32, 1), enum.AtomicOrderingSequentiallyConsistent))
+
+	// bool cas(long *p, long old, long new) {
+	//    return atomic_compare_exchange_strong(p, &old, new);
+	// }
+	p := ir.NewParam("p", types.NewPointer(types.I64))
+	old := ir.NewParam("old", types.I64)
+	nv := ir.NewParam("new", types.I64)
+	cas := m.NewFunc("cas", types.I1, p, old, nv)
+	entry = cas.NewBlock("entry")
+	pair := entry.NewCmpXchg(p, old, nv, enum.AtomicOrderingSequentiallyConsistent, enum.AtomicOrderingSequentiallyConsistent)
+	entry.NewRet(entry.NewExtractValue(pair, 1))
+
+	// int fetch_max(int x) {
+	//    return atomic_fetch_max(&counter, x);
+	// }
+	x := ir.NewParam("x", types.I32)
+	fetchMax := m.NewFunc("fetch_max", types.I32, x)
+	entry = fetchMax.NewBlock("entry")
+	entry.NewRet(entry.NewAtomicRMW(enum.AtomicOpMax, counter, x, enum.AtomicOrderingSequentiallyConsistent))
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `package main
+
+import "sync/atomic"
+
+var counter int32 = 0
+
+func inc() int32 {
+	_0 := atomic.AddInt32(&counter, 1) - 1
+	return _0
+}
+func cas(p *int64, old int64, _new int64) bool {
+	var _0 struct {
+		Field0 int64
+		Field1 bool
+	}
+	_0.Field1 = atomic.CompareAndSwapInt64(p, old, _new)
+	_0.Field0 = old
+	if !_0.Field1 {
+		_0.Field0 = atomic.LoadInt64(p)
+	}
+	_1 := _0.Field1
+	return _1
+}
+func fetch_max(x int32) int32 {
+	var _0 int32
+	for {
+		_0 = atomic.LoadInt32(&counter)
+		if atomic.CompareAndSwapInt32(&counter, _0, max(_0, x)) {
+			break
+		}
+	}
+	return _0
+}
+`
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("atomic.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
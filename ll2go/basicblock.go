@@ -0,0 +1,88 @@
+package ll2go
+
+import (
+	"go/ast"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// basicBlock represents a conceptual basic block, that may contain both LLVM IR
+// instructions and Go statements.
+type basicBlock struct {
+	*ir.Block
+	// Go statements of the basic block; e.g. the result of merging basic blocks
+	// into control flow primitives.
+	stmts []ast.Stmt
+	// Incoming values of PHI instructions of the basic block copied through
+	// temporaries (see edgePhis). In other words, a list of assignment
+	// statements to appear at the start of the basic block.
+	in []ast.Stmt
+	// Outgoing values for PHI instructions. In other words, a list of assignment
+	// statements to appear at the end of the basic block.
+	out []ast.Stmt
+	// Outgoing values for PHI instructions to appear directly after a given
+	// instruction of the basic block; mapping from LLVM IR instruction to
+	// assignment statements.
+	after map[ir.Instruction][]ast.Stmt
+}
+
+// addOut adds the given outgoing PHI assignment to the basic block, assigning x
+// to the variable of the PHI instruction. The assignment is placed directly
+// after the definition of x, if defined in the basic block, but no earlier than
+// the last use of the PHI variable; otherwise, it is placed at the end of the
+// basic block.
+//
+// The PHI variable is required to have no later use in the terminator of the
+// basic block nor in any successor basic block; the incoming values of such PHI
+// instructions are copied through temporaries instead (see edgePhis).
+func (block *basicBlock) addOut(phi *ir.InstPhi, x value.Value, stmt ast.Stmt) {
+	pos := -1
+	for i, inst := range block.Insts {
+		if v, ok := inst.(value.Value); ok && v == x {
+			pos = i
+		}
+		// The incoming values of PHI instructions are not used within the basic
+		// block.
+		if _, ok := inst.(*ir.InstPhi); !ok && uses(operands(inst), phi) {
+			pos = i
+		}
+	}
+	if pos == -1 {
+		block.out = append(block.out, stmt)
+		return
+	}
+	if block.after == nil {
+		block.after = make(map[ir.Instruction][]ast.Stmt)
+	}
+	inst := block.Insts[pos]
+	block.after[inst] = append(block.after[inst], stmt)
+}
+
+// stmts returns the Go statements of the given basic block; i.e. the Go
+// statements corresponding to the LLVM IR instructions of the basic block,
+// followed by its Go statements and outgoing PHI assignments.
+func (d *Decompiler) stmts(block *basicBlock) ([]ast.Stmt, error) {
+	stmts, err := d.body(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(stmts, block.out...), nil
+}
+
+// body returns the Go statements of the given basic block, excluding the
+// outgoing PHI assignments at the end of the basic block.
+func (d *Decompiler) body(block *basicBlock) ([]ast.Stmt, error) {
+	stmts := append([]ast.Stmt(nil), block.in...)
+	for _, inst := range block.Insts {
+		instStmts, err := d.instStmts(inst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		stmts = append(stmts, instStmts...)
+		stmts = append(stmts, block.after[inst]...)
+	}
+	stmts = append(stmts, block.stmts...)
+	return stmts, nil
+}
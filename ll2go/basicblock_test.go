@@ -0,0 +1,64 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestPhiOutOrder(t *testing.T) {
+	// The incoming value of the PHI instruction is computed in the predecessor
+	// basic block, which also uses the PHI variable.
+	//
+	//    int f() {
+	//       int i = 0;
+	//       do {
+	//          i++;
+	//       } while (i < 10);
+	//       return i;
+	//    }
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.I32)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	next := loop.NewAdd(i, constant.NewInt(types.I32, 1))
+	next.SetName("next")
+	i.Incs = append(i.Incs, ir.NewIncoming(next, loop))
+	cond := loop.NewICmp(enum.IPredSLT, next, constant.NewInt(types.I32, 10))
+	loop.NewCondBr(cond, loop, exit)
+	exit.NewRet(next)
+
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f() int32 {
+	var i int32
+	var next int32
+	var _0 bool
+	i = 0
+	goto block_loop
+block_loop:
+	next = i + 1
+	i = next
+	_0 = next < 10
+	if _0 {
+		goto block_loop
+	} else {
+		goto block_exit
+	}
+block_exit:
+	return next
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,837 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fset is the file set of the Go source files returned by Decompile.
+var fset = token.NewFileSet()
+
+// FileSet returns the file set of the source positions of the Go source files
+// returned by Decompile, which position the comments of the Go source files;
+// thus the Go source files should be printed using the file set; e.g.
+//
+//    format.Node(w, ll2go.FileSet(), file)
+func FileSet() *token.FileSet {
+	return fset
+}
+
+// lineFiles are the files of the file set of FileSet, which hold the lines of
+// the Go source files returned by Decompile; mapping from line length to file
+// of lines of the length.
+//
+// The source positions of distinct Go source files need not be distinct; thus
+// the files are shared by the Go source files, and the file set only grows when
+// a Go source file has more or longer lines than any previous one.
+var lineFiles = struct {
+	sync.Mutex
+	files map[int]*token.File
+}{files: make(map[int]*token.File)}
+
+// lineFile returns a file of the file set of FileSet, of at least the given
+// number of lines, each of the same length of at least the given length. The
+// line length of the file is returned.
+func lineFile(lines, length int) (*token.File, int) {
+	size := 1 << 8
+	for size < length {
+		size <<= 1
+	}
+	lineFiles.Lock()
+	defer lineFiles.Unlock()
+	f := lineFiles.files[size]
+	if f != nil && f.LineCount() >= lines {
+		return f, size
+	}
+	n := 1 << 10
+	if f != nil {
+		n = 2 * f.LineCount()
+	}
+	for n < lines {
+		n <<= 1
+	}
+	f = fset.AddFile("", -1, n*size)
+	offsets := make([]int, n)
+	for i := range offsets {
+		offsets[i] = i * size
+	}
+	f.SetLines(offsets)
+	lineFiles.files[size] = f
+	return f, size
+}
+
+// A commentSet keeps track of the comments of the Go nodes decompiled from a
+// module, so that the comments may be recorded in the Go source file (see
+// ast.File.Comments).
+//
+// The decompiled Go nodes have no source positions to associate comments with;
+// thus the comments are associated with Go nodes, and positioned by laying out
+// the Go nodes as printed by go/printer (see layout).
+//
+// A commentSet may be used concurrently by multiple goroutines.
+type commentSet struct {
+	sync.Mutex
+	// Comments of Go nodes; mapping from Go expression to the text of its
+	// trailing block comments, and from empty Go statement to the text of its
+	// line comment.
+	texts map[ast.Node][]string
+}
+
+// newCommentSet returns a new comment set.
+func newCommentSet() *commentSet {
+	return &commentSet{
+		texts: make(map[ast.Node][]string),
+	}
+}
+
+// commented returns the given expression, followed by the given comment in the
+// Go source file; e.g. `*p /* volatile */`. The comment is recorded in the
+// comment set of the decompiler, if comments are tracked.
+func (d *Decompiler) commented(expr ast.Expr, comment string) ast.Expr {
+	if d.comments != nil {
+		d.comments.add(expr, comment)
+	}
+	return expr
+}
+
+// commentStmts returns a list of empty statements, which anchor the line
+// comments of the given lines of text in the Go source file; e.g.
+//
+//    // phi: x (block_1)
+//
+// A nil list of statements is returned if comments are not tracked.
+func (d *Decompiler) commentStmts(text string) []ast.Stmt {
+	if d.comments == nil {
+		return nil
+	}
+	var stmts []ast.Stmt
+	for _, line := range strings.Split(text, "\n") {
+		stmt := &ast.EmptyStmt{Implicit: true}
+		d.comments.add(stmt, strings.TrimSpace(line))
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// add records the given comment of the given Go node in the comment set.
+func (s *commentSet) add(node ast.Node, comment string) {
+	s.Lock()
+	s.texts[node] = append(s.texts[node], comment)
+	s.Unlock()
+}
+
+// lookup returns the comments of the given Go node.
+func (s *commentSet) lookup(node ast.Node) []string {
+	s.Lock()
+	defer s.Unlock()
+	return s.texts[node]
+}
+
+// file records the comments of the comment set in the given Go source file,
+// positioning the Go nodes of the file as laid out by go/printer; the source
+// positions of the file are recorded in the file set of FileSet.
+func (s *commentSet) file(file *ast.File) {
+	l := newLayout(s)
+	l.file(file)
+	file.Comments = l.resolve()
+}
+
+// nodeType is the type of Go nodes.
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// copyNode returns a deep copy of the given Go node, with the comments of the
+// original Go nodes.
+func (s *commentSet) copyNode(node ast.Node) ast.Node {
+	s.Lock()
+	defer s.Unlock()
+	return s.copyValue(reflect.ValueOf(node)).Interface().(ast.Node)
+}
+
+// copyValue returns a deep copy of the Go nodes of the given value.
+func (s *commentSet) copyValue(v reflect.Value) reflect.Value {
+	if !v.Type().Implements(nodeType) && !(v.Kind() == reflect.Slice && v.Type().Elem().Implements(nodeType)) {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(s.copyValue(v.Elem()))
+		return c
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(v.Elem())
+		for i := 0; i < c.Elem().NumField(); i++ {
+			if field := c.Elem().Field(i); field.CanSet() {
+				field.Set(s.copyValue(field))
+			}
+		}
+		if texts, ok := s.texts[v.Interface().(ast.Node)]; ok {
+			s.texts[c.Interface().(ast.Node)] = texts
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(s.copyValue(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
+
+// tokenGap is the minimum distance between the columns of consecutive tokens
+// of a layout, which leaves room for the tokens without source positions (e.g.
+// commas and blanks) printed between them.
+const tokenGap = 16
+
+// A layout positions the Go nodes of a Go source file, and the comments of the
+// Go nodes, on the lines of the Go source code printed by go/printer for Go
+// nodes without source positions; thus go/printer prints the positioned Go
+// nodes as before, with their comments interspersed.
+//
+// Lines are laid out as printed; each statement, declaration, field and closing
+// brace of a block starts on a new line, and line comments are on lines of
+// their own, before the Go statements they are anchored by. Trailing block
+// comments follow their Go expressions on the same line. Columns need only be
+// ordered, and are spaced apart by at least the length of the tokens.
+//
+// Go nodes shared between parents are copied, as the source positions of the
+// Go nodes differ between parents.
+type layout struct {
+	// Comments of the Go nodes.
+	comments *commentSet
+	// Current line (1-based) and column (0-based) of the layout.
+	line, col int
+	// Indentation of the current line.
+	indent int
+	// Maximum column of the layout.
+	maxCol int
+	// Source positions of the layout, to be resolved in a file of the lines of
+	// the layout.
+	refs []posRef
+	// Comment groups of the layout.
+	groups []*ast.CommentGroup
+	// Comment group of the preceding comment, or nil if a token follows it.
+	group *ast.CommentGroup
+	// Line of the last token, and of the last comment.
+	tokLine, commentLine int
+	// Laid out Go nodes.
+	seen map[ast.Node]bool
+}
+
+// A posRef is a source position of a layout, specified by line and column.
+type posRef struct {
+	// Source position of the layout.
+	pos *token.Pos
+	// Line (1-based) and column (0-based) of the source position.
+	line, col int
+}
+
+// newLayout returns a new layout of the Go nodes of the given comment set.
+func newLayout(s *commentSet) *layout {
+	return &layout{
+		comments: s,
+		line:     1,
+		seen:     make(map[ast.Node]bool),
+	}
+}
+
+// resolve resolves the source positions of the layout in a file of the file set
+// of FileSet, and returns the comment groups of the layout.
+func (l *layout) resolve() []*ast.CommentGroup {
+	f, length := lineFile(l.line, l.maxCol+1)
+	for _, ref := range l.refs {
+		*ref.pos = token.Pos(f.Base() + (ref.line-1)*length + ref.col)
+	}
+	return l.groups
+}
+
+// newline starts a new line of the layout.
+func (l *layout) newline() {
+	l.line++
+	l.col = l.indent
+}
+
+// at positions the given source position at the current column of the layout,
+// and advances the column by n.
+func (l *layout) at(pos *token.Pos, n int) {
+	l.refs = append(l.refs, posRef{pos: pos, line: l.line, col: l.col})
+	l.col += n + tokenGap
+	if l.col > l.maxCol {
+		l.maxCol = l.col
+	}
+}
+
+// tok positions the given source position of a token of the given text.
+func (l *layout) tok(pos *token.Pos, text string) {
+	l.at(pos, len(text))
+	l.tokLine = l.line
+	l.group = nil
+}
+
+// optTok positions the given source position of an optional token of the given
+// text, if present; i.e. if valid.
+func (l *layout) optTok(pos *token.Pos, text string) {
+	if pos.IsValid() {
+		l.tok(pos, text)
+	}
+}
+
+// comment adds a comment of the given text at the current column of the
+// layout. Comments are grouped as by go/parser; adjacent comments form a group,
+// except for comments following a token on the same line, which form a group
+// of their own.
+func (l *layout) comment(text string) {
+	c := &ast.Comment{Text: text}
+	g := l.group
+	switch {
+	case g == nil:
+		// token before comment.
+	case l.commentLine == l.tokLine && l.line != l.commentLine:
+		// comment following comments on the line of the last token.
+		g = nil
+	case l.line > l.commentLine+1:
+		// comment separated by a blank line.
+		g = nil
+	}
+	if g == nil {
+		g = &ast.CommentGroup{}
+		l.groups = append(l.groups, g)
+	}
+	g.List = append(g.List, c)
+	l.at(&c.Slash, len(text))
+	l.group = g
+	l.commentLine = l.line
+}
+
+// doc adds the given doc comment on lines of its own, before the line of the
+// commented declaration.
+func (l *layout) doc(doc *ast.CommentGroup) {
+	l.groups = append(l.groups, doc)
+	for _, c := range doc.List {
+		l.at(&c.Slash, len(c.Text))
+		l.newline()
+	}
+	l.group = nil
+}
+
+// fresh returns the given Go node, or a copy of it if already laid out.
+func (l *layout) fresh(node ast.Node) ast.Node {
+	if l.seen[node] {
+		node = l.comments.copyNode(node)
+	}
+	l.seen[node] = true
+	return node
+}
+
+// file lays out the given Go source file.
+func (l *layout) file(file *ast.File) {
+	l.tok(&file.Package, "package")
+	file.Name = l.ident(file.Name)
+	tok := token.ILLEGAL
+	for i, decl := range file.Decls {
+		// Declarations are separated by blank lines, except for consecutive
+		// declarations of the same kind without doc comments.
+		prev := tok
+		tok = token.FUNC
+		var doc *ast.CommentGroup
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			tok, doc = decl.Tok, decl.Doc
+		case *ast.FuncDecl:
+			doc = decl.Doc
+		}
+		if prev != tok || doc != nil {
+			l.newline()
+		}
+		l.newline()
+		if doc != nil {
+			l.doc(doc)
+		}
+		file.Decls[i] = l.decl(decl)
+	}
+}
+
+// decl lays out the given Go declaration.
+func (l *layout) decl(decl ast.Decl) ast.Decl {
+	switch d := l.fresh(decl).(type) {
+	case *ast.GenDecl:
+		l.tok(&d.TokPos, d.Tok.String())
+		if !d.Lparen.IsValid() && len(d.Specs) == 1 {
+			d.Specs[0] = l.spec(d.Specs[0])
+			return d
+		}
+		l.tok(&d.Lparen, "(")
+		if len(d.Specs) > 0 {
+			l.indent++
+			for i, spec := range d.Specs {
+				l.newline()
+				d.Specs[i] = l.spec(spec)
+			}
+			l.indent--
+			l.newline()
+		}
+		l.tok(&d.Rparen, ")")
+		return d
+	case *ast.FuncDecl:
+		d.Type = l.fresh(d.Type).(*ast.FuncType)
+		l.tok(&d.Type.Func, "func")
+		if d.Recv != nil {
+			d.Recv = l.fieldList(d.Recv, "(", ")")
+		}
+		d.Name = l.ident(d.Name)
+		l.signature(d.Type)
+		if d.Body != nil {
+			d.Body = l.block(d.Body, 1)
+		}
+		return d
+	default:
+		return d.(ast.Decl)
+	}
+}
+
+// spec lays out the given Go specification.
+func (l *layout) spec(spec ast.Spec) ast.Spec {
+	switch s := l.fresh(spec).(type) {
+	case *ast.ImportSpec:
+		if s.Name != nil {
+			s.Name = l.ident(s.Name)
+		}
+		s.Path = l.expr(s.Path).(*ast.BasicLit)
+		return s
+	case *ast.ValueSpec:
+		s.Names = l.idents(s.Names)
+		s.Type = l.expr(s.Type)
+		s.Values = l.exprs(s.Values)
+		return s
+	case *ast.TypeSpec:
+		s.Name = l.ident(s.Name)
+		l.optTok(&s.Assign, "=")
+		s.Type = l.expr(s.Type)
+		return s
+	default:
+		return s.(ast.Spec)
+	}
+}
+
+// signature lays out the parameters and results of the given Go function type.
+func (l *layout) signature(typ *ast.FuncType) {
+	typ.Params = l.fieldList(typ.Params, "(", ")")
+	if typ.Results != nil {
+		typ.Results = l.fieldList(typ.Results, "(", ")")
+	}
+}
+
+// fieldList lays out the given list of Go fields on the current line, enclosed
+// by the given opening and closing tokens.
+func (l *layout) fieldList(fields *ast.FieldList, opening, closing string) *ast.FieldList {
+	fields = l.fresh(fields).(*ast.FieldList)
+	l.tok(&fields.Opening, opening)
+	fields.List = l.fields(fields.List, false)
+	l.tok(&fields.Closing, closing)
+	return fields
+}
+
+// fieldBlock lays out the given list of Go fields of a struct or interface
+// type, with each field on a line of its own.
+func (l *layout) fieldBlock(fields *ast.FieldList) *ast.FieldList {
+	fields = l.fresh(fields).(*ast.FieldList)
+	l.tok(&fields.Opening, "{")
+	l.indent++
+	fields.List = l.fields(fields.List, true)
+	l.indent--
+	l.newline()
+	l.tok(&fields.Closing, "}")
+	return fields
+}
+
+// fields lays out the given Go fields, with each field on a line of its own if
+// lines is set.
+func (l *layout) fields(list []*ast.Field, lines bool) []*ast.Field {
+	var out []*ast.Field
+	for i, field := range list {
+		if lines {
+			l.newline()
+		}
+		f := l.fresh(field).(*ast.Field)
+		f.Names = l.idents(f.Names)
+		f.Type = l.expr(f.Type)
+		if f.Tag != nil {
+			f.Tag = l.expr(f.Tag).(*ast.BasicLit)
+		}
+		if f != field && out == nil {
+			out = append([]*ast.Field(nil), list...)
+		}
+		if out != nil {
+			out[i] = f
+		}
+	}
+	if out != nil {
+		return out
+	}
+	return list
+}
+
+// block lays out the given Go block statement, with its statements indented by
+// the given number of levels.
+func (l *layout) block(block *ast.BlockStmt, nindent int) *ast.BlockStmt {
+	b := l.fresh(block).(*ast.BlockStmt)
+	l.tok(&b.Lbrace, "{")
+	b.List = l.stmtList(b.List, nindent, true)
+	l.newline()
+	l.tok(&b.Rbrace, "}")
+	return b
+}
+
+// stmtList lays out the given list of Go statements, indented by the given
+// number of levels, with each statement on a line of its own; nextIsRBrace
+// specifies whether the list is the last of a block.
+//
+// go/printer omits the empty statements of statement lists; thus the empty
+// statements are dropped from the returned list, after laying out the line
+// comments they anchor.
+func (l *layout) stmtList(list []ast.Stmt, nindent int, nextIsRBrace bool) []ast.Stmt {
+	l.indent += nindent
+	last := -1
+	for i, stmt := range list {
+		if _, ok := stmt.(*ast.EmptyStmt); !ok {
+			last = i
+		}
+	}
+	var stmts []ast.Stmt
+	for i := 0; i < len(list); i++ {
+		if e, ok := list[i].(*ast.EmptyStmt); ok {
+			l.emptyStmt(e, false)
+			continue
+		}
+		l.newline()
+		if s, ok := list[i].(*ast.LabeledStmt); ok && l.isAnchor(s.Stmt) && i < last {
+			// go/printer prints labeled empty statements as semicolons; thus
+			// labeled comment anchors are dropped, and their labels label the
+			// following statement.
+			s = l.fresh(s).(*ast.LabeledStmt)
+			l.label(s)
+			l.emptyStmt(s.Stmt.(*ast.EmptyStmt), false)
+			for i++; i < last; i++ {
+				e, ok := list[i].(*ast.EmptyStmt)
+				if !ok {
+					break
+				}
+				l.emptyStmt(e, false)
+			}
+			l.newline()
+			s.Stmt = l.stmt(list[i], nextIsRBrace && i == last)
+			stmts = append(stmts, s)
+			continue
+		}
+		stmts = append(stmts, l.stmt(list[i], nextIsRBrace && i == last))
+	}
+	l.indent -= nindent
+	return stmts
+}
+
+// isAnchor reports whether the given Go statement is an empty statement which
+// anchors line comments.
+func (l *layout) isAnchor(stmt ast.Stmt) bool {
+	e, ok := stmt.(*ast.EmptyStmt)
+	return ok && len(l.comments.lookup(e)) > 0
+}
+
+// label lays out the label of the given labeled Go statement.
+func (l *layout) label(stmt *ast.LabeledStmt) {
+	// Labels are unindented.
+	l.col = l.indent - 1
+	stmt.Label = l.ident(stmt.Label)
+	l.tok(&stmt.Colon, ":")
+}
+
+// emptyStmt lays out the line comments of the given empty Go statement, each on
+// a line of its own, followed by a semicolon on a line of its own if semicolon
+// is set.
+func (l *layout) emptyStmt(stmt *ast.EmptyStmt, semicolon bool) *ast.EmptyStmt {
+	s := l.fresh(stmt).(*ast.EmptyStmt)
+	for _, text := range l.comments.lookup(s) {
+		l.newline()
+		l.comment("// " + text)
+	}
+	if semicolon {
+		l.newline()
+		l.tok(&s.Semicolon, ";")
+	} else {
+		l.at(&s.Semicolon, 0)
+	}
+	return s
+}
+
+// stmt lays out the given Go statement; nextIsRBrace specifies whether the
+// statement is the last statement of a block.
+func (l *layout) stmt(stmt ast.Stmt, nextIsRBrace bool) ast.Stmt {
+	if stmt == nil {
+		return nil
+	}
+	if e, ok := stmt.(*ast.EmptyStmt); ok {
+		return l.emptyStmt(e, false)
+	}
+	switch s := l.fresh(stmt).(type) {
+	case *ast.DeclStmt:
+		s.Decl = l.decl(s.Decl)
+		return s
+	case *ast.LabeledStmt:
+		l.label(s)
+		if e, ok := s.Stmt.(*ast.EmptyStmt); ok {
+			// Empty statements are printed as semicolons, unless last of a
+			// block.
+			s.Stmt = l.emptyStmt(e, !nextIsRBrace)
+			return s
+		}
+		l.newline()
+		s.Stmt = l.stmt(s.Stmt, nextIsRBrace)
+		return s
+	case *ast.ExprStmt:
+		s.X = l.expr(s.X)
+		return s
+	case *ast.SendStmt:
+		s.Chan = l.expr(s.Chan)
+		l.tok(&s.Arrow, "<-")
+		s.Value = l.expr(s.Value)
+		return s
+	case *ast.IncDecStmt:
+		s.X = l.expr(s.X)
+		l.tok(&s.TokPos, s.Tok.String())
+		return s
+	case *ast.AssignStmt:
+		s.Lhs = l.exprs(s.Lhs)
+		l.tok(&s.TokPos, s.Tok.String())
+		s.Rhs = l.exprs(s.Rhs)
+		return s
+	case *ast.GoStmt:
+		l.tok(&s.Go, "go")
+		s.Call = l.expr(s.Call).(*ast.CallExpr)
+		return s
+	case *ast.DeferStmt:
+		l.tok(&s.Defer, "defer")
+		s.Call = l.expr(s.Call).(*ast.CallExpr)
+		return s
+	case *ast.ReturnStmt:
+		l.tok(&s.Return, "return")
+		s.Results = l.exprs(s.Results)
+		return s
+	case *ast.BranchStmt:
+		l.tok(&s.TokPos, s.Tok.String())
+		if s.Label != nil {
+			s.Label = l.ident(s.Label)
+		}
+		return s
+	case *ast.BlockStmt:
+		return l.block(s, 1)
+	case *ast.IfStmt:
+		l.tok(&s.If, "if")
+		s.Init = l.stmt(s.Init, false)
+		s.Cond = l.expr(s.Cond)
+		s.Body = l.block(s.Body, 1)
+		// The else branch follows the closing brace on the same line.
+		s.Else = l.stmt(s.Else, nextIsRBrace)
+		return s
+	case *ast.CaseClause:
+		l.tok(&s.Case, "case")
+		s.List = l.exprs(s.List)
+		l.tok(&s.Colon, ":")
+		s.Body = l.stmtList(s.Body, 1, nextIsRBrace)
+		return s
+	case *ast.SwitchStmt:
+		l.tok(&s.Switch, "switch")
+		s.Init = l.stmt(s.Init, false)
+		s.Tag = l.expr(s.Tag)
+		s.Body = l.block(s.Body, 0)
+		return s
+	case *ast.TypeSwitchStmt:
+		l.tok(&s.Switch, "switch")
+		s.Init = l.stmt(s.Init, false)
+		s.Assign = l.stmt(s.Assign, false)
+		s.Body = l.block(s.Body, 0)
+		return s
+	case *ast.CommClause:
+		l.tok(&s.Case, "case")
+		s.Comm = l.stmt(s.Comm, false)
+		l.tok(&s.Colon, ":")
+		s.Body = l.stmtList(s.Body, 1, nextIsRBrace)
+		return s
+	case *ast.SelectStmt:
+		l.tok(&s.Select, "select")
+		if len(s.Body.List) == 0 {
+			// Empty select statements are printed on one line.
+			s.Body = l.fresh(s.Body).(*ast.BlockStmt)
+			l.tok(&s.Body.Lbrace, "{")
+			l.tok(&s.Body.Rbrace, "}")
+			return s
+		}
+		s.Body = l.block(s.Body, 0)
+		return s
+	case *ast.ForStmt:
+		l.tok(&s.For, "for")
+		s.Init = l.stmt(s.Init, false)
+		s.Cond = l.expr(s.Cond)
+		s.Post = l.stmt(s.Post, false)
+		s.Body = l.block(s.Body, 1)
+		return s
+	case *ast.RangeStmt:
+		l.tok(&s.For, "for")
+		s.Key = l.expr(s.Key)
+		s.Value = l.expr(s.Value)
+		l.optTok(&s.TokPos, s.Tok.String())
+		s.X = l.expr(s.X)
+		s.Body = l.block(s.Body, 1)
+		return s
+	default:
+		return s.(ast.Stmt)
+	}
+}
+
+// ident lays out the given Go identifier.
+func (l *layout) ident(ident *ast.Ident) *ast.Ident {
+	return l.expr(ident).(*ast.Ident)
+}
+
+// idents lays out the given Go identifiers.
+func (l *layout) idents(list []*ast.Ident) []*ast.Ident {
+	var out []*ast.Ident
+	for i, ident := range list {
+		id := l.ident(ident)
+		if id != ident && out == nil {
+			out = append([]*ast.Ident(nil), list...)
+		}
+		if out != nil {
+			out[i] = id
+		}
+	}
+	if out != nil {
+		return out
+	}
+	return list
+}
+
+// exprs lays out the given Go expressions.
+func (l *layout) exprs(list []ast.Expr) []ast.Expr {
+	var out []ast.Expr
+	for i, expr := range list {
+		x := l.expr(expr)
+		if x != expr && out == nil {
+			out = append([]ast.Expr(nil), list...)
+		}
+		if out != nil {
+			out[i] = x
+		}
+	}
+	if out != nil {
+		return out
+	}
+	return list
+}
+
+// expr lays out the given Go expression, followed by its trailing block
+// comments.
+func (l *layout) expr(expr ast.Expr) ast.Expr {
+	if expr == nil || reflect.ValueOf(expr).IsNil() {
+		return expr
+	}
+	x := l.fresh(expr).(ast.Expr)
+	switch x := x.(type) {
+	case *ast.Ident:
+		l.tok(&x.NamePos, x.Name)
+	case *ast.BasicLit:
+		l.tok(&x.ValuePos, x.Value)
+	case *ast.Ellipsis:
+		l.tok(&x.Ellipsis, "...")
+		x.Elt = l.expr(x.Elt)
+	case *ast.FuncLit:
+		x.Type = l.expr(x.Type).(*ast.FuncType)
+		x.Body = l.block(x.Body, 1)
+	case *ast.CompositeLit:
+		x.Type = l.expr(x.Type)
+		l.tok(&x.Lbrace, "{")
+		x.Elts = l.exprs(x.Elts)
+		l.tok(&x.Rbrace, "}")
+	case *ast.ParenExpr:
+		l.tok(&x.Lparen, "(")
+		x.X = l.expr(x.X)
+		l.tok(&x.Rparen, ")")
+	case *ast.SelectorExpr:
+		x.X = l.expr(x.X)
+		x.Sel = l.ident(x.Sel)
+	case *ast.IndexExpr:
+		x.X = l.expr(x.X)
+		l.tok(&x.Lbrack, "[")
+		x.Index = l.expr(x.Index)
+		l.tok(&x.Rbrack, "]")
+	case *ast.SliceExpr:
+		x.X = l.expr(x.X)
+		l.tok(&x.Lbrack, "[")
+		x.Low = l.expr(x.Low)
+		x.High = l.expr(x.High)
+		x.Max = l.expr(x.Max)
+		l.tok(&x.Rbrack, "]")
+	case *ast.TypeAssertExpr:
+		x.X = l.expr(x.X)
+		l.tok(&x.Lparen, "(")
+		x.Type = l.expr(x.Type)
+		l.tok(&x.Rparen, ")")
+	case *ast.CallExpr:
+		x.Fun = l.expr(x.Fun)
+		l.tok(&x.Lparen, "(")
+		x.Args = l.exprs(x.Args)
+		l.optTok(&x.Ellipsis, "...")
+		l.tok(&x.Rparen, ")")
+	case *ast.StarExpr:
+		l.tok(&x.Star, "*")
+		x.X = l.expr(x.X)
+	case *ast.UnaryExpr:
+		l.tok(&x.OpPos, x.Op.String())
+		x.X = l.expr(x.X)
+	case *ast.BinaryExpr:
+		x.X = l.expr(x.X)
+		l.tok(&x.OpPos, x.Op.String())
+		x.Y = l.expr(x.Y)
+	case *ast.KeyValueExpr:
+		x.Key = l.expr(x.Key)
+		l.tok(&x.Colon, ":")
+		x.Value = l.expr(x.Value)
+	case *ast.ArrayType:
+		l.tok(&x.Lbrack, "[")
+		x.Len = l.expr(x.Len)
+		x.Elt = l.expr(x.Elt)
+	case *ast.StructType:
+		l.tok(&x.Struct, "struct")
+		x.Fields = l.fieldBlock(x.Fields)
+	case *ast.FuncType:
+		l.tok(&x.Func, "func")
+		l.signature(x)
+	case *ast.InterfaceType:
+		l.tok(&x.Interface, "interface")
+		x.Methods = l.fieldBlock(x.Methods)
+	case *ast.MapType:
+		l.tok(&x.Map, "map")
+		x.Key = l.expr(x.Key)
+		x.Value = l.expr(x.Value)
+	case *ast.ChanType:
+		l.tok(&x.Begin, "chan")
+		l.optTok(&x.Arrow, "<-")
+		x.Value = l.expr(x.Value)
+	}
+	for _, text := range l.comments.lookup(x) {
+		// Block comments end at the first "*/".
+		l.comment("/* " + strings.Replace(text, "*/", "* /", -1) + " */")
+	}
+	return x
+}
@@ -0,0 +1,90 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestCommented(t *testing.T) {
+	d := withComments(NewDecompiler())
+	golden := []struct {
+		comment string
+		want    string
+	}{
+		{comment: "volatile", want: "*p /* volatile */"},
+		// Block comments end at the first "*/".
+		{comment: "a */ b", want: "*p /* a * / b */"},
+	}
+	for _, g := range golden {
+		expr := &ast.StarExpr{X: ast.NewIdent("p")}
+		if got := d.commented(expr, g.comment); got != expr {
+			t.Errorf("%q: commented expression mismatch; expected %p, got %p", g.comment, expr, got)
+		}
+		if got := commentString(t, d, expr); got != g.want {
+			t.Errorf("%q: expression mismatch; expected %q, got %q", g.comment, g.want, got)
+		}
+	}
+}
+
+func TestDecompileComments(t *testing.T) {
+	// Tail-recursive factorial, with IR comments between the self-call and the
+	// return of its result.
+	m := ir.NewModule()
+	n := ir.NewParam("n", types.I32)
+	acc := ir.NewParam("acc", types.I32)
+	f := m.NewFunc("fact", types.I32, n, acc)
+	entry := f.NewBlock("entry")
+	base := f.NewBlock("base")
+	rec := f.NewBlock("rec")
+	cond := entry.NewICmp(enum.IPredSLE, n, constant.NewInt(types.I32, 1))
+	entry.NewCondBr(cond, base, rec)
+	base.NewRet(acc)
+	n1 := rec.NewSub(n, constant.NewInt(types.I32, 1))
+	acc1 := rec.NewMul(acc, n)
+	result := rec.NewCall(f, n1, acc1)
+	rec.NewRet(result)
+	d := NewDecompiler()
+	d.IRComments = true
+	d.TailCalls = true
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	if len(file.Comments) == 0 {
+		t.Errorf("expected comment groups in decompiled Go source file")
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	want := "package main\n\nfunc fact(n int32, acc int32) int32 {\n\tfor {\n\t\t// %0 = icmp sle i32 %n, 1\n\t\t_0 := n <= 1\n\t\tif _0 {\n\t\t\t// ret i32 %acc\n\t\t\treturn acc\n\t\t}\n\t\t// %1 = sub i32 %n, 1\n\t\t_1 := n - 1\n\t\t// %2 = mul i32 %acc, %n\n\t\t_2 := acc * n\n\t\t// %3 = call i32 @fact(i32 %1, i32 %2)\n\t\tn, acc = _1, _2\n\t\tcontinue\n\t}\n}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestDecompileFileSet(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.Void)
+	f.NewBlock("entry").NewRet(nil)
+	d := NewDecompiler()
+	d.IRComments = true
+	var bases []int
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decompile(m, nil); err != nil {
+			t.Fatalf("unable to decompile module; %v", err)
+		}
+		bases = append(bases, FileSet().Base())
+	}
+	// The file set is shared by Go source files of equal layout size.
+	if bases[0] != bases[1] {
+		t.Errorf("file set base mismatch; expected %d, got %d", bases[0], bases[1])
+	}
+}
@@ -0,0 +1,876 @@
+// Package ll2go implements decompilation of LLVM IR to Go source code.
+//
+// The decompiler relies on the high-level control flow primitives of each
+// function, as recovered by control flow analysis (see the cfa package).
+package ll2go
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// dbg represents a logger with the "ll2go:" prefix, which logs debug messages;
+// debug messages are discarded unless enabled using SetDebugOutput.
+var dbg = log.New(ioutil.Discard, term.GreenBold("ll2go:")+" ", 0)
+
+// SetDebugOutput sets the output destination of debug messages.
+func SetDebugOutput(w io.Writer) {
+	dbg.SetOutput(w)
+}
+
+// Decompile decompiles the given LLVM IR module into a corresponding Go source
+// file, based on the high-level control flow primitives of each function;
+// mapping from function name to control flow primitives. The control flow
+// primitives of functions not present in prims are recovered by RecoverPrims.
+//
+// The package name of the Go source file is "main".
+func Decompile(module *ir.Module, prims map[string][]*primitive.Primitive) (*ast.File, error) {
+	return NewDecompiler().Decompile(module, prims)
+}
+
+// DecompileString decompiles the given LLVM IR assembly into corresponding Go
+// source code, formatted as by gofmt. The control flow primitives of each
+// function are recovered by RecoverPrims.
+//
+// The package name of the Go source code is "main".
+func DecompileString(src string) (string, error) {
+	module, err := asm.ParseString("", src)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	file, err := Decompile(module, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return buf.String(), nil
+}
+
+// A Decompiler keeps track of relevant information during the decompilation
+// process of a module.
+//
+// A Decompiler may be used concurrently by multiple goroutines, as the
+// per-function state of the decompilation process is kept in a separate
+// function context for each function.
+//
+// Comments (e.g. line comments and the comments of undef values) are only
+// emitted in the Go source files returned by Decompile, as the Go nodes
+// returned by FuncDecl, GoType and Value have no source positions to attach
+// comments to.
+type Decompiler struct {
+	// Go type of i8 pointers; either "*int8", "[]byte" or "unsafe.Pointer".
+	I8Ptr string
+	// Emit the source locations of !dbg metadata attachments as line comments.
+	LineComments bool
+	// Precede the Go statements of each instruction by a comment with its LLVM
+	// IR assembly.
+	IRComments bool
+	// Assign the incoming values of PHI instructions at the end of each
+	// predecessor basic block, preceded by a comment naming the PHI variable;
+	// rather than directly after the definition of the incoming values. The
+	// variables of PHI instructions are declared at the start of each function
+	// either way.
+	NoPhiPropagation bool
+	// Target platform of C-derived LLVM IR (e.g. "linux-amd64"), as specified
+	// by "GOOS-GOARCH"; or empty to map integer types to the Go integer types of
+	// the same size. Integers of the pointer size of the target map to int, and
+	// to uintptr in unsigned operations (e.g. udiv), as the C types of the
+	// original source (e.g. long and size_t) are pointer-sized. The pointer size
+	// specified by the data layout or target triple of the module takes
+	// precedence over that of the architecture of the target.
+	//
+	// As LLVM IR integer types are signless, the signedness of the C types is
+	// recovered from the zeroext attribute of parameters; i.e. pointer-sized
+	// zeroext parameters (e.g. size_t) map to uintptr. The signext attribute and
+	// the basic types of !dbg metadata are not yet taken into account, and the
+	// Go function types of function values do not carry parameter attributes.
+	Target string
+	// Rewrite pre-test loops which iterate over the indices of an array, with a
+	// constant trip count matching the array length, into for-range loops.
+	RangeLoops bool
+	// Rewrite tail-recursive self-calls into loops.
+	TailCalls bool
+	// Inline single-use temporaries into their use, and declare the remaining
+	// temporaries in a var block at the start of each function.
+	InlineTemps bool
+	// Maximum number of instructions, including terminators, of functions to
+	// decompile; or 0 for no limit. Larger functions are replaced by stubs, as
+	// the control flow recovery of pathologically large functions may exhaust
+	// memory.
+	MaxFuncSize int
+	// Generate a Go main function which calls the LLVM IR main function, if
+	// any, and exits with its result as status code; the LLVM IR main function
+	// is renamed to _main.
+	Runnable bool
+	// Derive the Go identifiers of C++ global and function names from their
+	// demangled names (e.g. foo_bar for _ZN3foo3barEv), as mangled by the
+	// Itanium C++ ABI; the mangled names are kept in doc comments.
+	Demangle bool
+	// Go identifiers of LLVM IR names, which take precedence over the Go
+	// identifiers derived from the LLVM IR names; or nil if none.
+	Names *SymbolMap
+	// Decompilation coverage statistics, which are updated as functions are
+	// decompiled; or nil if not recorded.
+	Stats *Stats
+
+	// Type declarations of the module being decompiled; or nil if type
+	// declarations are not tracked.
+	types *typeRegistry
+	// Packages referenced by the module being decompiled; or nil if imports are
+	// not tracked.
+	imports *importSet
+	// Helper functions referenced by the module being decompiled; or nil if
+	// helper functions are not tracked.
+	helpers *helperSet
+	// String globals of the module being decompiled, which are inlined as Go
+	// string literals at their use sites; or nil if none (see stringGlobals).
+	strs map[*ir.Global]bool
+	// Go identifiers of the mangled C++ global and function names of the module
+	// being decompiled; or nil if not tracked (see demangledNames).
+	demangled map[string]string
+	// Go identifiers of the local names of the function being decompiled; or
+	// nil if local names are not tracked.
+	names *nameAllocator
+	// Report of the unsupported LLVM IR constructs of the function being
+	// decompiled; or nil if unsupported constructs cause an error (see Report).
+	report *FuncReport
+	// Registered lowerings of calls; mapping from callee name to call lowering,
+	// or nil if none (see RegisterCallLowering).
+	calls map[string]CallLowering
+	// Comments of the Go nodes of the module being decompiled; or nil if
+	// comments are not tracked.
+	comments *commentSet
+	// Pointer size in bits of the module being decompiled, as specified by its
+	// data layout or target triple; or 0 if unspecified (see ptrSize).
+	modulePtrSize uint64
+	// First unsupported type encountered while decompiling the function or
+	// module, as types are converted without returning errors; or nil if
+	// unsupported types are not tracked (see GoType).
+	typeErr *error
+}
+
+// A funcContext keeps track of relevant information during the decompilation
+// process of a function.
+type funcContext struct {
+	*Decompiler
+
+	// LLVM IR function being decompiled.
+	f *ir.Func
+
+	// Basic blocks of the function, which have yet to be merged into control
+	// flow primitives; mapping from basic block name to basic block.
+	blocks map[string]*basicBlock
+	// Parent basic block of each instruction of the function.
+	parents map[ir.Instruction]*ir.Block
+	// Names of basic blocks targeted by goto statements; mapping from basic
+	// block name to number of goto statements.
+	labels map[string]int
+	// Number of predecessors of each basic block; mapping from basic block name
+	// to number of predecessors.
+	preds map[string]int
+	// Original entry basic block names of merged basic blocks; mapping from
+	// merged basic block name to entry basic block name.
+	entries map[string]string
+	// Basic blocks inlined into the case clauses of switch statements.
+	inlined map[string]bool
+	// Candidate for-range loops; mapping from for statement to range loop, or
+	// nil if none (see recordRangeLoop).
+	ranges map[*ast.ForStmt]*rangeLoop
+	// Number of uses of each value of the function; or nil if not yet counted
+	// (see numUses).
+	uses map[value.Value]int
+	// PHI instructions whose incoming values are copied through temporaries
+	// (see edgePhis).
+	edgePhis map[*ir.InstPhi]bool
+	// Name of the personality function of the function, which determines the
+	// unwind semantics of its invoke and landingpad instructions; or empty if
+	// none.
+	personality string
+	// Control flow recovery of the function is incomplete, and the remaining
+	// basic blocks are emitted as labeled statements.
+	incomplete bool
+}
+
+// newFuncContext returns a new function context of the decompiler.
+//
+// The local names of the function are tracked by a shallow copy of the
+// decompiler, to allow concurrent use.
+func (d *Decompiler) newFuncContext() *funcContext {
+	fd := *d
+	fd.names = newNameAllocator()
+	fd.typeErr = new(error)
+	return &funcContext{
+		Decompiler: &fd,
+		blocks:     make(map[string]*basicBlock),
+		parents:    make(map[ir.Instruction]*ir.Block),
+		labels:     make(map[string]int),
+		preds:      make(map[string]int),
+		entries:    make(map[string]string),
+		inlined:    make(map[string]bool),
+	}
+}
+
+// NewDecompiler returns a new decompiler.
+func NewDecompiler() *Decompiler {
+	return &Decompiler{
+		I8Ptr: "*int8",
+	}
+}
+
+// Decompile decompiles the given LLVM IR module into a corresponding Go source
+// file, based on the high-level control flow primitives of each function;
+// mapping from function name to control flow primitives. The control flow
+// primitives of functions not present in prims are recovered by RecoverPrims.
+//
+// Failing to decompile a function does not prevent the remaining functions from
+// being decompiled. If any function fails to decompile, the returned Go source
+// file contains the functions which were successfully decompiled, and the
+// returned error is of type FuncErrors.
+//
+// The comments of the Go source file (see ast.File.Comments) are positioned by
+// the source positions of the file, as recorded in the file set of FileSet.
+//
+// The package name of the Go source file is "main".
+func (d *Decompiler) Decompile(module *ir.Module, prims map[string][]*primitive.Primitive) (*ast.File, error) {
+	// Keep track of the named types and referenced packages of the module,
+	// using a shallow copy of the decompiler to allow concurrent use.
+	md := *d
+	d = &md
+	d.types = newTypeRegistry()
+	d.imports = newImportSet()
+	d.helpers = newHelperSet()
+	d.comments = newCommentSet()
+	d.typeErr = new(error)
+	d.modulePtrSize = modulePtrSize(module)
+	for _, t := range module.TypeDefs {
+		d.registerType(t)
+	}
+	if d.Demangle {
+		d.demangled = demangledNames(module)
+	}
+
+	// Decompile global variables; string globals are inlined at their use
+	// sites instead of being declared.
+	var globals []ast.Decl
+	consts := constGlobals(module)
+	d.strs = stringGlobals(module)
+	for _, g := range module.Globals {
+		if d.strs[g] {
+			continue
+		}
+		decl, err := d.globalDecl(g, consts[g])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		globals = append(globals, decl)
+	}
+	if err := d.typeError(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n := len(d.types.names)
+
+	// Decompile functions concurrently, using one goroutine per CPU, while
+	// preserving the order of function declarations.
+	var funcs []*ir.Func
+	for _, f := range module.Funcs {
+		// Skip function declarations.
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		funcs = append(funcs, f)
+	}
+	fns := make([]*ast.FuncDecl, len(funcs))
+	errs := make([]error, len(funcs))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				fns[j], errs[j] = d.decompileFunc(funcs[j], prims)
+			}
+		}()
+	}
+	for j := range funcs {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Import the referenced packages, and declare each named type once,
+	// followed by the global variables, functions and helper functions.
+	var funcErrs FuncErrors
+	var decls []ast.Decl
+	for j, fn := range fns {
+		if errs[j] != nil {
+			funcErrs = append(funcErrs, &FuncError{Func: funcs[j].Name(), Err: errs[j]})
+			continue
+		}
+		decls = append(decls, fn)
+	}
+	if f, ok := findMain(module); ok && d.Runnable {
+		fn, err := d.mainFunc(f)
+		if err != nil {
+			funcErrs = append(funcErrs, &FuncError{Func: f.Name(), Err: err})
+		} else {
+			decls = append(decls, fn)
+		}
+	}
+	decls = append(decls, d.helpers.decls()...)
+	if len(funcErrs) > 0 {
+		// Packages may have been referenced by functions which failed to
+		// decompile.
+		d.imports.prune(append(globals, decls...))
+	}
+	file := &ast.File{
+		Name: ast.NewIdent("main"),
+	}
+	if decl := d.imports.decl(); decl != nil {
+		file.Decls = append(file.Decls, decl)
+		for _, spec := range decl.Specs {
+			file.Imports = append(file.Imports, spec.(*ast.ImportSpec))
+		}
+	}
+	file.Decls = append(file.Decls, d.types.decls(n)...)
+	file.Decls = append(file.Decls, globals...)
+	file.Decls = append(file.Decls, decls...)
+	// Record the comments of the decompiled Go nodes in the Go source file.
+	d.comments.file(file)
+	if len(funcErrs) > 0 {
+		return file, funcErrs
+	}
+	return file, nil
+}
+
+// A FuncError is an error encountered while decompiling a function.
+type FuncError struct {
+	// Function name.
+	Func string
+	// Error encountered while decompiling the function.
+	Err error
+}
+
+// Error returns the error message of the function error.
+func (e *FuncError) Error() string {
+	return fmt.Sprintf("unable to decompile function %q; %v", e.Func, e.Err)
+}
+
+// FuncErrors is the list of errors of the functions which failed to decompile,
+// as returned by Decompile.
+type FuncErrors []*FuncError
+
+// Error returns the error message of the first function error, followed by the
+// number of additional errors.
+func (errs FuncErrors) Error() string {
+	switch len(errs) {
+	case 0:
+		return "no function errors"
+	case 1:
+		return errs[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", errs[0], len(errs)-1)
+}
+
+// A FuncResult is the result of decompiling a function.
+type FuncResult struct {
+	// LLVM IR function.
+	Func *ir.Func
+	// Go function declaration; or nil if Err is non-nil.
+	Decl *ast.FuncDecl
+	// Error encountered while decompiling the function, if any.
+	Err error
+}
+
+// FuncDecls decompiles the function definitions of the given LLVM IR module one
+// at a time, based on the high-level control flow primitives of each function;
+// mapping from function name to control flow primitives. The control flow
+// primitives of functions not present in prims are recovered by RecoverPrims.
+//
+// The result of each function is sent on the returned channel as soon as it has
+// been decompiled, in the order of module.Funcs. Failing to decompile a
+// function does not prevent the remaining functions from being decompiled. The
+// channel is closed after the last function, and must be drained.
+func (d *Decompiler) FuncDecls(module *ir.Module, prims map[string][]*primitive.Primitive) <-chan FuncResult {
+	results := make(chan FuncResult)
+	go func() {
+		defer close(results)
+		for _, f := range module.Funcs {
+			// Skip function declarations.
+			if len(f.Blocks) == 0 {
+				continue
+			}
+			fn, err := d.decompileFunc(f, prims)
+			results <- FuncResult{Func: f, Decl: fn, Err: err}
+		}
+	}()
+	return results
+}
+
+// decompileFunc decompiles the given LLVM IR function definition, based on its
+// control flow primitives in prims, or on recovered control flow primitives if
+// not present.
+//
+// A panic while decompiling the function (e.g. in a registered call lowering)
+// is returned as an error, so that the remaining functions may be decompiled.
+func (d *Decompiler) decompileFunc(f *ir.Func, prims map[string][]*primitive.Primitive) (fn *ast.FuncDecl, err error) {
+	dbg.Printf("decompiling function %q.", f.Name())
+	defer func() {
+		if e := recover(); e != nil {
+			d.addFuncStats(funcFailed)
+			fn, err = nil, errors.Errorf("panic while decompiling function; %v", e)
+		}
+	}()
+	fprims, ok := prims[f.Name()]
+	// Skip control flow recovery of functions which are replaced by stubs.
+	if !ok && !d.ExceedsMaxFuncSize(f) {
+		if fprims, err = RecoverPrims(f); err != nil {
+			d.addFuncStats(funcFailed)
+			return nil, errors.WithStack(err)
+		}
+	}
+	fc := d.newFuncContext()
+	fn, err = fc.funcDecl(f, fprims)
+	switch {
+	case err != nil:
+		d.addFuncStats(funcFailed)
+		return nil, errors.WithStack(err)
+	case d.ExceedsMaxFuncSize(f):
+		d.addFuncStats(funcStubbed)
+	case fc.incomplete:
+		d.addFuncStats(funcPartial)
+	default:
+		d.addFuncStats(funcRecovered)
+	}
+	return fn, nil
+}
+
+// addFuncStats records the outcome of decompiling a function in the
+// decompilation coverage statistics, if recorded.
+func (d *Decompiler) addFuncStats(outcome int) {
+	if d.Stats != nil {
+		d.Stats.addFunc(outcome)
+	}
+}
+
+// FuncDecl converts the given LLVM IR function into a corresponding Go function
+// declaration, based on the high-level control flow primitives of the function.
+func (d *Decompiler) FuncDecl(f *ir.Func, prims []*primitive.Primitive) (*ast.FuncDecl, error) {
+	return d.newFuncContext().funcDecl(f, prims)
+}
+
+// funcDecl converts the given LLVM IR function into a corresponding Go function
+// declaration, based on the high-level control flow primitives of the function.
+func (fc *funcContext) funcDecl(f *ir.Func, prims []*primitive.Primitive) (*ast.FuncDecl, error) {
+	// Force generate local IDs; unlike f.String, this does not print the
+	// function, and only names the locals which remain unnamed.
+	if err := f.AssignIDs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fc.f = f
+	if fc.modulePtrSize == 0 && f.Parent != nil {
+		fc.modulePtrSize = modulePtrSize(f.Parent)
+	}
+	fc.personality = personalityName(f.Personality)
+	if fc.Names != nil {
+		fc.names.renames = fc.Names.Locals[f.Name()]
+	}
+
+	// Recover function declaration. Parameters are named by their local names
+	// (e.g. %arg and %0 are named "arg" and "_0"), as referenced in the body.
+	sig, err := funcType(f, fc.GoType(f.Sig))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i, param := range f.Params {
+		sig.Params.List[i].Names = []*ast.Ident{fc.local(param.Name())}
+		if fc.uintptrParam(param) {
+			sig.Params.List[i].Type = fc.paramType(param)
+		}
+	}
+	if f.Sig.Variadic {
+		sig.Params.List[len(sig.Params.List)-1].Names = []*ast.Ident{ast.NewIdent(vaArgs)}
+	}
+	fn := &ast.FuncDecl{
+		Name: fc.global(f.Name()),
+		Type: sig,
+	}
+	// Document the semantic hints of function attributes.
+	fn.Doc = fc.funcDoc(f, fn.Name.Name)
+	if fc.ExceedsMaxFuncSize(f) {
+		dbg.Printf("skipping function %q; %d instructions exceed maximum function size of %d.", f.Name(), funcSize(f), fc.MaxFuncSize)
+		fn.Body = fc.stubBody(fmt.Sprintf("function not decompiled; %d instructions exceed maximum function size of %d", funcSize(f), fc.MaxFuncSize))
+		if err := fc.typeError(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fn, nil
+	}
+
+	// Record basic blocks and the number of predecessors of each basic block.
+	// Invoke terminators are lowered into calls recovering from panics, and
+	// conditional branches to the unwind and normal basic blocks. Indirectbr
+	// terminators of jump tables are lowered into switches.
+	for _, block := range f.Blocks {
+		fc.blocks[block.Name()] = &basicBlock{Block: block}
+		for _, inst := range block.Insts {
+			fc.parents[inst] = block
+			// Allocate Go identifiers of local names in order of definition; e.g.
+			// "i" for %i.0 and "i_1" for %i.1.
+			if v, ok := inst.(value.Named); ok && len(v.Name()) > 0 {
+				fc.local(v.Name())
+			}
+		}
+		switch term := block.Term.(type) {
+		case *ir.TermInvoke:
+			b, err := fc.invokeBlock(block, term)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			fc.blocks[block.Name()] = b
+		case *ir.TermIndirectBr:
+			if jt, ok := findJumpTable(block, term); ok {
+				fc.blocks[block.Name()] = jumpTableBlock(block, term, jt)
+			}
+		}
+		// Count each distinct successor once; terminators have few successors.
+		succs := block.Term.Succs()
+		for i, succ := range succs {
+			if !containsBlock(succs[:i], succ) {
+				fc.preds[succ.Name()]++
+			}
+		}
+	}
+
+	// Record outgoing values of PHI instructions; i.e. assign the incoming value
+	// to the PHI variable in each predecessor basic block, as determined by the
+	// PHI strategy of the decompiler. PHI variables copied through temporaries
+	// are assigned at the start of the basic block of the PHI instruction.
+	phis := fc.phiStrategy()
+	fc.edgePhis = edgePhis(f)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			if fc.edgePhis[phi] {
+				b := fc.blocks[block.Name()]
+				b.in = append(b.in, fc.assign(phi.Name(), fc.local(phiTemp(phi))))
+			}
+			incs, err := phiIncs(phi)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for _, inc := range incs {
+				if err := phis.assign(fc, phi, fc.blocks[blockName(inc.Pred)], inc.X); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+		}
+	}
+
+	// Merge basic blocks into control flow primitives.
+	var order []string
+	for _, block := range f.Blocks {
+		order = append(order, block.Name())
+	}
+	loops := enclosingLoops(prims)
+	for i, prim := range prims {
+		block, err := fc.prim(prim, loops[i])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		order = mergeOrder(order, prim)
+		for _, node := range prim.Nodes {
+			delete(fc.blocks, node)
+		}
+		fc.blocks[block.Name()] = block
+		// Branches to the entry of the primitive target the merged basic block.
+		entry := prim.Entry
+		if orig, ok := fc.entries[entry]; ok {
+			entry = orig
+		}
+		fc.entries[block.Name()] = entry
+	}
+
+	// After control flow recovery, a single basic block should remain; not
+	// counting basic blocks inlined into the case clauses of switch statements.
+	// If control flow recovery is incomplete (e.g. for irreducible control
+	// flow), the remaining basic blocks are emitted as labeled statements, and
+	// the branches between them as goto statements.
+	var bodies [][]ast.Stmt
+	for _, name := range order {
+		block := fc.blocks[name]
+		stmts, err := fc.stmts(block)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		termStmts, err := fc.term(block.Term)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		bodies = append(bodies, append(stmts, termStmts...))
+	}
+	fn.Body = &ast.BlockStmt{List: phis.decls(fc)}
+	fn.Body.List = append(fn.Body.List, fc.landingPadDecls()...)
+	if n := len(fc.blocks) - len(fc.inlined); n != 1 {
+		var remaining []string
+		for _, name := range order {
+			if fc.inlined[name] {
+				continue
+			}
+			// Merged basic blocks are named by the node of their primitive.
+			if orig, ok := fc.entries[name]; ok {
+				name = fmt.Sprintf("%s (entry: %s)", name, orig)
+			}
+			remaining = append(remaining, name)
+		}
+		dbg.Printf("control flow recovery incomplete in function %q; %d basic blocks remain (%s) after %s.", f.Name(), n, strings.Join(remaining, ", "), primsString(prims))
+		fc.incomplete = true
+		fn.Body.List = append(fn.Body.List, fc.hoistDecls(bodies, fn.Body.List)...)
+	}
+	for i, name := range order {
+		// Skip basic blocks inlined into the case clauses of switch statements.
+		if fc.inlined[name] {
+			continue
+		}
+		stmts := bodies[i]
+		// Only label basic blocks targeted by goto statements, as unused labels
+		// are invalid in Go.
+		if orig, ok := fc.entries[name]; ok {
+			name = orig
+		}
+		if fc.labels[name] > 0 {
+			stmts = labeled(fc.label(name), stmts)
+		}
+		fn.Body.List = append(fn.Body.List, stmts...)
+	}
+	fc.rewriteRangeLoops(fn.Body)
+	fc.rewriteTailCalls(fn)
+	fc.inlineTemps(fn)
+	if err := fc.typeError(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fn, nil
+}
+
+// funcSize returns the number of instructions of the given function, including
+// terminators.
+func funcSize(f *ir.Func) int {
+	n := 0
+	for _, block := range f.Blocks {
+		n += len(block.Insts) + 1
+	}
+	return n
+}
+
+// ExceedsMaxFuncSize reports whether the given function exceeds the maximum
+// function size of the decompiler, if any. The control flow primitives of such
+// functions are not used, as the functions are replaced by stubs.
+func (d *Decompiler) ExceedsMaxFuncSize(f *ir.Func) bool {
+	return d.MaxFuncSize > 0 && funcSize(f) > d.MaxFuncSize
+}
+
+// stubBody returns the body of a stub Go function, which panics when called
+// and is preceded by the given comment; e.g.
+//
+//    // function not decompiled; 1200 instructions exceed maximum function size of 1000
+//    panic("function not decompiled")
+func (d *Decompiler) stubBody(comment string) *ast.BlockStmt {
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("function not decompiled")}},
+	}
+	return &ast.BlockStmt{
+		List: append(d.commentStmts(comment), &ast.ExprStmt{X: call}),
+	}
+}
+
+// containsBlock reports whether the given basic blocks contain a basic block of
+// the same name as block.
+func containsBlock(blocks []*ir.Block, block *ir.Block) bool {
+	for _, b := range blocks {
+		if b.Name() == block.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// funcType returns the given Go type of the signature of the given LLVM IR
+// function, as converted by GoType, if a Go function type.
+func funcType(f *ir.Func, typ ast.Expr) (*ast.FuncType, error) {
+	if sig, ok := typ.(*ast.FuncType); ok {
+		return sig, nil
+	}
+	buf := &bytes.Buffer{}
+	if typ != nil {
+		if err := format.Node(buf, token.NewFileSet(), typ); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return nil, errors.Errorf("invalid Go type %q (%T) of signature %q of function %q; expected function type", buf, typ, f.Sig, f.Name())
+}
+
+// mergeOrder returns the layout order of basic blocks after merging the basic
+// blocks of the given control flow primitive, which takes the place of the
+// first of its basic blocks.
+func mergeOrder(order []string, prim *primitive.Primitive) []string {
+	nodes := make(map[string]bool)
+	for _, node := range prim.Nodes {
+		nodes[node] = true
+	}
+	var merged []string
+	placed := false
+	for _, name := range order {
+		if !nodes[name] {
+			merged = append(merged, name)
+			continue
+		}
+		if !placed {
+			merged = append(merged, prim.Node)
+			placed = true
+		}
+	}
+	return merged
+}
+
+// hoistDecls hoists the variable declarations of the given statements of the
+// basic blocks which remain after control flow recovery, and returns the
+// hoisted declarations, which are placed at the start of the function body
+// after the given declarations.
+//
+// Go does not allow goto statements to jump over variable declarations into
+// the scope of the variables; thus, short variable declarations are turned
+// into assignments to variables declared at the start of the function body.
+// The variables of PHI instructions are declared as well, unless already
+// declared.
+//
+//    var y int32
+//    var _1 int32
+//    ...
+//    _1 = x + 1
+func (fc *funcContext) hoistDecls(bodies [][]ast.Stmt, decls []ast.Stmt) []ast.Stmt {
+	// Local variables; mapping from Go variable name to LLVM IR value.
+	locals := make(map[string]value.Named)
+	var phis []*ir.InstPhi
+	for _, block := range fc.f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Named); ok && len(v.Name()) > 0 {
+				locals[fc.local(v.Name()).Name] = v
+			}
+			if phi, ok := inst.(*ir.InstPhi); ok {
+				phis = append(phis, phi)
+			}
+		}
+		if term, ok := block.Term.(*ir.TermInvoke); ok && len(term.Name()) > 0 {
+			locals[fc.local(term.Name()).Name] = term
+		}
+	}
+	declared := make(map[string]bool)
+	for _, decl := range decls {
+		for _, name := range declNames(decl) {
+			declared[name] = true
+		}
+	}
+	var hoisted []ast.Stmt
+	declare := func(name string) bool {
+		v, ok := locals[name]
+		if !ok || declared[name] {
+			return declared[name]
+		}
+		declared[name] = true
+		hoisted = append(hoisted, fc.varDecl(v.Name(), fc.GoType(v.Type())))
+		return true
+	}
+	for _, phi := range phis {
+		declare(fc.local(phi.Name()).Name)
+	}
+	for j, stmts := range bodies {
+		var list []ast.Stmt
+		for _, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *ast.AssignStmt:
+				if stmt.Tok != token.DEFINE {
+					break
+				}
+				ok := true
+				for _, lhs := range stmt.Lhs {
+					if ident, isIdent := lhs.(*ast.Ident); !isIdent || !declare(ident.Name) {
+						ok = false
+					}
+				}
+				if ok {
+					stmt.Tok = token.ASSIGN
+				}
+			case *ast.DeclStmt:
+				// Move variable declarations without values, and turn the
+				// remaining variable declarations into assignments.
+				gen, ok := stmt.Decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+					break
+				}
+				spec := gen.Specs[0].(*ast.ValueSpec)
+				for _, name := range spec.Names {
+					declared[name.Name] = true
+				}
+				hoisted = append(hoisted, &ast.DeclStmt{Decl: &ast.GenDecl{
+					Tok:   token.VAR,
+					Specs: []ast.Spec{&ast.ValueSpec{Names: spec.Names, Type: spec.Type}},
+				}})
+				if len(spec.Values) == 0 {
+					continue
+				}
+				var lhs []ast.Expr
+				for _, name := range spec.Names {
+					lhs = append(lhs, name)
+				}
+				list = append(list, &ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: spec.Values})
+				continue
+			}
+			list = append(list, stmt)
+		}
+		bodies[j] = list
+	}
+	return hoisted
+}
+
+// declNames returns the names of the variables declared by the given
+// statement.
+func declNames(stmt ast.Stmt) []string {
+	decl, ok := stmt.(*ast.DeclStmt)
+	if !ok {
+		return nil
+	}
+	gen, ok := decl.Decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR {
+		return nil
+	}
+	var names []string
+	for _, spec := range gen.Specs {
+		for _, name := range spec.(*ast.ValueSpec).Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
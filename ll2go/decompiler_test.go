@@ -0,0 +1,741 @@
+package ll2go
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestDecompile(t *testing.T) {
+	m := ir.NewModule()
+	m.NewTypeDef("struct.point", types.NewStruct(types.I32, types.I32))
+	m.NewFunc("g", types.I32)
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 42))
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `package main
+
+type point struct {
+	Field0 int32
+	Field1 int32
+}
+
+func f() int32 {
+	return 42
+}
+`
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestDecompileString(t *testing.T) {
+	golden := []struct {
+		src  string
+		want string
+	}{
+		// Constant return.
+		{
+			src: `
+define i32 @f() {
+entry:
+	ret i32 42
+}
+`,
+			want: "package main\n\nfunc f() int32 {\n\treturn 42\n}\n",
+		},
+		// Named local variable.
+		{
+			src: `
+define i32 @add(i32 %x, i32 %y) {
+entry:
+	%z = add i32 %x, %y
+	ret i32 %z
+}
+`,
+			want: "package main\n\nfunc add(x int32, y int32) int32 {\n\tz := x + y\n\treturn z\n}\n",
+		},
+		// Conditional branch.
+		{
+			src: `
+define i32 @larger(i32 %x, i32 %y) {
+entry:
+	%cond = icmp sgt i32 %x, %y
+	br i1 %cond, label %then, label %else
+then:
+	ret i32 %x
+else:
+	ret i32 %y
+}
+`,
+			want: "package main\n\nfunc larger(x int32, y int32) int32 {\n\tcond := x > y\n\tif cond {\n\t\treturn x\n\t}\n\treturn y\n}\n",
+		},
+		// Go keywords and predeclared identifiers.
+		{
+			src: `
+declare i32 @select()
+
+define i32 @len(i32 %int) {
+entry:
+	%type = call i32 @select()
+	%x = add i32 %type, %int
+	ret i32 %x
+}
+`,
+			want: "package main\n\nfunc _len(_int int32) int32 {\n\t_type := _select()\n\tx := _type + _int\n\treturn x\n}\n",
+		},
+		// Type names which differ by their struct, union or class prefix.
+		{
+			src: `
+%struct.foo = type { i32 }
+%union.foo = type { i64 }
+
+define void @f(%struct.foo* %p, %union.foo* %q) {
+entry:
+	ret void
+}
+`,
+			want: "package main\n\ntype foo struct {\n\tField0 int32\n}\ntype foo_1 struct {\n\tField0 int64\n}\n\nfunc f(p *foo, q *foo_1) {\n\treturn\n}\n",
+		},
+		// Global names which differ by a dot-separated suffix.
+		{
+			src: `
+define i32 @f.cold() {
+entry:
+	ret i32 1
+}
+
+define i32 @f() {
+entry:
+	%x = call i32 @f.cold()
+	ret i32 %x
+}
+`,
+			want: "package main\n\nfunc f_cold() int32 {\n\treturn 1\n}\nfunc f() int32 {\n\tx := f_cold()\n\treturn x\n}\n",
+		},
+	}
+	for i, g := range golden {
+		got, err := DecompileString(g.src)
+		if err != nil {
+			t.Errorf("i=%d: unable to decompile LLVM IR assembly; %v", i, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("i=%d: Go source mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
+
+func TestDecompileStringInvalid(t *testing.T) {
+	if _, err := DecompileString("define i32 @f() {"); err == nil {
+		t.Errorf("expected error for invalid LLVM IR assembly")
+	}
+}
+
+func TestDecompileFuncErrors(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	// Supported function.
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	// Function with an unsupported constant expression.
+	h := m.NewFunc("h", types.I32)
+	entry := h.NewBlock("entry")
+	entry.NewRet(entry.NewLoad(types.I32, constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))))
+
+	file, err := Decompile(m, nil)
+	funcErrs, ok := err.(FuncErrors)
+	if !ok {
+		t.Fatalf("error type mismatch; expected FuncErrors, got %T (%v)", err, err)
+	}
+	if len(funcErrs) != 1 || funcErrs[0].Func != "h" {
+		t.Fatalf("function errors mismatch; expected error of %q, got %v", "h", funcErrs)
+	}
+	const want = `unable to decompile function "h"; support for constant expression *constant.ExprAddrSpaceCast not yet implemented`
+	if got := funcErrs.Error(); got != want {
+		t.Errorf("error message mismatch; expected %q, got %q", want, got)
+	}
+	// The supported function is emitted.
+	if file == nil {
+		t.Fatalf("expected Go source file of the supported function")
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	if len(names) != 1 || names[0] != "f" {
+		t.Errorf("function declarations mismatch; expected [f], got %v", names)
+	}
+}
+
+func TestDecompileUnsupported(t *testing.T) {
+	// Each function uses an LLVM IR construct which is not yet supported; the
+	// remaining functions are decompiled.
+	const src = `
+declare void @may_throw()
+
+define i32 @f(i32 %x) {
+entry:
+	ret i32 %x
+}
+
+define <2 x float> @frem(<2 x float> %x, <2 x float> %y) {
+entry:
+	%z = frem <2 x float> %x, %y
+	ret <2 x float> %z
+}
+
+define <2 x i1> @fcmp(<2 x float> %x, <2 x float> %y) {
+entry:
+	%z = fcmp uno <2 x float> %x, %y
+	ret <2 x i1> %z
+}
+
+define <2 x i32> @udiv(<2 x i32> %x, <2 x i32> %y) {
+entry:
+	%z = udiv <2 x i32> %x, %y
+	ret <2 x i32> %z
+}
+
+define void @token(token %t) {
+entry:
+	ret void
+}
+
+define void @cleanuppad() personality i32 (...)* @__CxxFrameHandler3 {
+entry:
+	invoke void @may_throw()
+		to label %normal unwind label %cleanup
+normal:
+	ret void
+cleanup:
+	%cp = cleanuppad within none []
+	cleanupret from %cp unwind to caller
+}
+
+declare i32 @__CxxFrameHandler3(...)
+`
+	m, err := asm.ParseString("", src)
+	if err != nil {
+		t.Fatalf("unable to parse LLVM IR module; %v", err)
+	}
+	// The control flow graph of functions with cleanupret terminators cannot be
+	// constructed; thus empty control flow primitives are used.
+	prims := map[string][]*primitive.Primitive{"cleanuppad": nil}
+	file, err := Decompile(m, prims)
+	funcErrs, ok := err.(FuncErrors)
+	if !ok {
+		t.Fatalf("error type mismatch; expected FuncErrors, got %T (%v)", err, err)
+	}
+	want := map[string]string{
+		"frem":       "support for floating-point remainder of type *types.VectorType not yet implemented",
+		"fcmp":       "support for NaN check of type *types.VectorType not yet implemented",
+		"udiv":       "support for unsigned type of *types.VectorType not yet implemented",
+		"token":      "support for type *types.TokenType not yet implemented",
+		"cleanuppad": "support for unwind basic block instruction *ir.InstCleanupPad not yet implemented",
+	}
+	if len(funcErrs) != len(want) {
+		t.Fatalf("function errors mismatch; expected %d errors, got %v", len(want), funcErrs)
+	}
+	for _, funcErr := range funcErrs {
+		if got := funcErr.Err.Error(); got != want[funcErr.Func] {
+			t.Errorf("%q: error message mismatch; expected %q, got %q", funcErr.Func, want[funcErr.Func], got)
+		}
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	if len(names) != 1 || names[0] != "f" {
+		t.Errorf("function declarations mismatch; expected [f], got %v", names)
+	}
+
+	// Unsupported constructs are recorded when reporting.
+	for _, report := range NewDecompiler().Report(m) {
+		if report.Func == "f" || report.Func == "cleanuppad" {
+			continue
+		}
+		if len(report.Unsupported) == 0 {
+			t.Errorf("%q: expected unsupported constructs in report", report.Func)
+		}
+	}
+}
+
+func TestDecompilePanic(t *testing.T) {
+	// A panic while decompiling a function does not prevent the remaining
+	// functions from being decompiled.
+	m := ir.NewModule()
+	boom := m.NewFunc("boom", types.Void)
+	for _, name := range []string{"f", "g", "h"} {
+		f := m.NewFunc(name, types.Void)
+		entry := f.NewBlock("entry")
+		if name == "g" {
+			entry.NewCall(boom)
+		}
+		entry.NewRet(nil)
+	}
+	d := NewDecompiler()
+	d.RegisterCallLowering("boom", func(d *Decompiler, call *ir.InstCall) (ast.Stmt, error) {
+		panic("boom")
+	})
+	file, err := d.Decompile(m, nil)
+	funcErrs, ok := err.(FuncErrors)
+	if !ok {
+		t.Fatalf("error type mismatch; expected FuncErrors, got %T (%v)", err, err)
+	}
+	const want = `unable to decompile function "g"; panic while decompiling function; boom`
+	if len(funcErrs) != 1 || funcErrs.Error() != want {
+		t.Fatalf("function errors mismatch; expected %q, got %v", want, funcErrs)
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "f h" {
+		t.Errorf("function declarations mismatch; expected [f h], got %v", names)
+	}
+	reports := d.Report(m)
+	if len(reports) != 3 {
+		t.Fatalf("reports mismatch; expected 3 reports, got %d", len(reports))
+	}
+	if got := reports[1].Err; got != "panic while decompiling function; boom" {
+		t.Errorf("report error mismatch; expected %q, got %q", "panic while decompiling function; boom", got)
+	}
+}
+
+func TestFuncDecls(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	m.NewFunc("g", types.I32)
+	h := m.NewFunc("h", types.I32)
+	h.NewBlock("entry").NewRet(constant.NewInt(types.I32, 2))
+	// Invalid control flow primitive of i.
+	i := m.NewFunc("i", types.I32)
+	i.NewBlock("entry").NewRet(constant.NewInt(types.I32, 3))
+	j := m.NewFunc("j", types.I32)
+	j.NewBlock("entry").NewRet(constant.NewInt(types.I32, 4))
+	prims := map[string][]*primitive.Primitive{
+		"i": {{Prim: "invalid"}},
+	}
+	golden := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{name: "f", want: "func f() int32 {\n\treturn 1\n}"},
+		{name: "h", want: "func h() int32 {\n\treturn 2\n}"},
+		{name: "i", err: true},
+		{name: "j", want: "func j() int32 {\n\treturn 4\n}"},
+	}
+	d := NewDecompiler()
+	var results []FuncResult
+	for result := range d.FuncDecls(m, prims) {
+		results = append(results, result)
+	}
+	if len(results) != len(golden) {
+		t.Fatalf("number of results mismatch; expected %d, got %d", len(golden), len(results))
+	}
+	for k, g := range golden {
+		result := results[k]
+		if result.Func.Name() != g.name {
+			t.Errorf("function name mismatch; expected %q, got %q", g.name, result.Func.Name())
+			continue
+		}
+		if g.err {
+			if result.Err == nil {
+				t.Errorf("%q: expected error, got nil", g.name)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("%q: unable to decompile function; %v", g.name, result.Err)
+			continue
+		}
+		if got := nodeString(t, result.Decl); got != g.want {
+			t.Errorf("%q: function mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestFuncDeclConcurrent(t *testing.T) {
+	// Run with -race to detect data races between functions decompiled
+	// concurrently by the same decompiler.
+	const n = 64
+	m := ir.NewModule()
+	var funcs []*ir.Func
+	for i := 0; i < n; i++ {
+		x := ir.NewParam("x", types.I32)
+		f := m.NewFunc(fmt.Sprintf("f%d", i), types.I32, x)
+		entry := f.NewBlock("entry")
+		body := f.NewBlock("body")
+		exit := f.NewBlock("exit")
+		cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, int64(i)))
+		entry.NewCondBr(cond, body, exit)
+		sum := body.NewAdd(x, constant.NewInt(types.I32, 1))
+		body.NewBr(exit)
+		y := exit.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry), ir.NewIncoming(sum, body))
+		y.SetName("y")
+		exit.NewRet(y)
+		if err := f.AssignIDs(); err != nil {
+			t.Fatalf("unable to assign IDs; %v", err)
+		}
+		funcs = append(funcs, f)
+	}
+	d := NewDecompiler()
+	want := make([]string, n)
+	for i, f := range funcs {
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Fatalf("%q: unable to decompile function; %v", f.Name(), err)
+		}
+		want[i] = nodeString(t, fn)
+	}
+	got := make([]string, n)
+	errs := make([]error, n)
+	wg := &sync.WaitGroup{}
+	for i, f := range funcs {
+		wg.Add(1)
+		go func(i int, f *ir.Func) {
+			defer wg.Done()
+			fn, err := d.FuncDecl(f, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			buf := &bytes.Buffer{}
+			if err := printer.Fprint(buf, token.NewFileSet(), fn); err != nil {
+				errs[i] = err
+				return
+			}
+			got[i] = buf.String()
+		}(i, f)
+	}
+	wg.Wait()
+	for i, f := range funcs {
+		if errs[i] != nil {
+			t.Errorf("%q: unable to decompile function; %v", f.Name(), errs[i])
+			continue
+		}
+		if got[i] != want[i] {
+			t.Errorf("%q: function mismatch; expected %q, got %q", f.Name(), want[i], got[i])
+		}
+	}
+}
+
+func TestDecompileDeterministic(t *testing.T) {
+	// The output is byte-identical across runs; types referenced by concurrently
+	// decompiled functions, package imports and recovered control flow
+	// primitives are emitted in a stable order.
+	m := ir.NewModule()
+	for i := 0; i < 16; i++ {
+		typ := m.NewTypeDef(fmt.Sprintf("struct.t%d", i), types.NewStruct(types.I32, types.Double))
+		p := ir.NewParam("p", types.NewPointer(typ))
+		n := ir.NewParam("n", types.I32)
+		f := m.NewFunc(fmt.Sprintf("f%d", i), types.Double, p, n)
+		entry := f.NewBlock("entry")
+		loop := f.NewBlock("loop")
+		body := f.NewBlock("body")
+		latch := f.NewBlock("latch")
+		exit := f.NewBlock("exit")
+		entry.NewBr(loop)
+		j := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+		j.SetName("j")
+		loop.NewCondBr(loop.NewICmp(enum.IPredSLT, j, n), body, exit)
+		body.NewCondBr(body.NewICmp(enum.IPredEQ, j, constant.NewInt(types.I32, int64(i))), exit, latch)
+		inc := latch.NewAdd(j, constant.NewInt(types.I32, 1))
+		latch.NewBr(loop)
+		j.Incs = append(j.Incs, ir.NewIncoming(inc, latch))
+		exit.NewRet(constant.NewFloat(types.Double, math.Inf(1)))
+	}
+	var want string
+	for i := 0; i < 8; i++ {
+		file, err := Decompile(m, nil)
+		if err != nil {
+			t.Fatalf("unable to decompile module; %v", err)
+		}
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, FileSet(), file); err != nil {
+			t.Fatalf("unable to format Go source file; %v", err)
+		}
+		got := buf.String()
+		if i == 0 {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Fatalf("run %d: Go source mismatch; expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestFuncDeclParams(t *testing.T) {
+	// define i32 @f(i32 %arg, i32) {
+	//    %2 = sub i32 %arg, %0
+	//    ret i32 %2
+	// }
+	m := ir.NewModule()
+	arg := ir.NewParam("arg", types.I32)
+	unnamed := ir.NewParam("", types.I32)
+	f := m.NewFunc("f", types.I32, arg, unnamed)
+	entry := f.NewBlock("")
+	diff := entry.NewSub(arg, unnamed)
+	entry.NewRet(diff)
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := "func f(arg int32, _0 int32) int32 {\n\t_2 := arg - _0\n\treturn _2\n}"
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestFuncDeclInvalidIDs(t *testing.T) {
+	// define i32 @f(i32 %3) {
+	//    ret i32 %3
+	// }
+	m := ir.NewModule()
+	x := ir.NewParam("", types.I32)
+	x.SetID(3)
+	f := m.NewFunc("f", types.I32, x)
+	f.NewBlock("entry").NewRet(x)
+	d := NewDecompiler()
+	if _, err := d.FuncDecl(f, nil); err == nil {
+		t.Errorf("expected error for function with out of order local IDs")
+	}
+}
+
+func TestFuncType(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.I32, ir.NewParam("x", types.I32))
+	d := NewDecompiler()
+	if _, err := funcType(f, d.GoType(f.Sig)); err != nil {
+		t.Errorf("unexpected error for function signature; %v", err)
+	}
+	// Synthetic Go type of a malformed signature.
+	_, err := funcType(f, ast.NewIdent("int32"))
+	if err == nil {
+		t.Fatalf("expected error for non-function Go type of signature")
+	}
+	want := `invalid Go type "int32" (*ast.Ident) of signature "i32 (i32)" of function "f"; expected function type`
+	if got := err.Error(); got != want {
+		t.Errorf("error mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestFuncDeclIncomplete(t *testing.T) {
+	// The debug message of incomplete control flow recovery names the function,
+	// the remaining basic blocks and the applied control flow primitives.
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	g := m.NewFunc("g", types.I1, x)
+	n := ir.NewParam("n", types.I32)
+	f := m.NewFunc("f", types.Void, n)
+	entry := f.NewBlock("entry")
+	pre := f.NewBlock("pre")
+	body := f.NewBlock("body")
+	cond := f.NewBlock("cond")
+	exit := f.NewBlock("exit")
+	entry.NewBr(pre)
+	pre.NewCondBr(pre.NewICmp(enum.IPredSGT, n, constant.NewInt(types.I32, 10)), cond, body)
+	body.NewBr(cond)
+	cond.NewCondBr(cond.NewCall(g, n), body, exit)
+	exit.NewRet(nil)
+
+	buf := &bytes.Buffer{}
+	SetDebugOutput(buf)
+	defer SetDebugOutput(ioutil.Discard)
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	if _, err := NewDecompiler().FuncDecl(f, prims); err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `control flow recovery incomplete in function "f"; 4 basic blocks remain (seq_0 (entry: entry), body, cond, exit) after 1 primitive: seq_0 = seq(entry=entry, exit=pre).`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("debug message mismatch; expected %q in %q", want, got)
+	}
+}
+
+func TestMaxFuncSize(t *testing.T) {
+	m := ir.NewModule()
+	// Function of 2 instructions.
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewAdd(x, constant.NewInt(types.I32, 1)))
+	// Function of 4 instructions.
+	y := ir.NewParam("y", types.I32)
+	g := m.NewFunc("g", types.I32, y)
+	entry = g.NewBlock("entry")
+	sum := entry.NewAdd(y, constant.NewInt(types.I32, 1))
+	prod := entry.NewMul(sum, y)
+	entry.NewRet(entry.NewSub(prod, y))
+
+	buf := &bytes.Buffer{}
+	SetDebugOutput(buf)
+	defer SetDebugOutput(ioutil.Discard)
+	d := NewDecompiler()
+	d.MaxFuncSize = 3
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `package main
+
+func f(x int32) int32 {
+	_0 := x + 1
+	return _0
+}
+func g(y int32) int32 {
+	// function not decompiled; 4 instructions exceed maximum function size of 3
+	panic("function not decompiled")
+}
+`
+	src := &bytes.Buffer{}
+	if err := format.Node(src, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	if got := src.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("main.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+	const wantMsg = `skipping function "g"; 4 instructions exceed maximum function size of 3.`
+	if got := buf.String(); !strings.Contains(got, wantMsg) {
+		t.Errorf("debug message mismatch; expected %q in %q", wantMsg, got)
+	}
+}
+
+// benchModule returns an LLVM IR module of n functions, each summing the
+// squares of the integers below its parameter; e.g.
+//
+//    define i32 @f0(i32 %n) {
+//    entry:
+//       br label %cond
+//    cond:
+//       %i = phi i32 [ 0, %entry ], [ %3, %body ]
+//       %sum = phi i32 [ 0, %entry ], [ %2, %body ]
+//       %0 = icmp slt i32 %i, %n
+//       br i1 %0, label %body, label %exit
+//    body:
+//       %1 = mul i32 %i, %i
+//       %2 = add i32 %sum, %1
+//       %3 = add i32 %i, 1
+//       br label %cond
+//    exit:
+//       ret i32 %sum
+//    }
+func benchModule(n int) *ir.Module {
+	m := ir.NewModule()
+	for j := 0; j < n; j++ {
+		x := ir.NewParam("n", types.I32)
+		f := m.NewFunc(fmt.Sprintf("f%d", j), types.I32, x)
+		entry := f.NewBlock("entry")
+		cond := f.NewBlock("cond")
+		body := f.NewBlock("body")
+		exit := f.NewBlock("exit")
+		zero := constant.NewInt(types.I32, 0)
+		entry.NewBr(cond)
+		i := cond.NewPhi(ir.NewIncoming(zero, entry))
+		i.SetName("i")
+		sum := cond.NewPhi(ir.NewIncoming(zero, entry))
+		sum.SetName("sum")
+		cond.NewCondBr(cond.NewICmp(enum.IPredSLT, i, x), body, exit)
+		sq := body.NewMul(i, i)
+		next := body.NewAdd(sum, sq)
+		inc := body.NewAdd(i, constant.NewInt(types.I32, 1))
+		body.NewBr(cond)
+		i.Incs = append(i.Incs, ir.NewIncoming(inc, body))
+		sum.Incs = append(sum.Incs, ir.NewIncoming(next, body))
+		exit.NewRet(sum)
+	}
+	return m
+}
+
+func BenchmarkDecompile(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		b.Run(fmt.Sprintf("funcs=%d", n), func(b *testing.B) {
+			m := benchModule(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Decompile(m, nil); err != nil {
+					b.Fatalf("unable to decompile module; %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFuncDecl(b *testing.B) {
+	f := benchModule(1).Funcs[0]
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		b.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	d := NewDecompiler()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.FuncDecl(f, prims); err != nil {
+			b.Fatalf("unable to decompile function; %v", err)
+		}
+	}
+}
+
+func BenchmarkFuncDeclLarge(b *testing.B) {
+	// Single basic block of 20000 unnamed instructions.
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	var v value.Value = x
+	for i := 0; i < 20000; i++ {
+		v = entry.NewAdd(x, constant.NewInt(types.I32, int64(i)))
+	}
+	entry.NewRet(v)
+	d := NewDecompiler()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.FuncDecl(f, nil); err != nil {
+			b.Fatalf("unable to decompile function; %v", err)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package ll2go
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+)
+
+// demangle returns the components of the qualified name of the given C++
+// symbol name, as mangled by the Itanium C++ ABI, and a boolean indicating
+// success; e.g.
+//
+//    _ZN3foo3barEv       => foo, bar          // foo::bar()
+//    _ZN3fooC2Ei         => foo, foo          // foo::foo(int)
+//    _ZNSt6vectorIiE5sizeEv => std, vector, size // std::vector<int>::size()
+//    _ZZ4mainE5count     => main, count       // static count of main()
+//    _ZTV3foo            => foo, vtable       // vtable for foo
+//
+// Function parameters and template arguments are not part of the qualified
+// name; thus overloaded functions and template instances share the same
+// qualified name. Only the subset of the mangling grammar common in C-like C++
+// code is supported; the boolean return value is false for any other symbol
+// name.
+//
+// Suffixes of symbol names added by LLVM optimization passes (e.g. ".cold" and
+// ".isra.0") are ignored.
+func demangle(name string) ([]string, bool) {
+	if pos := strings.Index(name, "."); pos > 0 {
+		name = name[:pos]
+	}
+	if !strings.HasPrefix(name, "_Z") {
+		return nil, false
+	}
+	p := &demangler{s: name, pos: len("_Z")}
+	var parts []string
+	var ok bool
+	if suffix, isSpecial := specialNames[p.peek(2)]; isSpecial {
+		p.pos += 2
+		parts, ok = p.name()
+		parts = append(parts, suffix)
+	} else {
+		parts, ok = p.encoding()
+	}
+	if !ok || p.pos != len(p.s) {
+		return nil, false
+	}
+	return parts, true
+}
+
+// specialNames maps from the mangled prefixes of the special names of virtual
+// tables, type information and guard variables to the suffix of their Go
+// identifiers.
+var specialNames = map[string]string{
+	"TV": "vtable",
+	"TI": "typeinfo",
+	"TS": "typeinfo_name",
+	"GV": "guard",
+}
+
+// demangleIdent returns the Go identifier of the given C++ symbol name, as
+// mangled by the Itanium C++ ABI, and a boolean indicating success; e.g.
+// foo_bar for _ZN3foo3barEv.
+func demangleIdent(name string) (string, bool) {
+	parts, ok := demangle(name)
+	if !ok {
+		return "", false
+	}
+	return unreserved(Sanitize(strings.Join(parts, "_"))), true
+}
+
+// demangledNames returns the Go identifiers of the mangled C++ global and
+// function names of the given module; mapping from LLVM IR global name to Go
+// identifier.
+//
+// Distinct symbols which share the same qualified name (e.g. overloaded
+// functions) are disambiguated by a numeric suffix in module order (e.g.
+// foo_bar and foo_bar_1), as are demangled identifiers which clash with the
+// identifiers of other global names.
+func demangledNames(module *ir.Module) map[string]string {
+	var names []string
+	for _, g := range module.Globals {
+		names = append(names, g.Name())
+	}
+	for _, f := range module.Funcs {
+		names = append(names, f.Name())
+	}
+	taken := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := demangle(name); !ok {
+			taken[newIdent(name).Name] = true
+		}
+	}
+	idents := make(map[string]string)
+	for _, name := range names {
+		base, ok := demangleIdent(name)
+		if !ok {
+			continue
+		}
+		ident := base
+		for i := 1; taken[ident]; i++ {
+			ident = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[ident] = true
+		idents[name] = ident
+	}
+	return idents
+}
+
+// A demangler parses the qualified name of a mangled C++ symbol name.
+type demangler struct {
+	// Mangled symbol name.
+	s string
+	// Current position within the mangled symbol name.
+	pos int
+}
+
+// peek returns the next n bytes of the mangled symbol name, or fewer at the end
+// of the symbol name.
+func (p *demangler) peek(n int) string {
+	end := p.pos + n
+	if end > len(p.s) {
+		end = len(p.s)
+	}
+	return p.s[p.pos:end]
+}
+
+// consume consumes the given prefix at the current position. The boolean return
+// value indicates whether the prefix was present.
+func (p *demangler) consume(prefix string) bool {
+	if !strings.HasPrefix(p.s[p.pos:], prefix) {
+		return false
+	}
+	p.pos += len(prefix)
+	return true
+}
+
+// encoding parses the encoding of a symbol; i.e. its name, followed by the
+// parameter types of functions, which are skipped.
+//
+//    <encoding> ::= <name> [<bare-function-type>]
+func (p *demangler) encoding() ([]string, bool) {
+	parts, ok := p.name()
+	if !ok {
+		return nil, false
+	}
+	for p.pos < len(p.s) && p.s[p.pos] != 'E' {
+		if !p.skipType() {
+			return nil, false
+		}
+	}
+	return parts, true
+}
+
+// name parses the qualified name of a symbol.
+//
+//    <name> ::= N [<CV-qualifiers>] [<ref-qualifier>] <prefix> E
+//           ::= Z <encoding> E <name> [<discriminator>]
+//           ::= St <unqualified-name> [<template-args>]
+//           ::= <unqualified-name> [<template-args>]
+func (p *demangler) name() ([]string, bool) {
+	switch {
+	case p.consume("N"):
+		return p.nestedName()
+	case p.consume("Z"):
+		return p.localName()
+	case p.consume("St"):
+		part, ok := p.unqualifiedName()
+		if !ok || !p.skipTemplateArgs() {
+			return nil, false
+		}
+		return []string{"std", part}, true
+	}
+	part, ok := p.unqualifiedName()
+	if !ok || !p.skipTemplateArgs() {
+		return nil, false
+	}
+	return []string{part}, true
+}
+
+// nestedName parses the components of a nested name, after the leading N.
+// Template arguments of the components are skipped.
+func (p *demangler) nestedName() ([]string, bool) {
+	// CV-qualifiers and ref-qualifiers of member functions.
+	for p.consume("r") || p.consume("V") || p.consume("K") {
+	}
+	if !p.consume("R") {
+		p.consume("O")
+	}
+	var parts []string
+	for !p.consume("E") {
+		if p.pos >= len(p.s) {
+			return nil, false
+		}
+		switch {
+		case p.consume("St"):
+			parts = append(parts, "std")
+			continue
+		case p.peek(1) == "S":
+			part, ok := p.stdAbbrev()
+			if !ok {
+				return nil, false
+			}
+			parts = append(parts, part...)
+		case p.peek(1) == "I":
+			if len(parts) == 0 || !p.skipTemplateArgs() {
+				return nil, false
+			}
+			continue
+		default:
+			// Constructors and destructors are named after their class.
+			var part string
+			switch p.peek(1) {
+			case "C", "D":
+				if len(parts) == 0 || !p.ctorDtor() {
+					return nil, false
+				}
+				part = parts[len(parts)-1]
+				if p.s[p.pos-2] == 'D' {
+					part = "~" + part
+				}
+			default:
+				var ok bool
+				if part, ok = p.unqualifiedName(); !ok {
+					return nil, false
+				}
+			}
+			parts = append(parts, part)
+		}
+	}
+	return parts, len(parts) > 0
+}
+
+// localName parses the components of a local name, after the leading Z; i.e.
+// the qualified name of the enclosing function, followed by the name of the
+// local entity.
+func (p *demangler) localName() ([]string, bool) {
+	parts, ok := p.encoding()
+	if !ok || !p.consume("E") {
+		return nil, false
+	}
+	// String literals have no name.
+	if p.peek(1) == "s" {
+		return nil, false
+	}
+	local, ok := p.name()
+	if !ok {
+		return nil, false
+	}
+	// Discriminator of local entities sharing the same name.
+	if p.consume("_") {
+		if p.consume("_") {
+			p.number()
+			if !p.consume("_") {
+				return nil, false
+			}
+		} else if _, ok := p.number(); !ok {
+			return nil, false
+		}
+	}
+	return append(parts, local...), true
+}
+
+// ctorDtor parses a constructor or destructor name.
+//
+//    <ctor-dtor-name> ::= C1 | C2 | C3 | D0 | D1 | D2
+func (p *demangler) ctorDtor() bool {
+	switch p.peek(2) {
+	case "C1", "C2", "C3", "D0", "D1", "D2":
+		p.pos += 2
+		return true
+	}
+	return false
+}
+
+// unqualifiedName parses an unqualified name; i.e. a source name, optionally
+// preceded by L for internal linkage, or an operator name. ABI tags (e.g.
+// B5cxx11) are skipped.
+//
+//    <unqualified-name> ::= [L] <source-name>
+//                       ::= <operator-name>
+func (p *demangler) unqualifiedName() (string, bool) {
+	p.consume("L")
+	var part string
+	if c := p.peek(1); len(c) == 1 && c[0] >= 'a' && c[0] <= 'z' {
+		op, ok := operatorNames[p.peek(2)]
+		if !ok {
+			return "", false
+		}
+		p.pos += 2
+		part = "operator_" + op
+	} else {
+		var ok bool
+		if part, ok = p.sourceName(); !ok {
+			return "", false
+		}
+	}
+	for p.consume("B") {
+		if _, ok := p.sourceName(); !ok {
+			return "", false
+		}
+	}
+	return part, true
+}
+
+// operatorNames maps from the mangled operator names of the Itanium C++ ABI to
+// the names of operators in Go identifiers.
+var operatorNames = map[string]string{
+	"nw": "new",
+	"na": "new_array",
+	"dl": "delete",
+	"da": "delete_array",
+	"ps": "plus",
+	"ng": "neg",
+	"ad": "addr",
+	"de": "deref",
+	"co": "not",
+	"pl": "add",
+	"mi": "sub",
+	"ml": "mul",
+	"dv": "div",
+	"rm": "rem",
+	"an": "and",
+	"or": "or",
+	"eo": "xor",
+	"aS": "assign",
+	"pL": "add_assign",
+	"mI": "sub_assign",
+	"mL": "mul_assign",
+	"dV": "div_assign",
+	"rM": "rem_assign",
+	"aN": "and_assign",
+	"oR": "or_assign",
+	"eO": "xor_assign",
+	"ls": "shl",
+	"rs": "shr",
+	"lS": "shl_assign",
+	"rS": "shr_assign",
+	"eq": "eq",
+	"ne": "ne",
+	"lt": "lt",
+	"gt": "gt",
+	"le": "le",
+	"ge": "ge",
+	"nt": "lnot",
+	"aa": "land",
+	"oo": "lor",
+	"pp": "inc",
+	"mm": "dec",
+	"cm": "comma",
+	"pm": "arrow_star",
+	"pt": "arrow",
+	"cl": "call",
+	"ix": "index",
+}
+
+// sourceName parses a source name; i.e. an identifier preceded by its length.
+// Names of anonymous namespaces (e.g. _GLOBAL__N_1) are given the name
+// "anonymous".
+//
+//    <source-name> ::= <positive length number> <identifier>
+func (p *demangler) sourceName() (string, bool) {
+	n, ok := p.number()
+	if !ok || n <= 0 || p.pos+n > len(p.s) {
+		return "", false
+	}
+	ident := p.s[p.pos : p.pos+n]
+	p.pos += n
+	if strings.HasPrefix(ident, "_GLOBAL__N") {
+		return "anonymous", true
+	}
+	return ident, true
+}
+
+// number parses a non-negative decimal number.
+func (p *demangler) number() (int, bool) {
+	start := p.pos
+	n := 0
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		n = n*10 + int(p.s[p.pos]-'0')
+		p.pos++
+	}
+	return n, p.pos > start
+}
+
+// stdAbbrev parses a standard abbreviation of a name within the std namespace,
+// followed by optional template arguments; other substitutions refer to
+// previously mangled components, which are not tracked.
+func (p *demangler) stdAbbrev() ([]string, bool) {
+	part, ok := stdAbbrevs[p.peek(2)]
+	if !ok {
+		return nil, false
+	}
+	p.pos += 2
+	if !p.skipTemplateArgs() {
+		return nil, false
+	}
+	return []string{"std", part}, true
+}
+
+// stdAbbrevs maps from the standard abbreviations of the Itanium C++ ABI to the
+// names they abbreviate within the std namespace.
+var stdAbbrevs = map[string]string{
+	"Sa": "allocator",
+	"Sb": "basic_string",
+	"Ss": "string",
+	"Si": "istream",
+	"So": "ostream",
+	"Sd": "iostream",
+}
+
+// skipTemplateArgs skips the template arguments at the current position, if
+// any. The boolean return value indicates success.
+//
+//    <template-args> ::= I <template-arg>+ E
+//    <template-arg>  ::= <type>
+//                    ::= L <type> <value number> E
+//                    ::= J <template-arg>* E
+func (p *demangler) skipTemplateArgs() bool {
+	if !p.consume("I") {
+		return true
+	}
+	return p.skipArgs()
+}
+
+// skipArgs skips the template arguments up to and including the terminating E.
+func (p *demangler) skipArgs() bool {
+	for !p.consume("E") {
+		switch {
+		case p.pos >= len(p.s):
+			return false
+		case p.consume("L"):
+			// Literals; e.g. Li1E.
+			if !p.skipType() {
+				return false
+			}
+			p.consume("n")
+			if _, ok := p.number(); !ok || !p.consume("E") {
+				return false
+			}
+		case p.consume("J"):
+			if !p.skipArgs() {
+				return false
+			}
+		default:
+			if !p.skipType() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// skipType skips the type at the current position. The boolean return value
+// indicates success.
+func (p *demangler) skipType() bool {
+	if p.pos >= len(p.s) {
+		return false
+	}
+	c := p.s[p.pos]
+	switch {
+	case strings.IndexByte("vwbcahstijlmxynofdegz", c) != -1:
+		// Builtin types.
+		p.pos++
+		return true
+	case strings.IndexByte("rVKPROCG", c) != -1:
+		// Qualified types, pointers and references.
+		p.pos++
+		return p.skipType()
+	case c == 'D':
+		switch p.peek(2) {
+		case "Dd", "De", "Df", "Dh", "Di", "Ds", "Du", "Da", "Dc", "Dn":
+			p.pos += 2
+			return true
+		case "Dp":
+			p.pos += 2
+			return p.skipType()
+		}
+		return false
+	case c == 'u':
+		// Vendor extended types.
+		p.pos++
+		_, ok := p.sourceName()
+		return ok
+	case c == 'F':
+		// Function types.
+		p.pos++
+		p.consume("Y")
+		for !p.consume("E") {
+			if p.consume("R") || p.consume("O") {
+				continue
+			}
+			if !p.skipType() {
+				return false
+			}
+		}
+		return true
+	case c == 'A':
+		// Array types of constant length.
+		p.pos++
+		p.number()
+		return p.consume("_") && p.skipType()
+	case c == 'M':
+		// Pointer to member types.
+		p.pos++
+		return p.skipType() && p.skipType()
+	case c == 'N':
+		p.pos++
+		_, ok := p.nestedName()
+		return ok
+	case c == 'T':
+		// Template parameters.
+		p.pos++
+		p.number()
+		return p.consume("_") && p.skipTemplateArgs()
+	case c == 'S':
+		if p.consume("St") {
+			_, ok := p.unqualifiedName()
+			return ok && p.skipTemplateArgs()
+		}
+		if _, ok := p.stdAbbrev(); ok {
+			return true
+		}
+		// Substitutions; e.g. S_ and S0_.
+		p.pos++
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z') {
+			p.pos++
+		}
+		return p.consume("_") && p.skipTemplateArgs()
+	case c >= '0' && c <= '9':
+		_, ok := p.sourceName()
+		return ok && p.skipTemplateArgs()
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package ll2go
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestDemangleIdent(t *testing.T) {
+	golden := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		// Free functions.
+		{name: "_Z3addii", want: "add", ok: true},
+		{name: "_Z4swapIiEvRT_S1_", want: "swap", ok: true},
+		// Member functions, constructors and destructors.
+		{name: "_ZN3foo3barEv", want: "foo_bar", ok: true},
+		{name: "_ZNK3foo4sizeEv", want: "foo_size", ok: true},
+		{name: "_ZN3fooC2Ei", want: "foo_foo", ok: true},
+		{name: "_ZN3fooD1Ev", want: "foo__foo", ok: true},
+		{name: "_ZN3fooplERKS_", want: "foo_operator_add", ok: true},
+		// Namespaces and templates.
+		{name: "_ZNSt6vectorIiSaIiEE9push_backERKi", want: "std_vector_push_back", ok: true},
+		{name: "_ZN12_GLOBAL__N_14initEv", want: "anonymous_init", ok: true},
+		{name: "_ZSt4endlIcSt11char_traitsIcEERSt13basic_ostreamIT_T0_ES6_", want: "std_endl", ok: true},
+		// Local names and special names.
+		{name: "_ZZ4mainE5count", want: "main_count", ok: true},
+		{name: "_ZTV3foo", want: "foo_vtable", ok: true},
+		// Variables and suffixes of optimization passes.
+		{name: "_ZL7counter", want: "counter", ok: true},
+		{name: "_ZN3foo3barEv.cold", want: "foo_bar", ok: true},
+		// Reserved identifiers.
+		{name: "_Z3lenPKc", want: "_len", ok: true},
+		// Names which are not mangled, or not supported.
+		{name: "main", ok: false},
+		{name: "_Z", ok: false},
+		{name: "_ZN3fooE", want: "foo", ok: true},
+		{name: "_ZN3foo", ok: false},
+		{name: "_Z3fooDTfp_E", ok: false},
+	}
+	for _, g := range golden {
+		got, ok := demangleIdent(g.name)
+		if ok != g.ok {
+			t.Errorf("%q: success mismatch; expected %v, got %v", g.name, g.ok, ok)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%q: identifier mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestDemangle(t *testing.T) {
+	//    static int counter;
+	//
+	//    namespace foo {
+	//       int bar(int x) { return x + counter; }
+	//       int bar(double x) { return (int)x; }
+	//    }
+	m := ir.NewModule()
+	counter := m.NewGlobalDef("_ZL7counter", constant.NewInt(types.I32, 0))
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("_ZN3foo3barEi", types.I32, x)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewAdd(x, entry.NewLoad(elemType(counter), counter)))
+	y := ir.NewParam("x", types.Double)
+	g := m.NewFunc("_ZN3foo3barEd", types.I32, y)
+	entry = g.NewBlock("entry")
+	entry.NewRet(entry.NewFPToSI(y, types.I32))
+	h := m.NewFunc("h", types.I32)
+	entry = h.NewBlock("entry")
+	entry.NewRet(entry.NewCall(g, constant.NewFloat(types.Double, 1)))
+
+	d := NewDecompiler()
+	d.Demangle = true
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	// Overloaded functions are disambiguated by a numeric suffix, and the
+	// mangled names are kept in doc comments.
+	golden := []struct {
+		name string
+		doc  string
+	}{
+		{name: "counter", doc: "// counter has the mangled C++ name _ZL7counter.\n"},
+		{name: "foo_bar", doc: "// foo_bar has the mangled C++ name _ZN3foo3barEi.\n"},
+		{name: "foo_bar_1", doc: "// foo_bar_1 has the mangled C++ name _ZN3foo3barEd.\n"},
+		{name: "h", doc: ""},
+	}
+	if len(file.Decls) != len(golden) {
+		t.Fatalf("number of declarations mismatch; expected %d, got %d", len(golden), len(file.Decls))
+	}
+	for i, decl := range file.Decls {
+		var name string
+		var doc *ast.CommentGroup
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			name, doc = decl.Specs[0].(*ast.ValueSpec).Names[0].Name, decl.Doc
+		case *ast.FuncDecl:
+			name, doc = decl.Name.Name, decl.Doc
+		}
+		if want := golden[i].name; name != want {
+			t.Errorf("declaration %d: identifier mismatch; expected %q, got %q", i, want, name)
+		}
+		var got string
+		if doc != nil {
+			for _, c := range doc.List {
+				got += c.Text + "\n"
+			}
+		}
+		if want := golden[i].doc; got != want {
+			t.Errorf("%q: doc comment mismatch; expected %q, got %q", name, want, got)
+		}
+	}
+	if err := Verify("demangle.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
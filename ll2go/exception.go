@@ -0,0 +1,211 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// Exception handling of LLVM IR is lowered into Go panics and recovers.
+//
+// The call of an invoke terminator is executed within a function literal which
+// recovers from any panic in a deferred call, and assigns the recovered value
+// to the variable of the landingpad instruction of the unwind basic block. The
+// invoke is thereby turned into a conditional branch, which continues to the
+// normal basic block if no panic was recovered, and to the unwind basic block
+// otherwise.
+//
+//    func() {
+//       defer func() {
+//          lp = recover()
+//       }()
+//       x = f()
+//    }()
+//    if lp == nil {
+//       goto block_normal
+//    }
+//    goto block_lpad
+//
+// A resume terminator re-panics with the recovered value; i.e. `panic(lp)`.
+
+// invokeBlock returns the conceptual basic block of the given LLVM IR basic
+// block, which is terminated by an invoke terminator. The call of the invoke is
+// lowered into Go statements of the basic block, which is terminated by a
+// conditional branch on whether the call returned normally.
+func (fc *funcContext) invokeBlock(block *ir.Block, term *ir.TermInvoke) (*basicBlock, error) {
+	lpad, err := fc.landingPad(term.ExceptionRetTarget.(*ir.Block))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	call, err := fc.call(term.Invokee, term.Sig(), term.Args)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var stmts []ast.Stmt
+	var callStmt ast.Stmt = &ast.ExprStmt{X: call}
+	if _, ok := multiResults(term.Sig().RetType); ok {
+		stmts = append(stmts, fc.varDecl(term.Name(), fc.GoType(term.Sig().RetType)))
+		callStmt = fc.assignResults(term.Name(), term.Sig().RetType, call)
+	} else if !types.Equal(term.Sig().RetType, types.Void) {
+		stmts = append(stmts, fc.varDecl(term.Name(), fc.GoType(term.Sig().RetType)))
+		callStmt = fc.assign(term.Name(), call)
+	}
+	// defer func() {
+	//    lp = recover()
+	// }()
+	recoverCall := &ast.CallExpr{Fun: ast.NewIdent("recover")}
+	deferStmt := &ast.DeferStmt{
+		Call: &ast.CallExpr{
+			Fun: &ast.FuncLit{
+				Type: &ast.FuncType{Params: &ast.FieldList{}},
+				Body: &ast.BlockStmt{List: []ast.Stmt{fc.assign(lpad.Name(), recoverCall)}},
+			},
+		},
+	}
+	lit := &ast.FuncLit{
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{deferStmt, callStmt}},
+	}
+	stmts = append(stmts, &ast.ExprStmt{X: &ast.CallExpr{Fun: lit}})
+
+	// Replace the invoke terminator with a conditional branch, leaving the
+	// original basic block unmodified.
+	b := *block
+	b.Term = &ir.TermCondBr{
+		Cond:        &returned{lpad: lpad},
+		TargetTrue:  term.NormalRetTarget,
+		TargetFalse: term.ExceptionRetTarget,
+		Metadata:    term.Metadata,
+	}
+	return &basicBlock{Block: &b, stmts: stmts}, nil
+}
+
+// landingPad returns the landingpad instruction of the given unwind basic
+// block. Unwind basic blocks of other exception handling models (e.g. starting
+// with a catchswitch terminator or a cleanuppad instruction) are not yet
+// supported.
+func (d *Decompiler) landingPad(block *ir.Block) (*ir.InstLandingPad, error) {
+	for _, inst := range block.Insts {
+		switch inst := inst.(type) {
+		case *ir.InstPhi:
+			// PHI instructions precede the landingpad instruction.
+		case *ir.InstLandingPad:
+			return inst, nil
+		default:
+			if err := d.unsupported("unwind basic block instruction", inst); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return nil, errors.Errorf("unable to lower invoke; unwind basic block %q starts with %T instead of landingpad instruction", block.Name(), inst)
+		}
+	}
+	if err := d.unsupported("unwind basic block terminator", block.Term); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return nil, errors.Errorf("unable to lower invoke; unwind basic block %q has no landingpad instruction", block.Name())
+}
+
+// landingPadDecls returns the declarations of the variables of the landingpad
+// instructions of the function, which hold the recovered panic values and are
+// placed at the start of the function body. The declarations note the
+// personality function of the function, if any.
+//
+//    var lp interface{} /* personality: __gxx_personality_v0 */
+func (fc *funcContext) landingPadDecls() []ast.Stmt {
+	var stmts []ast.Stmt
+	for _, block := range fc.f.Blocks {
+		for _, inst := range block.Insts {
+			if lpad, ok := inst.(*ir.InstLandingPad); ok {
+				var typ ast.Expr = ast.NewIdent("interface{}")
+				if len(fc.personality) > 0 {
+					typ = fc.commented(typ, "personality: "+fc.personality)
+				}
+				stmts = append(stmts, fc.varDecl(lpad.Name(), typ))
+			}
+		}
+	}
+	return stmts
+}
+
+// personalityName returns the name of the given personality function of a
+// function definition (e.g. __gxx_personality_v0), looking through bitcasts; or
+// an empty string if none.
+//
+//    define void @f() personality i8* bitcast (i32 (...)* @__gxx_personality_v0 to i8*)
+func personalityName(personality constant.Constant) string {
+	for {
+		switch c := personality.(type) {
+		case nil:
+			return ""
+		case *constant.ExprBitCast:
+			personality = c.From
+		case *ir.Func:
+			return c.Name()
+		default:
+			return c.Ident()
+		}
+	}
+}
+
+// instLandingPad converts the given LLVM IR landingpad instruction into a Go
+// comment, as the variable of the landingpad instruction is assigned the
+// recovered panic value by the deferred call of the invoke (see invokeBlock).
+//
+//    // landingpad: lp recovered from panic
+func (d *Decompiler) instLandingPad(inst *ir.InstLandingPad) []ast.Stmt {
+	return d.commentStmts(fmt.Sprintf("landingpad: %s recovered from panic", d.local(inst.Name()).Name))
+}
+
+// termResume converts the given LLVM IR resume terminator into a Go panic
+// statement, which re-panics with the recovered value; e.g.
+//
+//    panic(lp)
+func (fc *funcContext) termResume(term *ir.TermResume) (ast.Stmt, error) {
+	x, err := fc.Value(term.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{x},
+	}
+	return &ast.ExprStmt{X: call}, nil
+}
+
+// returned is the condition of the conditional branch replacing an invoke
+// terminator, which holds if the call of the invoke returned normally; i.e. if
+// the variable of the landingpad instruction is nil, as no panic was
+// recovered.
+type returned struct {
+	// Landingpad instruction of the unwind basic block.
+	lpad *ir.InstLandingPad
+}
+
+// String returns the LLVM IR assembly of the condition.
+func (c *returned) String() string {
+	return fmt.Sprintf("returned %v", c.lpad.Ident())
+}
+
+// Type returns the type of the condition.
+func (c *returned) Type() types.Type {
+	return types.I1
+}
+
+// Ident returns the identifier associated with the condition.
+func (c *returned) Ident() string {
+	return c.String()
+}
+
+// returnedCond returns the Go expression of the given returned condition; i.e.
+// `lp == nil`.
+func (d *Decompiler) returnedCond(c *returned) ast.Expr {
+	return &ast.BinaryExpr{
+		X:  d.local(c.lpad.Name()),
+		Op: token.EQL,
+		Y:  ast.NewIdent("nil"),
+	}
+}
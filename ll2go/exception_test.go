@@ -0,0 +1,120 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestInvoke(t *testing.T) {
+	m := ir.NewModule()
+
+	// int may_throw(int x) {
+	//    return x;
+	// }
+	x := ir.NewParam("x", types.I32)
+	mayThrow := m.NewFunc("may_throw", types.I32, x)
+	mayThrow.NewBlock("entry").NewRet(x)
+
+	// int f(int x) {
+	//    try {
+	//       return may_throw(x);
+	//    } catch (...) {
+	//       return -1;
+	//    }
+	// }
+	x = ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	normal := f.NewBlock("normal")
+	lpad := f.NewBlock("lpad")
+	r := entry.NewInvoke(mayThrow, []value.Value{x}, normal, lpad)
+	r.SetName("r")
+	normal.NewRet(r)
+	lp := lpad.NewLandingPad(types.NewStruct(types.NewPointer(types.I8), types.I32), &ir.Clause{Type: enum.ClauseTypeCatch, X: constant.NewNull(types.NewPointer(types.I8))})
+	lp.SetName("lp")
+	lpad.NewRet(constant.NewInt(types.I32, -1))
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `package main
+
+func may_throw(x int32) int32 {
+	return x
+}
+func f(x int32) int32 {
+	var lp interface{}
+	var r int32
+	func() {
+		defer func() {
+			lp = recover()
+		}()
+		r = may_throw(x)
+	}()
+	if lp == nil {
+		return r
+	}
+	// landingpad: lp recovered from panic
+	return -1
+}
+`
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("invoke.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
+
+func TestPersonality(t *testing.T) {
+	m := ir.NewModule()
+	mayThrow := m.NewFunc("may_throw", types.Void)
+	personality := m.NewFunc("__gxx_personality_v0", types.I32)
+	personality.Sig.Variadic = true
+
+	// define void @f() personality i8* bitcast (i32 (...)* @__gxx_personality_v0 to i8*) {
+	// entry:
+	//    invoke void @may_throw() to label %normal unwind label %lpad
+	// normal:
+	//    ret void
+	// lpad:
+	//    %lp = landingpad { i8*, i32 } cleanup
+	//    resume { i8*, i32 } %lp
+	// }
+	f := m.NewFunc("f", types.Void)
+	f.Personality = constant.NewBitCast(personality, types.NewPointer(types.I8))
+	entry := f.NewBlock("entry")
+	normal := f.NewBlock("normal")
+	lpad := f.NewBlock("lpad")
+	entry.NewInvoke(mayThrow, nil, normal, lpad)
+	normal.NewRet(nil)
+	lp := lpad.NewLandingPad(types.NewStruct(types.NewPointer(types.I8), types.I32))
+	lp.SetName("lp")
+	lp.Cleanup = true
+	lpad.NewResume(lp)
+
+	fc := withComments(NewDecompiler()).newFuncContext()
+	fn, err := fc.funcDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	if want := "__gxx_personality_v0"; fc.personality != want {
+		t.Errorf("personality mismatch; expected %q, got %q", want, fc.personality)
+	}
+	want := "func f() {\n\tvar lp interface{}\t/* personality: __gxx_personality_v0 */\n\tfunc() {\n\t\tdefer func() {\n\t\t\tlp = recover()\n\t\t}()\n\t\tmay_throw()\n\t}()\n\tif lp == nil {\n\t\tgoto block_normal\n\t} else {\n\t\tgoto block_lpad\n\t}\nblock_normal:\n\treturn\nblock_lpad:\n\t// landingpad: lp recovered from panic\n\tpanic(lp)\n}"
+	if got := commentString(t, fc.Decompiler, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,221 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// GlobalDecl converts the given LLVM IR global variable into a corresponding Go
+// variable declaration; e.g.
+//
+//    @primes = global [4 x i32] [i32 2, i32 3, i32 5, i32 7]
+//
+// is converted into
+//
+//    var primes [4]int32 = [4]int32{2, 3, 5, 7}
+//
+// External global variables, which have no initializer, are converted into
+// variable declarations with a comment noting that they are defined externally.
+func (d *Decompiler) GlobalDecl(g *ir.Global) (*ast.GenDecl, error) {
+	return d.globalDecl(g, false)
+}
+
+// globalDecl converts the given LLVM IR global variable into a corresponding Go
+// variable declaration, or into a constant declaration if isConst is set.
+func (d *Decompiler) globalDecl(g *ir.Global, isConst bool) (*ast.GenDecl, error) {
+	spec := &ast.ValueSpec{
+		Names: []*ast.Ident{d.global(g.Name())},
+		Type:  d.GoType(g.ContentType),
+	}
+	switch init := g.Init.(type) {
+	case nil:
+		spec.Type = d.commented(spec.Type, "external")
+	case *constant.ZeroInitializer:
+		// Go variables are zero-initialized.
+	default:
+		x, err := d.Value(init)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		spec.Values = []ast.Expr{x}
+	}
+	tok := token.VAR
+	if isConst {
+		tok = token.CONST
+	}
+	decl := &ast.GenDecl{
+		Tok:   tok,
+		Specs: []ast.Spec{spec},
+	}
+	if c := d.mangledDoc(g.Name(), spec.Names[0].Name); c != nil {
+		decl.Doc = &ast.CommentGroup{List: []*ast.Comment{c}}
+	}
+	return decl, nil
+}
+
+// constGlobals returns the set of global variables of the given module which
+// may be declared as Go constants; i.e. constant global variables of integer or
+// floating-point type, which are only ever loaded from, as the address of a Go
+// constant cannot be taken.
+func constGlobals(module *ir.Module) map[*ir.Global]bool {
+	consts := make(map[*ir.Global]bool)
+	for _, g := range module.Globals {
+		if !g.Immutable {
+			continue
+		}
+		switch g.Init.(type) {
+		case *constant.Int, *constant.Float:
+			if !isBool(g.ContentType) {
+				consts[g] = true
+			}
+		}
+	}
+	for _, f := range module.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if _, ok := inst.(*ir.InstLoad); ok {
+					continue
+				}
+				for _, op := range operands(inst) {
+					if g, ok := op.(*ir.Global); ok {
+						delete(consts, g)
+					}
+				}
+			}
+			for _, op := range termOperands(block.Term) {
+				if g, ok := op.(*ir.Global); ok {
+					delete(consts, g)
+				}
+			}
+		}
+	}
+	// Global variables referenced by the initializers of other global variables
+	// have their address taken.
+	for _, g := range module.Globals {
+		for _, ref := range globalRefs(g.Init) {
+			delete(consts, ref)
+		}
+	}
+	return consts
+}
+
+// stringGlobals returns the set of global variables of the given module which
+// may be inlined as Go string literals at their use sites; i.e. private
+// unnamed_addr constant global variables of NUL-terminated text (e.g. printf
+// format strings), which are only ever referenced by the address of their first
+// character, as call arguments; e.g.
+//
+//    @.str = private unnamed_addr constant [4 x i8] c"%d\0A\00"
+//    call i32 (i8*, ...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0), i32 42)
+//
+// is converted into
+//
+//    printf("%d\n", int32(42))
+func stringGlobals(module *ir.Module) map[*ir.Global]bool {
+	strs := make(map[*ir.Global]bool)
+	for _, g := range module.Globals {
+		if !g.Immutable || g.UnnamedAddr != enum.UnnamedAddrUnnamedAddr || g.Linkage != enum.LinkagePrivate {
+			continue
+		}
+		buf, ok := charArray(g.Init)
+		if !ok || !bytes.HasSuffix(buf, []byte{0}) || !isText(string(buf[:len(buf)-1])) {
+			continue
+		}
+		strs[g] = true
+	}
+	if len(strs) == 0 {
+		return nil
+	}
+	// Drop global variables referenced in non-string contexts.
+	drop := func(ops []value.Value, isCall bool) {
+		for _, op := range ops {
+			if _, ok := stringRef(op); ok && isCall {
+				continue
+			}
+			if c, ok := op.(constant.Constant); ok {
+				for _, ref := range globalRefs(c) {
+					delete(strs, ref)
+				}
+			}
+		}
+	}
+	for _, f := range module.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				call, ok := inst.(*ir.InstCall)
+				if !ok {
+					drop(operands(inst), false)
+					continue
+				}
+				drop([]value.Value{call.Callee}, false)
+				drop(call.Args, true)
+			}
+			drop(termOperands(block.Term), false)
+		}
+	}
+	for _, g := range module.Globals {
+		for _, ref := range globalRefs(g.Init) {
+			delete(strs, ref)
+		}
+	}
+	return strs
+}
+
+// stringRef returns the global variable of the given value, if it is the
+// address of the first element of a global variable; e.g.
+//
+//    getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0)
+//
+// The boolean return value indicates success.
+func stringRef(v value.Value) (*ir.Global, bool) {
+	e, ok := v.(*constant.ExprGetElementPtr)
+	if !ok {
+		return nil, false
+	}
+	g, ok := e.Src.(*ir.Global)
+	if !ok || len(e.Indices) != 2 {
+		return nil, false
+	}
+	for _, index := range e.Indices {
+		if !isZero(index) {
+			return nil, false
+		}
+	}
+	return g, true
+}
+
+// globalRefs returns the global variables referenced by the given constant,
+// including through the operands of address constant expressions.
+func globalRefs(c constant.Constant) []*ir.Global {
+	var elems []constant.Constant
+	switch c := c.(type) {
+	case *ir.Global:
+		return []*ir.Global{c}
+	case *constant.Array:
+		elems = c.Elems
+	case *constant.Vector:
+		elems = c.Elems
+	case *constant.Struct:
+		elems = c.Fields
+	case *constant.ExprGetElementPtr:
+		elems = []constant.Constant{c.Src}
+	case *constant.ExprBitCast:
+		elems = []constant.Constant{c.From}
+	case *constant.ExprPtrToInt:
+		elems = []constant.Constant{c.From}
+	case *constant.ExprAddrSpaceCast:
+		elems = []constant.Constant{c.From}
+	}
+	var refs []*ir.Global
+	for _, elem := range elems {
+		refs = append(refs, globalRefs(elem)...)
+	}
+	return refs
+}
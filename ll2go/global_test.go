@@ -0,0 +1,136 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestGlobalDecl(t *testing.T) {
+	// @primes = global [4 x i32] [i32 2, i32 3, i32 5, i32 7]
+	// @n = constant i32 4
+	// @buf = global [8 x i8] zeroinitializer
+	// @errno = external global i32
+	//
+	// define i32 @f(i32 %i) {
+	//    %1 = getelementptr [4 x i32], [4 x i32]* @primes, i32 0, i32 %i
+	//    %2 = load i32, i32* %1
+	//    %3 = load i32, i32* @n
+	//    %4 = add i32 %2, %3
+	//    store i32 %4, i32* @errno
+	//    ret i32 %4
+	// }
+	m := ir.NewModule()
+	i32 := func(x int64) constant.Constant {
+		return constant.NewInt(types.I32, x)
+	}
+	primes := m.NewGlobalDef("primes", constant.NewArray(types.NewArray(4, types.I32), i32(2), i32(3), i32(5), i32(7)))
+	n := m.NewGlobalDef("n", i32(4))
+	n.Immutable = true
+	m.NewGlobalDef("buf", constant.NewZeroInitializer(types.NewArray(8, types.I8)))
+	errno := m.NewGlobal("errno", types.I32)
+	i := ir.NewParam("i", types.I32)
+	f := m.NewFunc("f", types.I32, i)
+	entry := f.NewBlock("entry")
+	elem := entry.NewGetElementPtr(elemType(primes), primes, i32(0), i)
+	x := entry.NewLoad(elemType(elem), elem)
+	y := entry.NewLoad(elemType(n), n)
+	sum := entry.NewAdd(x, y)
+	entry.NewStore(sum, errno)
+	entry.NewRet(sum)
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	want := `package main
+
+var primes [4]int32 = [4]int32{2, 3, 5, 7}
+
+const n int32 = 4
+
+var buf [8]int8
+var errno int32 /* external */
+
+func f(i int32) int32 {
+	_0 := &primes[i]
+	_1 := *_0
+	_2 := n
+	_3 := _1 + _2
+	errno = _3
+	return _3
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("main.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
+
+func TestStringGlobals(t *testing.T) {
+	// @.str = private unnamed_addr constant [4 x i8] c"%d\0A\00"
+	// @.str.1 = private unnamed_addr constant [3 x i8] c"hi\00"
+	// @msg = global i8* null
+	//
+	// declare i32 @printf(i8*, ...)
+	//
+	// define void @f() {
+	//    %1 = call i32 (i8*, ...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0), i32 42)
+	//    store i8* getelementptr ([3 x i8], [3 x i8]* @.str.1, i64 0, i64 0), i8** @msg
+	//    ret void
+	// }
+	m := ir.NewModule()
+	i64 := func(x int64) constant.Constant {
+		return constant.NewInt(types.I64, x)
+	}
+	str := m.NewGlobalDef(".str", constant.NewCharArray([]byte("%d\n\x00")))
+	str1 := m.NewGlobalDef(".str.1", constant.NewCharArray([]byte("hi\x00")))
+	for _, g := range []*ir.Global{str, str1} {
+		g.Immutable = true
+		g.Linkage = enum.LinkagePrivate
+		g.UnnamedAddr = enum.UnnamedAddrUnnamedAddr
+	}
+	i8ptr := types.NewPointer(types.I8)
+	msg := m.NewGlobalDef("msg", constant.NewNull(i8ptr))
+	printf := m.NewFunc("printf", types.I32, ir.NewParam("format", i8ptr))
+	printf.Sig.Variadic = true
+	f := m.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	entry.NewCall(printf, constant.NewGetElementPtr(elemType(str), str, i64(0), i64(0)), constant.NewInt(types.I32, 42))
+	entry.NewStore(constant.NewGetElementPtr(elemType(str1), str1, i64(0), i64(0)), msg)
+	entry.NewRet(nil)
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	want := `package main
+
+var _str_1 [3]int8 = "hi"
+var msg *int8 = nil
+
+func f() {
+	_0 := printf("%d\n", int32(42))
+	msg = &_str_1[0]
+	return
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+}
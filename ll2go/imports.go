@@ -0,0 +1,92 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// An importSet keeps track of the packages referenced by the Go source code
+// decompiled from a module, so that the corresponding imports may be added to
+// the Go source file.
+//
+// An importSet may be used concurrently by multiple goroutines.
+type importSet struct {
+	sync.Mutex
+	// Import paths of referenced packages.
+	paths map[string]bool
+}
+
+// newImportSet returns a new import set.
+func newImportSet() *importSet {
+	return &importSet{
+		paths: make(map[string]bool),
+	}
+}
+
+// pkgSel returns the selector expression of the given identifier of the
+// package with the given import path (e.g. math.NaN and bits.OnesCount for
+// "math/bits"), and records the package in the import set of the decompiler.
+func (d *Decompiler) pkgSel(importPath, name string) ast.Expr {
+	if d.imports != nil {
+		d.imports.Lock()
+		d.imports.paths[importPath] = true
+		d.imports.Unlock()
+	}
+	return &ast.SelectorExpr{X: ast.NewIdent(path.Base(importPath)), Sel: ast.NewIdent(name)}
+}
+
+// decl returns an import declaration of the referenced packages, sorted by
+// import path; or nil if no packages are referenced.
+func (s *importSet) decl() *ast.GenDecl {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.paths) == 0 {
+		return nil
+	}
+	var paths []string
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	decl := &ast.GenDecl{
+		Tok: token.IMPORT,
+	}
+	for _, path := range paths {
+		spec := &ast.ImportSpec{
+			Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)},
+		}
+		decl.Specs = append(decl.Specs, spec)
+	}
+	// Group multiple imports within parentheses.
+	if len(decl.Specs) > 1 {
+		decl.Lparen = 1
+	}
+	return decl
+}
+
+// prune removes the packages of the import set which are not referenced by the
+// given declarations.
+func (s *importSet) prune(decls []ast.Decl) {
+	used := make(map[string]bool)
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok {
+					used[x.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	s.Lock()
+	defer s.Unlock()
+	for importPath := range s.paths {
+		if !used[path.Base(importPath)] {
+			delete(s.paths, importPath)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestImports(t *testing.T) {
+	// Referenced packages are imported once, sorted by import path.
+	m := ir.NewModule()
+	nan := &constant.Float{Typ: types.Double, NaN: true}
+	for _, name := range []string{"f", "g"} {
+		f := m.NewFunc(name, types.Double)
+		f.NewBlock("entry").NewRet(nan)
+	}
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	h := m.NewFunc("h", types.I64, p)
+	entry := h.NewBlock("entry")
+	entry.NewRet(entry.NewPtrToInt(p, types.I64))
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	src := buf.String()
+	if n := strings.Count(src, `"math"`); n != 1 {
+		t.Errorf("expected \"math\" to be imported once, got %d imports in %q", n, src)
+	}
+	const want = "package main\n\nimport (\n\t\"math\"\n\t\"unsafe\"\n)\n"
+	if !strings.HasPrefix(src, want) {
+		t.Errorf("import mismatch; expected prefix %q, got %q", want, src)
+	}
+	if err := Verify("main.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
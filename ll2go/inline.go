@@ -0,0 +1,536 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// inlineTemps inlines the single-use temporaries of the given Go function
+// declaration into their use, and declares the remaining temporaries in a var
+// block at the start of the function body; e.g.
+//
+//    _0 := x + 1        =>    var _2 int32
+//    _1 := _0 * 2              *q = (x+1)*2
+//    *q = _1                   _2 = *p
+//    _2 := *p                  if c {
+//    if c {                       return _2
+//       return _2              }
+//    }                         return _2
+//    return _2
+//
+// Temporaries without side effects are inlined into a later statement of the
+// same statement list, unless the variables they refer to are assigned in
+// between, or a labeled statement is passed. Temporaries with side effects or
+// which read memory (e.g. loads and calls) are only inlined into the directly
+// following statement, if it has no other side effects; thus preserving the
+// order of evaluation. Temporaries are only inlined into the expressions
+// evaluated once by a statement (e.g. the condition of an if statement), never
+// into nested statements.
+func (fc *funcContext) inlineTemps(fn *ast.FuncDecl) {
+	if !fc.InlineTemps {
+		return
+	}
+	t := &temps{
+		fc:     fc,
+		insts:  make(map[string]value.Named),
+		values: map[string]bool{vaArgs: true},
+		uses:   make(map[string]int),
+	}
+	for _, field := range fn.Type.Params.List {
+		for _, name := range field.Names {
+			t.values[name.Name] = true
+		}
+	}
+	for _, block := range fc.f.Blocks {
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Named)
+			if !ok || len(v.Name()) == 0 {
+				continue
+			}
+			// Local variables of alloca instructions are located in memory.
+			if _, ok := inst.(*ir.InstAlloca); ok {
+				continue
+			}
+			name := fc.local(v.Name()).Name
+			t.insts[name] = v
+			t.values[name] = true
+		}
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			t.uses[ident.Name]++
+		}
+		return true
+	})
+	stmtLists(fn.Body, t.stmts)
+	if decl := t.hoist(fn.Body); decl != nil {
+		fn.Body.List = append([]ast.Stmt{decl}, fn.Body.List...)
+	}
+}
+
+// temps keeps track of the temporaries of a function being inlined.
+type temps struct {
+	fc *funcContext
+	// Instructions of temporaries; mapping from Go variable name to LLVM IR
+	// instruction.
+	insts map[string]value.Named
+	// Go variables which are not located in memory; i.e. parameters and the
+	// temporaries of instructions.
+	values map[string]bool
+	// Number of occurrences of each identifier in the function body, including
+	// definitions.
+	uses map[string]int
+}
+
+// stmts inlines the single-use temporaries defined by the given list of
+// statements, and returns the resulting list of statements.
+func (t *temps) stmts(stmts []ast.Stmt) []ast.Stmt {
+	for i := 0; i < len(stmts); i++ {
+		name, expr, ok := simpleAssign(stmts[i], token.DEFINE)
+		if !ok {
+			continue
+		}
+		// The temporary is used once, as seen both in LLVM IR and in Go; uses
+		// within commented expressions are not visible in Go.
+		inst, ok := t.insts[name]
+		if !ok || t.uses[name] != 2 || t.fc.numUses(inst) != 1 {
+			continue
+		}
+		j, ok := t.useStmt(stmts, i, name, expr)
+		if !ok || !inline(stmts[j], name, expr) {
+			continue
+		}
+		stmts = append(stmts[:i], stmts[i+1:]...)
+		i--
+	}
+	return stmts
+}
+
+// useStmt returns the index of the statement using the temporary of the given
+// name and expression, as defined by the i:th statement of stmts. The boolean
+// return value indicates whether the temporary may be inlined into the
+// statement, as its value would not change.
+func (t *temps) useStmt(stmts []ast.Stmt, i int, name string, expr ast.Expr) (int, bool) {
+	pure := t.pure(expr, false)
+	refs := make(map[string]bool)
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			refs[ident.Name] = true
+		}
+		return true
+	})
+	for j := i + 1; j < len(stmts); j++ {
+		stmt := stmts[j]
+		if _, ok := stmt.(*ast.LabeledStmt); ok {
+			return 0, false
+		}
+		if refersTo(stmt, name) {
+			// Temporaries with side effects are only inlined into statements
+			// without other side effects.
+			return j, pure || t.pureStmt(stmt, name)
+		}
+		// Empty statements (e.g. the anchors of comments) have no effect.
+		if _, ok := stmt.(*ast.EmptyStmt); ok {
+			continue
+		}
+		if !pure || assigns(stmt, refs) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// pure reports whether the given Go expression has no side effects, and does
+// not read memory; where addr is set if the address of the expression is
+// taken, rather than its value read.
+func (t *temps) pure(expr ast.Expr, addr bool) bool {
+	switch expr := expr.(type) {
+	case nil:
+		return true
+	case *ast.Ident:
+		switch expr.Name {
+		case "nil", "true", "false":
+			return true
+		}
+		return addr || t.values[expr.Name]
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return t.pure(expr.X, addr)
+	case *ast.UnaryExpr:
+		return t.pure(expr.X, expr.Op == token.AND)
+	case *ast.BinaryExpr:
+		return t.pure(expr.X, false) && t.pure(expr.Y, false)
+	case *ast.StarExpr:
+		return addr && t.pure(expr.X, false)
+	case *ast.SelectorExpr:
+		return t.pure(expr.X, addr)
+	case *ast.IndexExpr:
+		return t.pure(expr.X, addr) && t.pure(expr.Index, false)
+	case *ast.SliceExpr:
+		return t.pure(expr.X, true) && t.pure(expr.Low, false) && t.pure(expr.High, false) && t.pure(expr.Max, false)
+	case *ast.TypeAssertExpr:
+		return t.pure(expr.X, false)
+	case *ast.CompositeLit:
+		for _, elt := range expr.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			if !t.pure(elt, false) {
+				return false
+			}
+		}
+		return true
+	case *ast.CallExpr:
+		// Conversions have no side effects.
+		if !isConversion(expr) || len(expr.Args) != 1 {
+			return false
+		}
+		return t.pure(expr.Args[0], false)
+	default:
+		// Function literals, etc.
+		return false
+	}
+}
+
+// pureStmt reports whether the expressions evaluated by the given statement
+// have no side effects and do not read memory, except for the occurrence of the
+// temporary of the given name.
+func (t *temps) pureStmt(stmt ast.Stmt, name string) bool {
+	slots, ok := exprSlots(stmt)
+	if !ok {
+		return false
+	}
+	lhs := make(map[*ast.Expr]bool)
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		for i := range assign.Lhs {
+			lhs[&assign.Lhs[i]] = true
+		}
+	}
+	for _, slot := range slots {
+		expr := *slot
+		// Assigned variables and pointer indirections are not read.
+		if lhs[slot] {
+			if _, ok := expr.(*ast.Ident); ok {
+				continue
+			}
+			if star, ok := expr.(*ast.StarExpr); ok {
+				expr = star.X
+			}
+		}
+		if !t.pureExcept(expr, name, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// pureExcept reports whether the given Go expression has no side effects and
+// does not read memory, except for the occurrence of the temporary of the given
+// name; where addr is set if the address of the expression is taken.
+//
+// The operands of a call are evaluated before the call; thus calls only have
+// side effects after the temporary is evaluated. Temporaries with side effects
+// are never inlined into the conditionally evaluated operand of a logical
+// operator.
+func (t *temps) pureExcept(expr ast.Expr, name string, addr bool) bool {
+	if expr == nil || !refersTo(expr, name) {
+		return t.pure(expr, addr)
+	}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.ParenExpr:
+		return t.pureExcept(expr.X, name, addr)
+	case *ast.UnaryExpr:
+		return t.pureExcept(expr.X, name, expr.Op == token.AND)
+	case *ast.StarExpr:
+		return t.pureExcept(expr.X, name, false)
+	case *ast.BinaryExpr:
+		if (expr.Op == token.LAND || expr.Op == token.LOR) && refersTo(expr.Y, name) {
+			return false
+		}
+		return t.pureExcept(expr.X, name, false) && t.pureExcept(expr.Y, name, false)
+	case *ast.SelectorExpr:
+		return t.pureExcept(expr.X, name, addr)
+	case *ast.IndexExpr:
+		return t.pureExcept(expr.X, name, addr) && t.pureExcept(expr.Index, name, false)
+	case *ast.SliceExpr:
+		return t.pureExcept(expr.X, name, true) && t.pureExcept(expr.Low, name, false) && t.pureExcept(expr.High, name, false) && t.pureExcept(expr.Max, name, false)
+	case *ast.TypeAssertExpr:
+		return t.pureExcept(expr.X, name, false)
+	case *ast.CallExpr:
+		// Functions are referred to by name.
+		switch expr.Fun.(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+		default:
+			if !t.pureExcept(expr.Fun, name, false) {
+				return false
+			}
+		}
+		for _, arg := range expr.Args {
+			if !t.pureExcept(arg, name, false) {
+				return false
+			}
+		}
+		return true
+	case *ast.CompositeLit:
+		for _, elt := range expr.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			if !t.pureExcept(elt, name, false) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// hoist turns the remaining short variable declarations of temporaries within
+// the given function body into assignments, and returns a var block declaring
+// the temporaries; or nil if none.
+func (t *temps) hoist(body *ast.BlockStmt) ast.Stmt {
+	gen := &ast.GenDecl{Tok: token.VAR}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Keep the temporaries of function literals local.
+			return false
+		case *ast.AssignStmt:
+			name, _, ok := simpleAssign(n, token.DEFINE)
+			if !ok || t.insts[name] == nil {
+				break
+			}
+			n.Tok = token.ASSIGN
+			spec := &ast.ValueSpec{
+				Names: []*ast.Ident{ast.NewIdent(name)},
+				Type:  t.fc.GoType(t.insts[name].Type()),
+			}
+			gen.Specs = append(gen.Specs, spec)
+		}
+		return true
+	})
+	if len(gen.Specs) == 0 {
+		return nil
+	}
+	if len(gen.Specs) > 1 {
+		gen.Lparen = 1
+	}
+	return &ast.DeclStmt{Decl: gen}
+}
+
+// inline replaces the occurrence of the temporary of the given name within the
+// expressions evaluated by the given statement by its expression, and reports
+// whether the temporary was inlined.
+func inline(stmt ast.Stmt, name string, expr ast.Expr) bool {
+	slots, ok := exprSlots(stmt)
+	if !ok {
+		return false
+	}
+	for _, slot := range slots {
+		if substitute(slot, name, expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces the identifier of the given name within the expression
+// of the given slot by repl, and reports whether the identifier was found.
+// Replacements are parenthesized as required by the precedence of their
+// context.
+func substitute(slot *ast.Expr, name string, repl ast.Expr) bool {
+	if ident, ok := (*slot).(*ast.Ident); ok && ident.Name == name {
+		*slot = repl
+		return true
+	}
+	// sub substitutes the identifier within the given operand, which is
+	// parenthesized if the precedence of the replacement is lower than prec.
+	sub := func(x *ast.Expr, prec int) bool {
+		if !substitute(x, name, repl) {
+			return false
+		}
+		if *x == repl && exprPrec(repl) < prec {
+			*x = &ast.ParenExpr{X: repl}
+		}
+		return true
+	}
+	switch expr := (*slot).(type) {
+	case *ast.ParenExpr:
+		return sub(&expr.X, 0)
+	case *ast.UnaryExpr:
+		return sub(&expr.X, token.UnaryPrec+1)
+	case *ast.StarExpr:
+		if !sub(&expr.X, token.UnaryPrec) {
+			return false
+		}
+		// Simplify the indirection of address expressions; e.g. *&a[i] => a[i].
+		if addr, ok := expr.X.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+			*slot = addr.X
+		}
+		return true
+	case *ast.BinaryExpr:
+		prec := expr.Op.Precedence()
+		// Binary operators are left-associative.
+		return sub(&expr.X, prec) || sub(&expr.Y, prec+1)
+	case *ast.SelectorExpr:
+		return sub(&expr.X, token.HighestPrec)
+	case *ast.IndexExpr:
+		return sub(&expr.X, token.HighestPrec) || sub(&expr.Index, 0)
+	case *ast.SliceExpr:
+		return sub(&expr.X, token.HighestPrec) || sub(&expr.Low, 0) || sub(&expr.High, 0) || sub(&expr.Max, 0)
+	case *ast.TypeAssertExpr:
+		return sub(&expr.X, token.HighestPrec)
+	case *ast.CallExpr:
+		if sub(&expr.Fun, token.HighestPrec) {
+			return true
+		}
+		for i := range expr.Args {
+			if sub(&expr.Args[i], 0) {
+				return true
+			}
+		}
+	case *ast.CompositeLit:
+		for i, elt := range expr.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if sub(&kv.Value, 0) {
+					return true
+				}
+				continue
+			}
+			if sub(&expr.Elts[i], 0) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exprPrec returns the precedence of the given Go expression, as an operand.
+func exprPrec(expr ast.Expr) int {
+	switch expr := expr.(type) {
+	case *ast.BinaryExpr:
+		return expr.Op.Precedence()
+	case *ast.UnaryExpr, *ast.StarExpr:
+		return token.UnaryPrec
+	}
+	return token.HighestPrec
+}
+
+// exprSlots returns the expressions evaluated once by the given statement,
+// excluding nested statements. The boolean return value indicates whether the
+// kind of statement is supported.
+func exprSlots(stmt ast.Stmt) ([]*ast.Expr, bool) {
+	var slots []*ast.Expr
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		for i := range stmt.Lhs {
+			slots = append(slots, &stmt.Lhs[i])
+		}
+		for i := range stmt.Rhs {
+			slots = append(slots, &stmt.Rhs[i])
+		}
+	case *ast.ExprStmt:
+		slots = append(slots, &stmt.X)
+	case *ast.ReturnStmt:
+		for i := range stmt.Results {
+			slots = append(slots, &stmt.Results[i])
+		}
+	case *ast.IfStmt:
+		if stmt.Init != nil {
+			return nil, false
+		}
+		slots = append(slots, &stmt.Cond)
+	case *ast.SwitchStmt:
+		if stmt.Init != nil || stmt.Tag == nil {
+			return nil, false
+		}
+		slots = append(slots, &stmt.Tag)
+	case *ast.IncDecStmt:
+		slots = append(slots, &stmt.X)
+	default:
+		return nil, false
+	}
+	return slots, true
+}
+
+// isConversion reports whether the given call expression is a conversion to a
+// predeclared or unsafe.Pointer type, or to a parenthesized type (e.g.
+// (*int32)(p)).
+func isConversion(call *ast.CallExpr) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		switch fun.Name {
+		case "bool", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "float32", "float64", "byte", "rune", "string":
+			return true
+		}
+	case *ast.SelectorExpr:
+		pkg, ok := fun.X.(*ast.Ident)
+		return ok && pkg.Name == "unsafe" && fun.Sel.Name == "Pointer"
+	case *ast.ParenExpr, *ast.ArrayType:
+		return true
+	}
+	return false
+}
+
+// refersTo reports whether the given node contains an identifier of the given
+// name.
+func refersTo(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// assigns reports whether the given statement may assign to any of the given
+// variables; i.e. directly, or through a pointer to the variable.
+func assigns(stmt ast.Stmt, vars map[string]bool) bool {
+	found := false
+	assigned := func(expr ast.Expr) {
+		if ident, ok := expr.(*ast.Ident); ok && vars[ident.Name] {
+			found = true
+		}
+	}
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				assigned(lhs)
+			}
+		case *ast.IncDecStmt:
+			assigned(n.X)
+		case *ast.RangeStmt:
+			assigned(n.Key)
+			assigned(n.Value)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				assigned(n.X)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// stmtLists invokes f for each statement list within the given function body,
+// replacing the statement list by the result of f.
+func stmtLists(body *ast.BlockStmt, f func(stmts []ast.Stmt) []ast.Stmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = f(n.List)
+		case *ast.CaseClause:
+			n.Body = f(n.Body)
+		}
+		return true
+	})
+}
@@ -0,0 +1,76 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestInlineTemps(t *testing.T) {
+	golden := []struct {
+		newFunc func(m *ir.Module) *ir.Func
+		want    string
+	}{
+		// Single-use temporaries.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				x := ir.NewParam("x", types.I32)
+				f := m.NewFunc("f", types.I32, x)
+				entry := f.NewBlock("entry")
+				sum := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+				prod := entry.NewMul(sum, constant.NewInt(types.I32, 2))
+				entry.NewRet(entry.NewSub(prod, x))
+				return f
+			},
+			want: "func f(x int32) int32 {\n\treturn (x+1)*2 - x\n}",
+		},
+		// Loads are not inlined across stores; temporaries used twice are
+		// declared in a var block.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				p := ir.NewParam("p", types.NewPointer(types.I32))
+				q := ir.NewParam("q", types.NewPointer(types.I32))
+				f := m.NewFunc("f", types.I32, p, q)
+				entry := f.NewBlock("entry")
+				x := entry.NewLoad(elemType(p), p)
+				entry.NewStore(constant.NewInt(types.I32, 1), q)
+				y := entry.NewLoad(elemType(q), q)
+				sq := entry.NewMul(y, y)
+				entry.NewRet(entry.NewAdd(x, sq))
+				return f
+			},
+			want: "func f(p *int32, q *int32) int32 {\n\tvar (\n\t\t_0\tint32\n\t\t_1\tint32\n\t)\n\t_0 = *p\n\t*q = 1\n\t_1 = *q\n\treturn _0 + _1*_1\n}",
+		},
+		// Loads are inlined into the directly following statement.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				p := ir.NewParam("p", types.NewPointer(types.I32))
+				f := m.NewFunc("f", types.I1, p)
+				entry := f.NewBlock("entry")
+				x := entry.NewLoad(elemType(p), p)
+				entry.NewRet(entry.NewICmp(enum.IPredSGT, x, constant.NewInt(types.I32, 0)))
+				return f
+			},
+			want: "func f(p *int32) bool {\n\treturn *p > 0\n}",
+		},
+	}
+	for _, g := range golden {
+		f := g.newFunc(ir.NewModule())
+		d := NewDecompiler()
+		d.InlineTemps = true
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Errorf("%q: unable to decompile function; %v", f.Name(), err)
+			continue
+		}
+		got := nodeString(t, fn)
+		if got != g.want {
+			t.Errorf("%q: function mismatch; expected %q, got %q", f.Name(), g.want, got)
+			continue
+		}
+		typeCheck(t, got)
+	}
+}
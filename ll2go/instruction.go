@@ -0,0 +1,1788 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math/big"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// insts converts the given LLVM IR instructions into a corresponding list of Go
+// statements.
+func (d *Decompiler) insts(insts []ir.Instruction) ([]ast.Stmt, error) {
+	var stmts []ast.Stmt
+	for _, inst := range insts {
+		instStmts, err := d.instStmts(inst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		stmts = append(stmts, instStmts...)
+	}
+	return stmts, nil
+}
+
+// instStmts converts the given LLVM IR instruction into a corresponding list of
+// Go statements, preceded by the comments of the instruction.
+func (d *Decompiler) instStmts(inst ir.Instruction) ([]ast.Stmt, error) {
+	// PHI instructions are handled by assigning the incoming values to the PHI
+	// variable in each predecessor basic block.
+	if _, ok := inst.(*ir.InstPhi); ok {
+		return nil, nil
+	}
+	stmts, err := d.instList(inst)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(d.instComments(inst), stmts...), nil
+}
+
+// instList converts the given LLVM IR instruction into a corresponding list of
+// Go statements.
+func (d *Decompiler) instList(inst ir.Instruction) ([]ast.Stmt, error) {
+	switch inst := inst.(type) {
+	// Select instructions are lowered into several Go statements.
+	case *ir.InstSelect:
+		return d.instSelect(inst)
+	// Variable argument instructions are lowered into several Go statements.
+	case *ir.InstVAArg:
+		return d.instVAArg(inst), nil
+	// Aggregate and vector insertions are lowered into a copy followed by an
+	// assignment.
+	case *ir.InstInsertValue:
+		return d.instInsertValue(inst)
+	case *ir.InstInsertElement:
+		return d.instInsertElement(inst)
+	// Getelementptr instructions with vector operands are lowered into a loop
+	// over the lanes of the vector.
+	case *ir.InstGetElementPtr:
+		if _, ok := inst.Type().(*types.VectorType); ok {
+			return d.instVectorGEP(inst)
+		}
+	// Fence and landingpad instructions are emitted as comments.
+	case *ir.InstFence:
+		return d.instFence(inst), nil
+	case *ir.InstLandingPad:
+		return d.instLandingPad(inst), nil
+	// Atomic instructions are lowered into sync/atomic calls.
+	case *ir.InstAtomicRMW:
+		return d.instAtomicRMW(inst)
+	case *ir.InstCmpXchg:
+		return d.instCmpXchg(inst)
+	// Calls to LLVM intrinsics are lowered into Go builtins and standard library
+	// functions, where supported; unless overridden by a registered lowering of
+	// the callee.
+	case *ir.InstCall:
+		stmt, ok, err := d.lowerCall(inst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ok {
+			return []ast.Stmt{stmt}, nil
+		}
+		stmts, ok, err := d.intrinsic(inst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ok {
+			return stmts, nil
+		}
+		if _, ok := multiResults(inst.Sig().RetType); ok {
+			call, err := d.call(inst.Callee, inst.Sig(), inst.Args)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return []ast.Stmt{d.varDecl(inst.Name(), d.GoType(inst.Sig().RetType)), d.assignResults(inst.Name(), inst.Sig().RetType, call)}, nil
+		}
+	}
+	stmt, err := d.inst(inst)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []ast.Stmt{stmt}, nil
+}
+
+// instInsertValue converts the given LLVM IR insertvalue instruction into a
+// corresponding list of Go statements. Go arrays and structs are values; thus
+// the aggregate is copied before the element is assigned, leaving the original
+// aggregate unmodified.
+//
+//    y := x
+//    y.Field1.Field0 = elem
+func (d *Decompiler) instInsertValue(inst *ir.InstInsertValue) ([]ast.Stmt, error) {
+	ops, err := d.values(inst.X, inst.Elem)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x, elem := ops[0], ops[1]
+	y := d.local(inst.Name())
+	lhs, err := d.aggregateElem(y, inst.X.Type(), inst.Indices)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	assign := &ast.AssignStmt{
+		Lhs: []ast.Expr{lhs},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{elem},
+	}
+	return []ast.Stmt{d.define(inst.Name(), x), assign}, nil
+}
+
+// instInsertElement converts the given LLVM IR insertelement instruction into a
+// corresponding list of Go statements. Vectors are mapped to Go arrays, which
+// are values; thus the vector is copied before the element is assigned.
+//
+//    y := x
+//    y[i] = elem
+func (d *Decompiler) instInsertElement(inst *ir.InstInsertElement) ([]ast.Stmt, error) {
+	ops, err := d.values(inst.X, inst.Elem, inst.Index)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x, elem, index := ops[0], ops[1], ops[2]
+	y := d.local(inst.Name())
+	assign := &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.IndexExpr{X: y, Index: index}},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{elem},
+	}
+	return []ast.Stmt{d.define(inst.Name(), x), assign}, nil
+}
+
+// shuffle returns the Go expression of the given LLVM IR shufflevector
+// instruction; i.e. a composite literal which gathers the elements of the two
+// input vectors, as selected by the shuffle mask; e.g.
+//
+//    [4]int32{x[3], x[2], y[1], 0}  // shufflevector <4 x i32> x, <4 x i32> y, <4 x i32> <i32 3, i32 2, i32 5, i32 undef>
+//
+// Undefined lanes of the shuffle mask produce zero-value elements.
+func (d *Decompiler) shuffle(inst *ir.InstShuffleVector) (ast.Expr, error) {
+	typ := inst.Type().(*types.VectorType)
+	n := int64(inst.X.Type().(*types.VectorType).Len)
+	ops, err := d.values(inst.X, inst.Y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x, y := ops[0], ops[1]
+	switch inst.Mask.(type) {
+	case *constant.Vector, *constant.ZeroInitializer, *constant.Undef, *constant.Poison:
+		// valid shuffle mask.
+	default:
+		if err := d.unsupported("shuffle mask", inst.Mask); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	zero, err := d.zeroValue(d.GoType(typ.ElemType), typ.ElemType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	lit := &ast.CompositeLit{
+		Type: d.GoType(typ),
+	}
+	for i := uint64(0); i < typ.Len; i++ {
+		var elem ast.Expr
+		switch index, ok := maskIndex(inst.Mask, i); {
+		case !ok:
+			elem = zero
+		case index < n:
+			elem = &ast.IndexExpr{X: x, Index: intLit(index)}
+		default:
+			elem = &ast.IndexExpr{X: y, Index: intLit(index - n)}
+		}
+		lit.Elts = append(lit.Elts, elem)
+	}
+	return lit, nil
+}
+
+// maskIndex returns the element index of the given lane of the shuffle mask,
+// which is a constant vector (see shuffle). The boolean return value indicates
+// whether the lane is defined.
+func maskIndex(mask value.Value, lane uint64) (int64, bool) {
+	switch mask := mask.(type) {
+	case *constant.Vector:
+		if c, ok := mask.Elems[lane].(*constant.Int); ok {
+			return c.X.Int64(), true
+		}
+		return 0, false
+	case *constant.ZeroInitializer:
+		return 0, true
+	default:
+		// undefined shuffle mask.
+		return 0, false
+	}
+}
+
+// aggregateElem returns the Go expression of the element at the given indices
+// of the given aggregate expression, of the given LLVM IR aggregate type; e.g.
+//
+//    x[2]             // extractvalue [4 x i32] x, 2
+//    x.Field1.Field0  // extractvalue {i32, {i8, i8}} x, 1, 0
+func (d *Decompiler) aggregateElem(x ast.Expr, t types.Type, indices []uint64) (ast.Expr, error) {
+	for _, index := range indices {
+		switch tt := t.(type) {
+		case *types.ArrayType:
+			x = &ast.IndexExpr{X: x, Index: intLit(int64(index))}
+			t = tt.ElemType
+		case *types.StructType:
+			x = &ast.SelectorExpr{X: x, Sel: fieldName(int(index))}
+			t = tt.Fields[index]
+		default:
+			if err := d.unsupported("aggregate index into type", t); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return unsupportedExpr(), nil
+		}
+	}
+	return x, nil
+}
+
+// vaArgs is the name of the variadic parameter of Go functions decompiled from
+// variadic LLVM IR functions.
+const vaArgs = "_va"
+
+// instVAArg converts the given LLVM IR va_arg instruction into a corresponding
+// list of Go statements, which read the next argument from the variadic
+// parameter of the function.
+//
+//    x := _va[0].(T)
+//    _va = _va[1:]
+//
+// The argument list operand of the va_arg instruction is ignored, as the
+// variadic arguments are always read from the variadic parameter.
+func (d *Decompiler) instVAArg(inst *ir.InstVAArg) []ast.Stmt {
+	va := ast.NewIdent(vaArgs)
+	arg := &ast.TypeAssertExpr{
+		X:    &ast.IndexExpr{X: va, Index: intLit(0)},
+		Type: d.GoType(inst.ArgType),
+	}
+	next := &ast.AssignStmt{
+		Lhs: []ast.Expr{va},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.SliceExpr{X: va, Low: intLit(1)}},
+	}
+	return []ast.Stmt{d.define(inst.Name(), arg), next}
+}
+
+// instComments returns the comments preceding the Go statements of the given
+// LLVM IR instruction or terminator; i.e. its source line comment and its LLVM
+// IR comment, if enabled, followed by the assembly of calls to inline assembly.
+func (d *Decompiler) instComments(inst ir.LLStringer) []ast.Stmt {
+	stmts := append(d.lineComment(inst), d.irComment(inst)...)
+	return append(stmts, d.asmComment(inst)...)
+}
+
+// irComment returns a comment with the LLVM IR assembly of the given LLVM IR
+// instruction or terminator; e.g.
+//
+//    // %3 = add i32 %1, %2
+//
+// A nil list of statements is returned if LLVM IR comments are disabled, or if
+// comments are not tracked.
+func (d *Decompiler) irComment(inst ir.LLStringer) []ast.Stmt {
+	if !d.IRComments {
+		return nil
+	}
+	return d.commentStmts(inst.LLString())
+}
+
+// instSelect converts the given LLVM IR select instruction into a
+// corresponding list of Go statements. As Go has no conditional operator, the
+// select instruction is lowered into an if-else statement which assigns the
+// selected value to the result variable.
+//
+//    var x T
+//    if cond {
+//        x = a
+//    } else {
+//        x = b
+//    }
+func (d *Decompiler) instSelect(inst *ir.InstSelect) ([]ast.Stmt, error) {
+	if _, ok := inst.Cond.Type().(*types.VectorType); ok {
+		return nil, errors.New("support for select instructions with vector conditions not yet implemented")
+	}
+	ops, err := d.values(inst.Cond, inst.ValueTrue, inst.ValueFalse)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.condAssign(inst.Name(), inst.Type(), ops[0], ops[1], ops[2]), nil
+}
+
+// inst converts the given LLVM IR instruction into a corresponding Go
+// statement.
+func (d *Decompiler) inst(inst ir.Instruction) (ast.Stmt, error) {
+	var (
+		expr ast.Expr
+		err  error
+	)
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		expr, err = d.unaryOp(token.SUB, inst.X)
+	// Binary instructions.
+	case *ir.InstAdd:
+		expr, err = d.binaryOp(inst.X, token.ADD, inst.Y)
+	case *ir.InstFAdd:
+		expr, err = d.binaryOp(inst.X, token.ADD, inst.Y)
+	case *ir.InstSub:
+		expr, err = d.binaryOp(inst.X, token.SUB, inst.Y)
+	case *ir.InstFSub:
+		expr, err = d.binaryOp(inst.X, token.SUB, inst.Y)
+	case *ir.InstMul:
+		expr, err = d.binaryOp(inst.X, token.MUL, inst.Y)
+	case *ir.InstFMul:
+		expr, err = d.binaryOp(inst.X, token.MUL, inst.Y)
+	case *ir.InstUDiv:
+		expr, err = d.unsignedOp(inst.X, token.QUO, inst.Y)
+	case *ir.InstSDiv:
+		expr, err = d.binaryOp(inst.X, token.QUO, inst.Y)
+	case *ir.InstFDiv:
+		expr, err = d.binaryOp(inst.X, token.QUO, inst.Y)
+	case *ir.InstURem:
+		expr, err = d.unsignedOp(inst.X, token.REM, inst.Y)
+	case *ir.InstSRem:
+		expr, err = d.binaryOp(inst.X, token.REM, inst.Y)
+	case *ir.InstFRem:
+		// Go has no remainder operator on floating-point values.
+		expr, err = d.frem(inst.X, inst.Y)
+	// Bitwise instructions.
+	case *ir.InstShl:
+		expr, err = d.binaryOp(inst.X, token.SHL, inst.Y)
+	case *ir.InstLShr:
+		// Go's >> operator is an arithmetic shift on signed integers and a
+		// logical shift on unsigned integers.
+		expr, err = d.unsignedOp(inst.X, token.SHR, inst.Y)
+	case *ir.InstAShr:
+		expr, err = d.binaryOp(inst.X, token.SHR, inst.Y)
+	case *ir.InstAnd:
+		if isBool(inst.X.Type()) {
+			expr, err = d.binaryOp(inst.X, token.LAND, inst.Y)
+			break
+		}
+		expr, err = d.binaryOp(inst.X, token.AND, inst.Y)
+	case *ir.InstOr:
+		if isBool(inst.X.Type()) {
+			expr, err = d.binaryOp(inst.X, token.LOR, inst.Y)
+			break
+		}
+		expr, err = d.binaryOp(inst.X, token.OR, inst.Y)
+	case *ir.InstXor:
+		// Go has no bitwise operators on booleans, and i1 operands are thus
+		// combined with logical operators; i.e. `xor i1 x, true` => `!x` and
+		// `xor i1 x, y` => `x != y`.
+		if isBool(inst.X.Type()) {
+			switch {
+			case isAllOnes(inst.Y):
+				expr, err = d.unaryOp(token.NOT, inst.X)
+			case isAllOnes(inst.X):
+				expr, err = d.unaryOp(token.NOT, inst.Y)
+			default:
+				expr, err = d.binaryOp(inst.X, token.NEQ, inst.Y)
+			}
+			break
+		}
+		// Recognize bitwise complement; i.e. `xor x, -1` => `^x`.
+		switch {
+		case isAllOnes(inst.Y):
+			expr, err = d.unaryOp(token.XOR, inst.X)
+		case isAllOnes(inst.X):
+			expr, err = d.unaryOp(token.XOR, inst.Y)
+		default:
+			expr, err = d.binaryOp(inst.X, token.XOR, inst.Y)
+		}
+	// Memory instructions.
+	case *ir.InstAlloca:
+		return d.instAlloca(inst)
+	case *ir.InstLoad:
+		expr, err = d.load(inst.Src)
+		if comment := memAccess(inst.Volatile, inst.Ordering); err == nil && len(comment) > 0 {
+			expr = d.commented(expr, comment)
+		}
+	case *ir.InstStore:
+		return d.instStore(inst)
+	case *ir.InstGetElementPtr:
+		expr, err = d.gep(inst.Src, inst.ElemType, inst.Indices)
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		expr, err = d.trunc(inst.From, inst.To)
+	case *ir.InstZExt:
+		expr, err = d.zext(inst.From, inst.To)
+	case *ir.InstSExt:
+		expr, err = d.sext(inst.From, inst.To)
+	case *ir.InstFPTrunc:
+		expr, err = d.convValue(inst.To, inst.From)
+	case *ir.InstFPExt:
+		expr, err = d.convValue(inst.To, inst.From)
+	case *ir.InstFPToUI:
+		expr, err = d.fpToInt(inst.From, inst.To, false)
+	case *ir.InstFPToSI:
+		expr, err = d.fpToInt(inst.From, inst.To, true)
+	case *ir.InstUIToFP:
+		if isBool(inst.From.Type()) {
+			expr, err = d.boolConv(inst.From, inst.To, false)
+		} else if expr, err = d.unsigned(inst.From); err == nil {
+			expr = d.conv(d.GoType(inst.To), expr)
+		}
+	case *ir.InstSIToFP:
+		if isBool(inst.From.Type()) {
+			expr, err = d.boolConv(inst.From, inst.To, true)
+		} else {
+			expr, err = d.convValue(inst.To, inst.From)
+		}
+	case *ir.InstPtrToInt:
+		expr, err = d.ptrToInt(inst.From, inst.To)
+	case *ir.InstIntToPtr:
+		expr, err = d.intToPtr(inst.From, inst.To)
+	case *ir.InstBitCast:
+		expr, err = d.bitCast(inst.From, inst.To)
+	// Vector instructions.
+	case *ir.InstExtractElement:
+		var ops []ast.Expr
+		if ops, err = d.values(inst.X, inst.Index); err == nil {
+			expr = &ast.IndexExpr{X: ops[0], Index: ops[1]}
+		}
+	case *ir.InstShuffleVector:
+		expr, err = d.shuffle(inst)
+	// Aggregate instructions.
+	case *ir.InstExtractValue:
+		if expr, err = d.Value(inst.X); err == nil {
+			expr, err = d.aggregateElem(expr, inst.X.Type(), inst.Indices)
+		}
+	// Other instructions.
+	case *ir.InstICmp:
+		expr, err = d.icmp(inst.Pred, inst.X, inst.Y)
+	case *ir.InstFCmp:
+		expr, err = d.fcmp(inst.Pred, inst.X, inst.Y)
+	case *ir.InstFreeze:
+		// Go has no poison or undefined values; thus freeze is lowered into the
+		// identity, which is noted by a comment.
+		if expr, err = d.Value(inst.X); err == nil {
+			expr = d.commented(expr, "freeze")
+		}
+	case *ir.InstCall:
+		// Tail calls are decompiled identically to regular calls.
+		call, err := d.call(inst.Callee, inst.Sig(), inst.Args)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if types.Equal(inst.Sig().RetType, types.Void) {
+			return &ast.ExprStmt{X: call}, nil
+		}
+		return d.define(inst.Name(), call), nil
+	default:
+		if err := d.unsupported("instruction", inst); err != nil {
+			return nil, err
+		}
+		return &ast.EmptyStmt{}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.define(inst.(value.Named).Name(), expr), nil
+}
+
+// instStore converts the given LLVM IR store instruction into a corresponding
+// Go assignment statement.
+//
+// i8 pointers represented as []byte are stored to through the first byte of
+// the slice; e.g. p[0] = uint8(x).
+func (d *Decompiler) instStore(inst *ir.InstStore) (ast.Stmt, error) {
+	p, err := d.ptrValue(inst.Dst)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var src, dst ast.Expr
+	if d.i8PtrType(p, inst.Dst.Type()) == "[]byte" {
+		dst = &ast.IndexExpr{X: p, Index: intLit(0)}
+		src, err = d.unsigned(inst.Src)
+	} else {
+		dst = d.derefExpr(p, inst.Dst.Type())
+		src, err = d.typedValue(inst.Src)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if comment := memAccess(inst.Volatile, inst.Ordering); len(comment) > 0 {
+		src = d.commented(src, comment)
+	}
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{dst},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{src},
+	}, nil
+}
+
+// instAlloca converts the given LLVM IR alloca instruction into a
+// corresponding Go local variable declaration; e.g.
+//
+//    var x int32       // alloca i32
+//    var x [10]int32   // alloca [10 x i32]
+//    x := make([]T, n) // alloca T, i32 n
+//
+// The address of the local variable is used in place of the alloca pointer;
+// i.e. &x for a single element and &x[0] for n elements. Loads and stores
+// through the alloca pointer are simplified accordingly by deref.
+func (d *Decompiler) instAlloca(inst *ir.InstAlloca) (ast.Stmt, error) {
+	if inst.NElems == nil || isOne(inst.NElems) {
+		return d.varDecl(inst.Name(), d.GoType(inst.ElemType)), nil
+	}
+	n, err := d.Value(inst.NElems)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	slice := &ast.ArrayType{Elt: d.GoType(inst.ElemType)}
+	expr := &ast.CallExpr{
+		Fun:  ast.NewIdent("make"),
+		Args: []ast.Expr{slice, n},
+	}
+	return d.define(inst.Name(), expr), nil
+}
+
+// alloca returns the address of the Go local variable of the given LLVM IR
+// alloca instruction.
+func (d *Decompiler) alloca(inst *ir.InstAlloca) ast.Expr {
+	x := ast.Expr(d.local(inst.Name()))
+	if inst.NElems != nil && !isOne(inst.NElems) {
+		x = &ast.IndexExpr{X: x, Index: &ast.BasicLit{Kind: token.INT, Value: "0"}}
+	}
+	return &ast.UnaryExpr{Op: token.AND, X: x}
+}
+
+// load returns the Go expression of the value loaded from the given pointer
+// (see deref). i8 pointers represented as []byte are loaded from the first byte
+// of the slice; e.g. int8(p[0]).
+func (d *Decompiler) load(ptr value.Value) (ast.Expr, error) {
+	x, err := d.ptrValue(ptr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if d.i8PtrType(x, ptr.Type()) == "[]byte" {
+		return d.conv(ast.NewIdent("int8"), &ast.IndexExpr{X: x, Index: intLit(0)}), nil
+	}
+	return d.derefExpr(x, ptr.Type()), nil
+}
+
+// i8PtrType returns the Go type of i8 pointers ("[]byte" or "unsafe.Pointer")
+// if the given Go expression of a pointer of the given LLVM IR type is
+// represented as such; or the empty string if the Go expression is a Go
+// pointer, as are i8 pointers by default and address expressions such as &x
+// (e.g. of i8 locals and globals).
+func (d *Decompiler) i8PtrType(x ast.Expr, t types.Type) string {
+	if !types.Equal(t, types.I8Ptr) {
+		return ""
+	}
+	if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		return ""
+	}
+	switch d.I8Ptr {
+	case "[]byte", "unsafe.Pointer":
+		return d.I8Ptr
+	}
+	return ""
+}
+
+// unsafePtr returns the Go expression of the given pointer of the given LLVM
+// IR pointer type, converted to unsafe.Pointer; e.g.
+//
+//    unsafe.Pointer(p)                    // *T
+//    unsafe.Pointer(unsafe.SliceData(p))  // []byte
+func (d *Decompiler) unsafePtr(x ast.Expr, t types.Type) ast.Expr {
+	if isNil(x) {
+		return d.conv(d.unsafeSel("Pointer"), x)
+	}
+	switch d.i8PtrType(x, t) {
+	case "[]byte":
+		return d.conv(d.unsafeSel("Pointer"), &ast.CallExpr{Fun: d.unsafeSel("SliceData"), Args: []ast.Expr{x}})
+	case "unsafe.Pointer":
+		return x
+	}
+	if isUnsafePointer(d.GoType(t)) {
+		return x
+	}
+	return d.conv(d.unsafeSel("Pointer"), x)
+}
+
+// isNil reports whether the given Go expression is nil.
+func isNil(x ast.Expr) bool {
+	ident, ok := x.(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+// deref returns the Go expression of the value pointed to by the given
+// pointer; i.e. `*x` in general, and `x` for the address expression `&x`. i8
+// pointers represented as []byte or unsafe.Pointer are dereferenced through
+// *int8; e.g. *(*int8)(p).
+func (d *Decompiler) deref(ptr value.Value) (ast.Expr, error) {
+	x, err := d.ptrValue(ptr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.derefExpr(x, ptr.Type()), nil
+}
+
+// ptrValue returns the Go expression of the given pointer to be dereferenced.
+//
+// Decayed pointers to the first element of i8 arrays are dereferenced as Go
+// pointers, regardless of the Go type of i8 pointers (see decayI8).
+func (d *Decompiler) ptrValue(ptr value.Value) (ast.Expr, error) {
+	if e, ok := ptr.(*constant.ExprGetElementPtr); ok {
+		var indices []value.Value
+		for _, index := range e.Indices {
+			indices = append(indices, index)
+		}
+		x, _, err := d.gepAddr(e.Src, e.ElemType, indices)
+		return x, err
+	}
+	return d.Value(ptr)
+}
+
+// derefExpr returns the Go expression of the value pointed to by the given Go
+// expression of a pointer of the given LLVM IR type (see deref).
+func (d *Decompiler) derefExpr(x ast.Expr, t types.Type) ast.Expr {
+	if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		return addr.X
+	}
+	if d.i8PtrType(x, t) != "" {
+		typ := &ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent("int8")}}
+		x = d.conv(typ, d.unsafePtr(x, t))
+	}
+	return &ast.StarExpr{X: x}
+}
+
+// operands returns the operands of the given LLVM IR instruction.
+//
+// Instructions not known to the decompiler have no operands; they are reported
+// as unsupported when translated to Go (see inst).
+func operands(inst ir.Instruction) []value.Value {
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		return []value.Value{inst.X}
+	// Binary instructions.
+	case *ir.InstAdd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFAdd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSub:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFSub:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstMul:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFMul:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstUDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstURem:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSRem:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFRem:
+		return []value.Value{inst.X, inst.Y}
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstLShr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstAShr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstAnd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstOr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstXor:
+		return []value.Value{inst.X, inst.Y}
+	// Vector instructions.
+	case *ir.InstExtractElement:
+		return []value.Value{inst.X, inst.Index}
+	case *ir.InstInsertElement:
+		return []value.Value{inst.X, inst.Elem, inst.Index}
+	case *ir.InstShuffleVector:
+		return []value.Value{inst.X, inst.Y, inst.Mask}
+	// Aggregate instructions.
+	case *ir.InstExtractValue:
+		return []value.Value{inst.X}
+	case *ir.InstInsertValue:
+		return []value.Value{inst.X, inst.Elem}
+	// Memory instructions.
+	case *ir.InstAlloca:
+		if inst.NElems == nil {
+			return nil
+		}
+		return []value.Value{inst.NElems}
+	case *ir.InstLoad:
+		return []value.Value{inst.Src}
+	case *ir.InstStore:
+		return []value.Value{inst.Src, inst.Dst}
+	case *ir.InstFence:
+		return nil
+	case *ir.InstCmpXchg:
+		return []value.Value{inst.Ptr, inst.Cmp, inst.New}
+	case *ir.InstAtomicRMW:
+		return []value.Value{inst.Dst, inst.X}
+	case *ir.InstGetElementPtr:
+		return append([]value.Value{inst.Src}, inst.Indices...)
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		return []value.Value{inst.From}
+	case *ir.InstZExt:
+		return []value.Value{inst.From}
+	case *ir.InstSExt:
+		return []value.Value{inst.From}
+	case *ir.InstFPTrunc:
+		return []value.Value{inst.From}
+	case *ir.InstFPExt:
+		return []value.Value{inst.From}
+	case *ir.InstFPToUI:
+		return []value.Value{inst.From}
+	case *ir.InstFPToSI:
+		return []value.Value{inst.From}
+	case *ir.InstUIToFP:
+		return []value.Value{inst.From}
+	case *ir.InstSIToFP:
+		return []value.Value{inst.From}
+	case *ir.InstPtrToInt:
+		return []value.Value{inst.From}
+	case *ir.InstIntToPtr:
+		return []value.Value{inst.From}
+	case *ir.InstBitCast:
+		return []value.Value{inst.From}
+	case *ir.InstAddrSpaceCast:
+		return []value.Value{inst.From}
+	// Other instructions.
+	case *ir.InstICmp:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFCmp:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstPhi:
+		var ops []value.Value
+		for _, inc := range inst.Incs {
+			ops = append(ops, inc.X)
+		}
+		return ops
+	case *ir.InstSelect:
+		return []value.Value{inst.Cond, inst.ValueTrue, inst.ValueFalse}
+	case *ir.InstFreeze:
+		return []value.Value{inst.X}
+	case *ir.InstCall:
+		return append([]value.Value{inst.Callee}, inst.Args...)
+	case *ir.InstVAArg:
+		return []value.Value{inst.ArgList}
+	case *ir.InstLandingPad:
+		return nil
+	case *ir.InstCatchPad:
+		return append([]value.Value{inst.CatchSwitch}, inst.Args...)
+	case *ir.InstCleanupPad:
+		return append([]value.Value{inst.ParentPad}, inst.Args...)
+	default:
+		return nil
+	}
+}
+
+// uses reports whether v is used by any of the given operands.
+func uses(ops []value.Value, v value.Value) bool {
+	for _, op := range ops {
+		if op == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ptrToInt returns the Go expression of the given pointer converted to the
+// given integer type. Pointer to integer conversions are unsafe.
+//
+//    int64(uintptr(unsafe.Pointer(p))) /* unsafe */
+func (d *Decompiler) ptrToInt(from value.Value, to types.Type) (ast.Expr, error) {
+	x, err := d.unsigned(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.commented(d.conv(d.GoType(to), x), "unsafe"), nil
+}
+
+// intToPtr returns the Go expression of the given integer converted to the
+// given pointer type. Integer to pointer conversions are unsafe.
+//
+//    (*int32)(unsafe.Pointer(uintptr(x))) /* unsafe */
+func (d *Decompiler) intToPtr(from value.Value, to types.Type) (ast.Expr, error) {
+	x, err := d.Value(from)
+	if c, ok := from.(*constant.Int); ok && c.X.Sign() < 0 {
+		x, err = d.unsigned(from)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addr := d.conv(ast.NewIdent("uintptr"), x)
+	return d.commented(d.ptrConv(d.conv(d.unsafeSel("Pointer"), addr), to), "unsafe"), nil
+}
+
+// trunc returns the Go expression of the given integer value truncated to the
+// given integer type; e.g.
+//
+//    int8(x)      // trunc i32 x to i8
+//    x&1 != 0     // trunc i32 x to i1
+func (d *Decompiler) trunc(from value.Value, to types.Type) (ast.Expr, error) {
+	x, err := d.Value(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Truncation to i1 keeps the least significant bit.
+	if isBool(to) {
+		mask := &ast.BinaryExpr{
+			X:  x,
+			Op: token.AND,
+			Y:  &ast.BasicLit{Kind: token.INT, Value: "1"},
+		}
+		return &ast.BinaryExpr{
+			X:  mask,
+			Op: token.NEQ,
+			Y:  &ast.BasicLit{Kind: token.INT, Value: "0"},
+		}, nil
+	}
+	return d.conv(d.GoType(to), x), nil
+}
+
+// zext returns the Go expression of the given integer value zero-extended to
+// the given integer type. The value is converted to the unsigned Go integer
+// type of the source size, as conversions between unsigned integer types are
+// zero-extending; e.g.
+//
+//    int64(uint32(x))   // zext i32 x to i64
+func (d *Decompiler) zext(from value.Value, to types.Type) (ast.Expr, error) {
+	if isBool(from.Type()) {
+		return d.boolConv(from, to, false)
+	}
+	x, err := d.unsigned(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Integer types of non-standard sizes are represented by larger Go integer
+	// types, and the unused bits are cleared.
+	if t, ok := from.Type().(*types.IntType); ok {
+		switch t.BitSize {
+		case 8, 16, 32, 64:
+		default:
+			if t.BitSize < 64 {
+				mask := new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize))
+				mask.Sub(mask, big.NewInt(1))
+				x = &ast.BinaryExpr{
+					X:  x,
+					Op: token.AND,
+					Y:  &ast.BasicLit{Kind: token.INT, Value: "0x" + mask.Text(16)},
+				}
+			}
+		}
+	}
+	return d.conv(d.GoType(to), x), nil
+}
+
+// fpToInt returns the Go expression of the given floating-point value
+// converted to the given integer type. Go conversions of floating-point values
+// to integers truncate toward zero, as do the fptoui and fptosi instructions.
+// Unsigned conversions go through the unsigned Go integer type of the same
+// size; e.g.
+//
+//    int32(uint32(f))   // fptoui double f to i32
+//    int32(f)           // fptosi double f to i32
+func (d *Decompiler) fpToInt(from value.Value, to types.Type, signed bool) (ast.Expr, error) {
+	x, err := d.Value(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The only non-poison values of i1 are 0 and 1 (or -1 if signed).
+	if isBool(to) {
+		return &ast.BinaryExpr{
+			X:  x,
+			Op: token.NEQ,
+			Y:  &ast.BasicLit{Kind: token.INT, Value: "0"},
+		}, nil
+	}
+	if signed {
+		return d.conv(d.GoType(to), x), nil
+	}
+	typ, err := d.unsignedType(to)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.conv(d.GoType(to), d.conv(typ, x)), nil
+}
+
+// bitCast returns the Go expression of the given value reinterpreted as the
+// given type; e.g.
+//
+//    (*T)(unsafe.Pointer(p))             // bitcast i32* p to T*
+//    math.Float32frombits(uint32(x))     // bitcast i32 x to float
+//    int32(math.Float32bits(f))          // bitcast float f to i32
+//    *(*T)(unsafe.Pointer(&x))           // bitcast <2 x i16> x to T
+//
+// Reinterpretations through unsafe.Pointer are marked with an "unsafe"
+// comment.
+func (d *Decompiler) bitCast(from value.Value, to types.Type) (ast.Expr, error) {
+	x, err := d.Value(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if types.Equal(from.Type(), to) {
+		return x, nil
+	}
+	_, fromPtr := from.Type().(*types.PointerType)
+	_, toPtr := to.(*types.PointerType)
+	if fromPtr && toPtr {
+		return d.commented(d.ptrConv(d.unsafePtr(x, from.Type()), to), "unsafe"), nil
+	}
+	fromInt, fromFloat := sizeOf(from.Type())
+	toInt, toFloat := sizeOf(to)
+	switch {
+	case fromInt == 32 && toFloat == 32, fromInt == 64 && toFloat == 64:
+		funcName := "Float32frombits"
+		if toFloat == 64 {
+			funcName = "Float64frombits"
+		}
+		bits, err := d.unsigned(from)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &ast.CallExpr{Fun: d.mathSel(funcName), Args: []ast.Expr{bits}}, nil
+	case fromFloat == 32 && toInt == 32:
+		return d.conv(d.GoType(to), &ast.CallExpr{Fun: d.mathSel("Float32bits"), Args: []ast.Expr{x}}), nil
+	case fromFloat == 64 && toInt == 64:
+		return d.conv(d.GoType(to), &ast.CallExpr{Fun: d.mathSel("Float64bits"), Args: []ast.Expr{x}}), nil
+	}
+	addr := d.conv(d.unsafeSel("Pointer"), &ast.UnaryExpr{Op: token.AND, X: x})
+	typ := &ast.ParenExpr{X: &ast.StarExpr{X: d.GoType(to)}}
+	return d.commented(&ast.StarExpr{X: d.conv(typ, addr)}, "unsafe"), nil
+}
+
+// sizeOf returns the size in bits of the given integer or floating-point type;
+// as either an integer size or a floating-point size, the other being zero.
+func sizeOf(t types.Type) (intSize, floatSize int) {
+	switch t := t.(type) {
+	case *types.IntType:
+		return int(t.BitSize), 0
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindFloat:
+			return 0, 32
+		case types.FloatKindDouble:
+			return 0, 64
+		}
+	}
+	return 0, 0
+}
+
+// ptrConv returns the conversion of the given unsafe.Pointer expression to the
+// Go type of the given pointer type.
+//
+// i8 pointers represented as []byte are converted by a helper function, as the
+// size of the memory pointed to is unknown; the slice thus extends to the
+// maximum length of 1<<30 bytes, and is nil for null pointers.
+func (d *Decompiler) ptrConv(p ast.Expr, to types.Type) ast.Expr {
+	typ := d.GoType(to)
+	if isUnsafePointer(typ) {
+		return p
+	}
+	if d.i8PtrType(p, to) == "[]byte" {
+		unsafePointer := nodeSrc(d.unsafeSel("Pointer"))
+		unsafeSlice := nodeSrc(d.unsafeSel("Slice"))
+		d.helper("unsafeBytes", fmt.Sprintf("func unsafeBytes(p %s) []byte {\nif p == nil {\nreturn nil\n}\nreturn %s((*byte)(p), 1<<30)\n}", unsafePointer, unsafeSlice))
+		return &ast.CallExpr{Fun: ast.NewIdent("unsafeBytes"), Args: []ast.Expr{p}}
+	}
+	return d.conv(&ast.ParenExpr{X: typ}, p)
+}
+
+// isUnsafePointer reports whether the given Go type is unsafe.Pointer.
+func isUnsafePointer(typ ast.Expr) bool {
+	sel, ok := typ.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == "unsafe" && sel.Sel.Name == "Pointer"
+}
+
+// sext returns the Go expression of the given integer value sign-extended to
+// the given integer type.
+func (d *Decompiler) sext(from value.Value, to types.Type) (ast.Expr, error) {
+	if isBool(from.Type()) {
+		return d.boolConv(from, to, true)
+	}
+	// Conversions between signed integer types are sign-extending.
+	return d.convValue(to, from)
+}
+
+// boolConv returns the Go expression of the given boolean value converted to
+// the given integer or floating-point type; i.e. 1 (or -1 if signed) if x is
+// true, and 0 otherwise. Go has no conversion from bool to numeric types, and
+// the conversion is thus performed by a helper function of the Go type, which
+// is declared in the Go source file of the module; e.g.
+//
+//    boolToInt32(b)     // zext i1 %b to i32
+//    -boolToInt32(b)    // sext i1 %b to i32
+func (d *Decompiler) boolConv(x value.Value, to types.Type, signed bool) (ast.Expr, error) {
+	cond, err := d.Value(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The helper function is named after the underlying Go type; i.e. without
+	// the comment noting the original size of the integer type (e.g. i24).
+	typ := nodeSrc(d.GoType(to))
+	if t, ok := to.(*types.IntType); ok {
+		typ = d.intIdent(t, "int").Name
+	}
+	name := "boolTo" + strings.ToUpper(typ[:1]) + typ[1:]
+	d.helper(name, fmt.Sprintf("func %s(x bool) %s {\nif x {\nreturn 1\n}\nreturn 0\n}", name, typ))
+	var expr ast.Expr = &ast.CallExpr{
+		Fun:  ast.NewIdent(name),
+		Args: []ast.Expr{cond},
+	}
+	if signed {
+		expr = &ast.UnaryExpr{Op: token.SUB, X: expr}
+	}
+	return expr, nil
+}
+
+// isBool reports whether the given type is the boolean type i1.
+func isBool(t types.Type) bool {
+	return types.Equal(t, types.I1)
+}
+
+// binaryOp returns the binary expression `x OP y`.
+func (d *Decompiler) binaryOp(x value.Value, op token.Token, y value.Value) (ast.Expr, error) {
+	ops, err := d.values(x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.BinaryExpr{
+		X:  ops[0],
+		Op: op,
+		Y:  ops[1],
+	}, nil
+}
+
+// frem returns the Go call expression math.Mod(x, y) of the given
+// floating-point operands, converted to and from float64 if needed; e.g.
+//
+//    math.Mod(x, y)                                 // frem double x, y
+//    float32(math.Mod(float64(x), float64(y)))      // frem float x, y
+func (d *Decompiler) frem(x, y value.Value) (ast.Expr, error) {
+	typ, ok := x.Type().(*types.FloatType)
+	if !ok {
+		if err := d.unsupported("floating-point remainder of type", x.Type()); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	var args []ast.Expr
+	for _, op := range []value.Value{x, y} {
+		arg, err := d.float64Arg(op)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, arg)
+	}
+	return d.mathCall(typ, "Mod", args...), nil
+}
+
+// unaryOp returns the unary expression `OP x`.
+func (d *Decompiler) unaryOp(op token.Token, x value.Value) (ast.Expr, error) {
+	expr, err := d.Value(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.UnaryExpr{Op: op, X: expr}, nil
+}
+
+// icmp returns the Go comparison expression of the given integer comparison
+// predicate and operands. The operands of unsigned comparisons are interpreted
+// as unsigned integers; e.g. uint32(x) < uint32(y).
+func (d *Decompiler) icmp(cond enum.IPred, x, y value.Value) (ast.Expr, error) {
+	switch cond {
+	case enum.IPredEQ:
+		return d.ptrCmp(x, token.EQL, y)
+	case enum.IPredNE:
+		return d.ptrCmp(x, token.NEQ, y)
+	case enum.IPredSGT:
+		return d.binaryOp(x, token.GTR, y)
+	case enum.IPredSGE:
+		return d.binaryOp(x, token.GEQ, y)
+	case enum.IPredSLT:
+		return d.binaryOp(x, token.LSS, y)
+	case enum.IPredSLE:
+		return d.binaryOp(x, token.LEQ, y)
+	}
+	ops := map[enum.IPred]token.Token{
+		enum.IPredUGT: token.GTR,
+		enum.IPredUGE: token.GEQ,
+		enum.IPredULT: token.LSS,
+		enum.IPredULE: token.LEQ,
+	}
+	op, ok := ops[cond]
+	if !ok {
+		return nil, errors.Errorf("support for integer comparison predicate %v not yet implemented", cond)
+	}
+	return d.unsignedCmp(x, op, y)
+}
+
+// ptrCmp returns the equality comparison expression `x OP y`. Slices are only
+// comparable to nil in Go, and i8 pointers represented as []byte are thus
+// compared as unsafe.Pointer values, unless compared to nil.
+func (d *Decompiler) ptrCmp(x value.Value, op token.Token, y value.Value) (ast.Expr, error) {
+	ops, err := d.values(x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !isNil(ops[0]) && !isNil(ops[1]) && (d.i8PtrType(ops[0], x.Type()) == "[]byte" || d.i8PtrType(ops[1], y.Type()) == "[]byte") {
+		ops[0], ops[1] = d.unsafePtr(ops[0], x.Type()), d.unsafePtr(ops[1], y.Type())
+	}
+	return &ast.BinaryExpr{
+		X:  ops[0],
+		Op: op,
+		Y:  ops[1],
+	}, nil
+}
+
+// unsignedCmp returns the comparison expression `x OP y`, where the operands
+// are interpreted as unsigned integers; e.g. uint32(x) < uint32(y).
+func (d *Decompiler) unsignedCmp(x value.Value, op token.Token, y value.Value) (ast.Expr, error) {
+	ux, err := d.unsigned(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	uy, err := d.unsigned(y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.BinaryExpr{
+		X:  ux,
+		Op: op,
+		Y:  uy,
+	}, nil
+}
+
+// fcmp returns the Go comparison expression of the given floating-point
+// comparison predicate and operands.
+//
+// The Go comparison operators ==, <, <=, > and >= yield false if either operand
+// is NaN, and thus correspond to the ordered predicates of LLVM IR, while the
+// Go != operator yields true if either operand is NaN and thus corresponds to
+// the unordered une predicate. The remaining unordered predicates are expressed
+// as the negation of the complementary ordered predicate (e.g. ult as
+// !(x >= y)), and NaN checks are made explicit for the ord and uno predicates
+// (e.g. ord as !math.IsNaN(x) && !math.IsNaN(y)).
+func (d *Decompiler) fcmp(cond enum.FPred, x, y value.Value) (ast.Expr, error) {
+	ops, err := d.values(x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// cmp: x OP y
+	cmp := func(op token.Token) ast.Expr {
+		return &ast.BinaryExpr{X: ops[0], Op: op, Y: ops[1]}
+	}
+	not := func(expr ast.Expr) ast.Expr {
+		return &ast.UnaryExpr{Op: token.NOT, X: &ast.ParenExpr{X: expr}}
+	}
+	// one: x < y || x > y
+	one := func() ast.Expr {
+		return &ast.BinaryExpr{
+			X:  cmp(token.LSS),
+			Op: token.LOR,
+			Y:  cmp(token.GTR),
+		}
+	}
+	switch cond {
+	case enum.FPredFalse:
+		return ast.NewIdent("false"), nil
+	case enum.FPredOEQ:
+		return cmp(token.EQL), nil
+	case enum.FPredOGT:
+		return cmp(token.GTR), nil
+	case enum.FPredOGE:
+		return cmp(token.GEQ), nil
+	case enum.FPredOLT:
+		return cmp(token.LSS), nil
+	case enum.FPredOLE:
+		return cmp(token.LEQ), nil
+	case enum.FPredONE:
+		return one(), nil
+	case enum.FPredORD:
+		nx, ny, err := d.isNaNs(ops, x.Type(), y.Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &ast.BinaryExpr{
+			X:  &ast.UnaryExpr{Op: token.NOT, X: nx},
+			Op: token.LAND,
+			Y:  &ast.UnaryExpr{Op: token.NOT, X: ny},
+		}, nil
+	case enum.FPredUEQ:
+		return not(one()), nil
+	case enum.FPredUGT:
+		return not(cmp(token.LEQ)), nil
+	case enum.FPredUGE:
+		return not(cmp(token.LSS)), nil
+	case enum.FPredULT:
+		return not(cmp(token.GEQ)), nil
+	case enum.FPredULE:
+		return not(cmp(token.GTR)), nil
+	case enum.FPredUNE:
+		return cmp(token.NEQ), nil
+	case enum.FPredUNO:
+		nx, ny, err := d.isNaNs(ops, x.Type(), y.Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &ast.BinaryExpr{
+			X:  nx,
+			Op: token.LOR,
+			Y:  ny,
+		}, nil
+	case enum.FPredTrue:
+		return ast.NewIdent("true"), nil
+	default:
+		return nil, errors.Errorf("support for floating-point comparison predicate %v not yet implemented", cond)
+	}
+}
+
+// isNaNs returns the Go call expressions math.IsNaN(x) and math.IsNaN(y) of the
+// given Go expressions of the operands x and y, of the given types (see isNaN).
+func (d *Decompiler) isNaNs(ops []ast.Expr, xt, yt types.Type) (ast.Expr, ast.Expr, error) {
+	x, err := d.isNaN(ops[0], xt)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	y, err := d.isNaN(ops[1], yt)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return x, y, nil
+}
+
+// isNaN returns the Go call expression math.IsNaN(x) of the given Go expression
+// of a floating-point value of the given type. Vectors of floating-point values
+// are not yet supported.
+func (d *Decompiler) isNaN(arg ast.Expr, t types.Type) (ast.Expr, error) {
+	goType, ok := d.GoType(t).(*ast.Ident)
+	if !ok {
+		if err := d.unsupported("NaN check of type", t); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	if goType.Name != "float64" {
+		arg = d.conv(ast.NewIdent("float64"), arg)
+	}
+	return &ast.CallExpr{
+		Fun:  d.mathSel("IsNaN"),
+		Args: []ast.Expr{arg},
+	}, nil
+}
+
+// call returns the Go call expression of the given callee, of the given
+// function signature, and arguments. The callee is either a function or a
+// function pointer, which are both represented by function values in Go.
+//
+// Variadic arguments are passed as regular arguments, of type interface{}; thus
+// integer and floating-point constants are converted to their Go types (e.g.
+// int32(5)) rather than passed as untyped constants.
+func (d *Decompiler) call(callee value.Value, sig *types.FuncType, args []value.Value) (ast.Expr, error) {
+	fn, err := d.Value(callee)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	call := &ast.CallExpr{
+		Fun: fn,
+	}
+	f, _ := callee.(*ir.Func)
+	for i, arg := range args {
+		// Arguments with parameter attributes (e.g. zeroext) are wrapped.
+		if a, ok := arg.(*ir.Arg); ok {
+			arg = a.Value
+		}
+		expr, err := d.typedValue(arg)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if sig.Variadic && i >= len(sig.Params) {
+			switch arg.(type) {
+			case *constant.Int, *constant.Float:
+				expr = d.conv(d.GoType(arg.Type()), expr)
+			}
+		}
+		// Arguments of pointer-sized zeroext parameters are passed as uintptr.
+		if f != nil && i < len(f.Params) && d.uintptrParam(f.Params[i]) {
+			expr = d.conv(d.paramType(f.Params[i]), expr)
+		}
+		call.Args = append(call.Args, expr)
+	}
+	return call, nil
+}
+
+// instVectorGEP converts the given LLVM IR getelementptr instruction with
+// vector operands, as used for the base addresses of gather and scatter
+// operations, into a corresponding list of Go statements; i.e. a loop computing
+// the address of each lane into an array of pointers. Scalar operands and splat
+// constants (e.g. struct indices, which are constant) are used by each lane.
+//
+//    // gather: getelementptr of 2 lanes
+//    var p [2]*int32 /* vector */
+//    for p_lane := range p {
+//       p[p_lane] = &a[idx[p_lane]]
+//    }
+func (d *Decompiler) instVectorGEP(inst *ir.InstGetElementPtr) ([]ast.Stmt, error) {
+	typ := inst.Type().(*types.VectorType)
+	lane := d.local(inst.Name() + ".lane")
+	laned := func(v value.Value) value.Value {
+		if _, ok := v.Type().(*types.VectorType); !ok {
+			return v
+		}
+		if c, ok := splat(v); ok {
+			return c
+		}
+		return &laneValue{x: v, lane: lane}
+	}
+	var indices []value.Value
+	for _, index := range inst.Indices {
+		indices = append(indices, laned(index))
+	}
+	addr, err := d.gep(laned(inst.Src), inst.ElemType, indices)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	loop := &ast.RangeStmt{
+		Key: lane,
+		Tok: token.DEFINE,
+		X:   d.local(inst.Name()),
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{&ast.IndexExpr{X: d.local(inst.Name()), Index: lane}},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{addr},
+				},
+			},
+		},
+	}
+	stmts := d.commentStmts(fmt.Sprintf("gather: getelementptr of %d lanes", typ.Len))
+	return append(stmts, d.varDecl(inst.Name(), d.GoType(typ)), loop), nil
+}
+
+// splat returns the element of the given constant vector, and a boolean
+// indicating whether all elements of the vector are equal.
+func splat(v value.Value) (constant.Constant, bool) {
+	switch v := v.(type) {
+	case *constant.Vector:
+		if len(v.Elems) == 0 {
+			return nil, false
+		}
+		for _, elem := range v.Elems[1:] {
+			if elem.Ident() != v.Elems[0].Ident() {
+				return nil, false
+			}
+		}
+		return v.Elems[0], true
+	case *constant.ZeroInitializer:
+		elem := v.Typ.(*types.VectorType).ElemType
+		if t, ok := elem.(*types.IntType); ok {
+			return constant.NewInt(t, 0), true
+		}
+		return constant.NewZeroInitializer(elem), true
+	default:
+		return nil, false
+	}
+}
+
+// laneValue is the element of a vector operand in a given lane, as used by the
+// loop over the lanes of an instruction with vector operands (see
+// instVectorGEP).
+type laneValue struct {
+	// Vector operand.
+	x value.Value
+	// Go identifier of the lane index.
+	lane *ast.Ident
+}
+
+// String returns the LLVM IR assembly of the lane value.
+func (v *laneValue) String() string {
+	return fmt.Sprintf("%v[%s]", v.x.Ident(), v.lane.Name)
+}
+
+// Type returns the element type of the vector operand.
+func (v *laneValue) Type() types.Type {
+	return v.x.Type().(*types.VectorType).ElemType
+}
+
+// Ident returns the identifier associated with the lane value.
+func (v *laneValue) Ident() string {
+	return v.String()
+}
+
+// gep returns the Go expression of the address computed by a getelementptr
+// instruction, which indexes into the source pointer of the given element type.
+//
+// The first index steps through the source pointer, and is lowered to pointer
+// arithmetic unless zero. Subsequent indices step into aggregates, and are
+// lowered left-to-right following the type at each index; to index expressions
+// for arrays and vectors (e.g. x[i]) and to selector expressions for structs
+// (e.g. x.Field1). The final expression is prefixed by the address-of operator;
+// e.g.
+//
+//    &matrix[i][j].Field1    // getelementptr [3 x [4 x %point]]* matrix, i64 0, i64 i, i64 j, i32 1
+//
+// Indices which are all zero denote the address of the first element of an
+// array, as by pointer decay in C (e.g. &a[0]); which is converted to the Go
+// type of i8 pointers for arrays of i8 (see decayI8).
+func (d *Decompiler) gep(src value.Value, elem types.Type, indices []value.Value) (ast.Expr, error) {
+	addr, arr, err := d.gepAddr(src, elem, indices)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if arr != nil {
+		return d.decayI8(addr, arr), nil
+	}
+	return addr, nil
+}
+
+// gepAddr returns the Go expression of the address computed by a getelementptr
+// instruction, as a Go pointer; and the i8 array of the address, if the address
+// is the decayed pointer to the first element of an i8 array, or nil otherwise.
+func (d *Decompiler) gepAddr(src value.Value, elem types.Type, indices []value.Value) (ast.Expr, ast.Expr, error) {
+	x, err := d.Value(src)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if len(indices) == 0 {
+		return x, nil, nil
+	}
+	if !isZero(indices[0]) {
+		if x, err = d.ptrAdd(x, elem, indices[0]); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+	}
+	if len(indices) == 1 {
+		return x, nil, nil
+	}
+	// Go implicitly dereferences pointers to arrays and structs in index and
+	// selector expressions.
+	if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		x = addr.X
+	}
+	decay := true
+	for _, index := range indices {
+		decay = decay && isZero(index)
+	}
+	t := elem
+	for _, index := range indices[1:] {
+		switch tt := t.(type) {
+		case *types.ArrayType:
+			i, err := d.Value(index)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			x = &ast.IndexExpr{X: x, Index: i}
+			t = tt.ElemType
+		case *types.VectorType:
+			i, err := d.Value(index)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			x = &ast.IndexExpr{X: x, Index: i}
+			t = tt.ElemType
+		case *types.StructType:
+			c, ok := index.(*constant.Int)
+			if !ok {
+				if err := d.unsupported("struct index", index); err != nil {
+					return nil, nil, errors.WithStack(err)
+				}
+				return unsupportedExpr(), nil, nil
+			}
+			i := int(c.X.Int64())
+			x = &ast.SelectorExpr{X: x, Sel: fieldName(i)}
+			t = tt.Fields[i]
+		default:
+			if err := d.unsupported("getelementptr index into type", t); err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			return unsupportedExpr(), nil, nil
+		}
+	}
+	addr := &ast.UnaryExpr{Op: token.AND, X: x}
+	if arr, ok := x.(*ast.IndexExpr); ok && decay && types.Equal(t, types.I8) {
+		return addr, arr.X, nil
+	}
+	return addr, nil, nil
+}
+
+// decayI8 returns the Go expression of the given address of the first element
+// of the given i8 array, converted to the Go type of i8 pointers; e.g.
+//
+//    &a[0]                                                 // *int8
+//    unsafe.Slice((*byte)(unsafe.Pointer(&a[0])), len(a))  // []byte
+//    unsafe.Pointer(&a[0])                                 // unsafe.Pointer
+//
+// The elements of i8 arrays are int8, and the []byte slice thus refers to the
+// array through an unsafe pointer conversion, rather than slicing the array.
+func (d *Decompiler) decayI8(addr, arr ast.Expr) ast.Expr {
+	switch d.I8Ptr {
+	case "[]byte":
+		p := d.conv(&ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent("byte")}}, d.conv(d.unsafeSel("Pointer"), addr))
+		n := &ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{arr}}
+		return &ast.CallExpr{
+			Fun:  d.unsafeSel("Slice"),
+			Args: []ast.Expr{p, n},
+		}
+	case "unsafe.Pointer":
+		return d.conv(d.unsafeSel("Pointer"), addr)
+	}
+	return addr
+}
+
+// ptrAdd returns the Go expression of the given pointer to the given element
+// type, offset by index elements; e.g.
+//
+//    (*T)(unsafe.Pointer(uintptr(unsafe.Pointer(x)) + uintptr(i)*unsafe.Sizeof(*x)))
+//
+// i8 pointers represented as []byte are offset by slicing, and those
+// represented as unsafe.Pointer by unsafe.Add; e.g.
+//
+//    p[i:]              // []byte
+//    unsafe.Add(p, i)   // unsafe.Pointer
+//
+// Negative constant offsets of []byte slices are computed by unsafe.Add.
+func (d *Decompiler) ptrAdd(x ast.Expr, elem types.Type, index value.Value) (ast.Expr, error) {
+	uintptr := ast.NewIdent("uintptr")
+	// Constant indices may be negative, which is not allowed in constant
+	// conversions to uintptr.
+	op := token.ADD
+	i, err := d.Value(index)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ptr := types.NewPointer(elem)
+	switch d.i8PtrType(x, ptr) {
+	case "[]byte":
+		if c, ok := index.(*constant.Int); !ok || c.X.Sign() >= 0 {
+			return &ast.SliceExpr{X: x, Low: i}, nil
+		}
+		add := &ast.CallExpr{Fun: d.unsafeSel("Add"), Args: []ast.Expr{d.unsafePtr(x, ptr), i}}
+		return d.ptrConv(add, ptr), nil
+	case "unsafe.Pointer":
+		return &ast.CallExpr{Fun: d.unsafeSel("Add"), Args: []ast.Expr{x, i}}, nil
+	}
+	if c, ok := index.(*constant.Int); ok && c.X.Sign() < 0 {
+		op = token.SUB
+		i = &ast.BasicLit{Kind: token.INT, Value: new(big.Int).Neg(c.X).String()}
+	}
+	size := &ast.CallExpr{
+		Fun:  d.unsafeSel("Sizeof"),
+		Args: []ast.Expr{&ast.StarExpr{X: x}},
+	}
+	addr := &ast.BinaryExpr{
+		X:  d.conv(uintptr, d.conv(d.unsafeSel("Pointer"), x)),
+		Op: op,
+		Y:  &ast.BinaryExpr{X: d.conv(uintptr, i), Op: token.MUL, Y: size},
+	}
+	return d.ptrConv(d.conv(d.unsafeSel("Pointer"), addr), ptr), nil
+}
+
+// unsafeSel returns the selector expression of the given identifier of the
+// unsafe package.
+func (d *Decompiler) unsafeSel(name string) ast.Expr {
+	return d.pkgSel("unsafe", name)
+}
+
+// isZero reports whether the given value is the integer constant zero.
+func isZero(v value.Value) bool {
+	c, ok := v.(*constant.Int)
+	return ok && c.X.Sign() == 0
+}
+
+// isOne reports whether the given value is the integer constant 1.
+func isOne(v value.Value) bool {
+	c, ok := v.(*constant.Int)
+	return ok && c.X.Cmp(big.NewInt(1)) == 0
+}
+
+// instFence converts the given LLVM IR fence instruction into a Go comment
+// documenting the memory barrier and its ordering, at the position of the
+// fence; e.g.
+//
+//    // memory barrier (fence seq_cst)
+//
+// Go has no explicit memory barriers, as the Go memory model only orders memory
+// accesses through synchronization primitives (e.g. sync/atomic operations on
+// the same variable); thus the barrier is not enforced.
+func (d *Decompiler) instFence(inst *ir.InstFence) []ast.Stmt {
+	return d.commentStmts(fmt.Sprintf("memory barrier (fence %v)", inst.Ordering))
+}
+
+// memAccess returns a description of the given volatile and atomic properties
+// of a memory access, or an empty string for regular memory accesses; e.g.
+// "volatile", "atomic seq_cst" or "volatile atomic acquire".
+func memAccess(volatile bool, ordering enum.AtomicOrdering) string {
+	var props []string
+	if volatile {
+		props = append(props, "volatile")
+	}
+	if ordering != enum.AtomicOrderingNone {
+		props = append(props, fmt.Sprintf("atomic %v", ordering))
+	}
+	return strings.Join(props, " ")
+}
+
+// isAllOnes reports whether the given value is an integer constant with all
+// bits set; i.e. -1, or true for the i1 type.
+func isAllOnes(v value.Value) bool {
+	c, ok := v.(*constant.Int)
+	if !ok {
+		return false
+	}
+	if c.Typ.BitSize == 1 {
+		return c.X.Sign() != 0
+	}
+	return c.X.Cmp(big.NewInt(-1)) == 0
+}
+
+// unsignedOp returns the binary expression `x OP y`, where the operands are
+// interpreted as unsigned integers.
+//
+// Integer types are recovered as signed Go integer types, and the semantics of
+// the Go division, remainder and shift operators depend on the signedness of
+// their operands. Thus, both operands are converted to the unsigned Go integer
+// type of the same size, and the result is converted back to the signed Go
+// integer type; e.g.
+//
+//    int32(uint32(x) / uint32(y))
+func (d *Decompiler) unsignedOp(x value.Value, op token.Token, y value.Value) (ast.Expr, error) {
+	expr, err := d.unsignedCmp(x, op, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.conv(d.GoType(x.Type()), expr), nil
+}
+
+// unsigned returns the Go expression of the given integer value, interpreted as
+// an unsigned integer; e.g. uint32(x).
+//
+// Negative integer constants are converted to their unsigned representation,
+// as constant conversions of negative values to unsigned integer types are
+// invalid in Go; e.g. the i32 constant -1 is converted to uint32(4294967295).
+// Pointers are converted to uintptr.
+func (d *Decompiler) unsigned(v value.Value) (ast.Expr, error) {
+	if c, ok := v.(*constant.Int); ok && c.X.Sign() < 0 {
+		x := new(big.Int).Lsh(big.NewInt(1), uint(c.Typ.BitSize))
+		x.Add(x, c.X)
+		return d.conv(d.intType(c.Typ, "uint"), &ast.BasicLit{Kind: token.INT, Value: x.String()}), nil
+	}
+	x, err := d.Value(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, ok := v.Type().(*types.PointerType); ok {
+		return d.conv(ast.NewIdent("uintptr"), d.unsafePtr(x, v.Type())), nil
+	}
+	typ, err := d.unsignedType(v.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.conv(typ, x), nil
+}
+
+// convValue returns the conversion of the given value to the Go type of the
+// given type.
+func (d *Decompiler) convValue(to types.Type, v value.Value) (ast.Expr, error) {
+	x, err := d.Value(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.conv(d.GoType(to), x), nil
+}
+
+// conv returns the conversion of the given expression to the given Go type.
+func (d *Decompiler) conv(typ, expr ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  typ,
+		Args: []ast.Expr{expr},
+	}
+}
+
+// define returns a short variable declaration, defining the local variable
+// with the given name as the given expression.
+func (d *Decompiler) define(name string, expr ast.Expr) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{d.local(name)},
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{expr},
+	}
+}
+
+// varDecl returns a variable declaration of the local variable with the given
+// name and Go type.
+func (d *Decompiler) varDecl(name string, typ ast.Expr) ast.Stmt {
+	return &ast.DeclStmt{
+		Decl: &ast.GenDecl{
+			Tok: token.VAR,
+			Specs: []ast.Spec{
+				&ast.ValueSpec{
+					Names: []*ast.Ident{d.local(name)},
+					Type:  typ,
+				},
+			},
+		},
+	}
+}
+
+// condAssign returns a variable declaration of the local variable with the
+// given name and type, followed by an if-else statement assigning x to the
+// variable if cond is true, and y otherwise.
+func (d *Decompiler) condAssign(name string, typ types.Type, cond, x, y ast.Expr) []ast.Stmt {
+	decl := d.varDecl(name, d.GoType(typ))
+	ifStmt := &ast.IfStmt{
+		Cond: cond,
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{d.assign(name, x)},
+		},
+		Else: &ast.BlockStmt{
+			List: []ast.Stmt{d.assign(name, y)},
+		},
+	}
+	return []ast.Stmt{decl, ifStmt}
+}
+
+// assign returns an assignment statement, assigning the given expression to the
+// local variable with the given name.
+func (d *Decompiler) assign(name string, expr ast.Expr) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{d.local(name)},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{expr},
+	}
+}
+
+// assignResults returns an assignment of the multiple return values of the
+// given call to the fields of the struct variable with the given name (see
+// multiResults); e.g.
+//
+//    _0.Field0, _0.Field1 = f()
+func (d *Decompiler) assignResults(name string, ret types.Type, call ast.Expr) ast.Stmt {
+	fields, _ := multiResults(ret)
+	assign := &ast.AssignStmt{
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{call},
+	}
+	for i := range fields {
+		assign.Lhs = append(assign.Lhs, &ast.SelectorExpr{X: d.local(name), Sel: fieldName(i)})
+	}
+	return assign
+}
@@ -0,0 +1,1309 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"go/printer"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestInstBinary(t *testing.T) {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	c := constant.NewInt(types.I32, 3)
+	golden := []struct {
+		newInst func(block *ir.Block, x, y value.Value) ir.Instruction
+		// Expected Go statement for the operands x and y, and for the operands x
+		// and 3, respectively.
+		want, wantConst string
+	}{
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewAdd(x, y) },
+			want:      "_0 := x + y",
+			wantConst: "_0 := x + 3",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewSub(x, y) },
+			want:      "_0 := x - y",
+			wantConst: "_0 := x - 3",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewMul(x, y) },
+			want:      "_0 := x * y",
+			wantConst: "_0 := x * 3",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewUDiv(x, y) },
+			want:      "_0 := int32(uint32(x) / uint32(y))",
+			wantConst: "_0 := int32(uint32(x) / uint32(3))",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewSDiv(x, y) },
+			want:      "_0 := x / y",
+			wantConst: "_0 := x / 3",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewURem(x, y) },
+			want:      "_0 := int32(uint32(x) % uint32(y))",
+			wantConst: "_0 := int32(uint32(x) % uint32(3))",
+		},
+		{
+			newInst:   func(block *ir.Block, x, y value.Value) ir.Instruction { return block.NewSRem(x, y) },
+			want:      "_0 := x % y",
+			wantConst: "_0 := x % 3",
+		},
+	}
+	for _, g := range golden {
+		for _, operand := range []struct {
+			y    value.Value
+			want string
+		}{{y: y, want: g.want}, {y: c, want: g.wantConst}} {
+			inst := newTestInst(x, y, func(block *ir.Block) ir.Instruction {
+				return g.newInst(block, x, operand.y)
+			})
+			d := NewDecompiler()
+			got := instString(t, d, inst)
+			if got != operand.want {
+				t.Errorf("statement mismatch; expected %q, got %q", operand.want, got)
+			}
+		}
+	}
+}
+
+func TestInstFloatBinary(t *testing.T) {
+	x := ir.NewParam("x", types.Float)
+	y := ir.NewParam("y", types.Float)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFNeg(x) },
+			want:    "_0 := -x",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFAdd(x, y) },
+			want:    "_0 := x + y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFSub(x, y) },
+			want:    "_0 := x - y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFMul(x, y) },
+			want:    "_0 := x * y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFDiv(x, constant.NewFloat(types.Float, 2)) },
+			want:    "_0 := x / 2.0",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFRem(x, y) },
+			want:    "_0 := float32(math.Mod(float64(x), float64(y)))",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, y, g.newInst)
+		d := NewDecompiler()
+		if got := instString(t, d, inst); got != g.want {
+			t.Errorf("%v: Go statement mismatch; expected %q, got %q", inst, g.want, got)
+		}
+	}
+}
+
+func TestInstBitwise(t *testing.T) {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	allOnes := constant.NewInt(types.I32, -1)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewShl(x, y) },
+			want:    "_0 := x << y",
+		},
+		// Logical shift right operates on unsigned integers.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewLShr(x, y) },
+			want:    "_0 := int32(uint32(x) >> uint32(y))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewLShr(x, constant.NewInt(types.I32, 31))
+			},
+			want: "_0 := int32(uint32(x) >> uint32(31))",
+		},
+		// Arithmetic shift right operates on signed integers.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewAShr(x, y) },
+			want:    "_0 := x >> y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewAnd(x, y) },
+			want:    "_0 := x & y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewOr(x, y) },
+			want:    "_0 := x | y",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(x, y) },
+			want:    "_0 := x ^ y",
+		},
+		// Bitwise complement.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(x, allOnes) },
+			want:    "_0 := ^x",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(allOnes, y) },
+			want:    "_0 := ^y",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, y, g.newInst)
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("statement mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
+func TestInstBitwiseBool(t *testing.T) {
+	// Bitwise instructions on i1 operands map to logical operators.
+	a := ir.NewParam("a", types.I1)
+	b := ir.NewParam("b", types.I1)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewAnd(a, b) },
+			want:    "_0 := a && b",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewOr(a, b) },
+			want:    "_0 := a || b",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(a, b) },
+			want:    "_0 := a != b",
+		},
+		// Logical negation.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(a, constant.True) },
+			want:    "_0 := !a",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewXor(constant.True, b) },
+			want:    "_0 := !b",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewAnd(a, constant.False) },
+			want:    "_0 := a && false",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(a, b, g.newInst)
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("statement mismatch; expected %q, got %q", g.want, got)
+		}
+		typeCheck(t, "func f(a, b bool) bool {\n"+got+"\nreturn _0\n}")
+	}
+}
+
+func TestInstMemory(t *testing.T) {
+	elem := types.NewArray(4, types.I32)
+	p := ir.NewParam("p", types.NewPointer(elem))
+	q := ir.NewParam("q", types.NewPointer(types.I32))
+	zero := constant.NewInt(types.I32, 0)
+	one := constant.NewInt(types.I32, 1)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		// Load through GEP result.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				elem := block.NewGetElementPtr(elemType(p), p, zero, one)
+				return block.NewLoad(elemType(elem), elem)
+			},
+			want: "_1 := *_0",
+		},
+		// Store constant.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewStore(constant.NewInt(types.I32, 42), q)
+			},
+			want: "*q = 42",
+		},
+		// Volatile and atomic memory accesses.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				inst := block.NewLoad(elemType(q), q)
+				inst.Volatile = true
+				return inst
+			},
+			want: "_0 := *q /* volatile */",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				inst := block.NewStore(constant.NewInt(types.I32, 42), q)
+				inst.Volatile = true
+				inst.Ordering = enum.AtomicOrderingSequentiallyConsistent
+				return inst
+			},
+			want: "*q = 42 /* volatile atomic seq_cst */",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(p, q, g.newInst)
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("statement mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
+func TestInstGetElementPtr(t *testing.T) {
+	st := types.NewStruct(types.I32, types.NewArray(8, types.I8))
+	p := ir.NewParam("p", types.NewPointer(st))
+	q := ir.NewParam("q", types.NewPointer(types.NewArray(3, types.NewArray(4, types.I32))))
+	point := types.NewStruct(types.I32, types.I32)
+	point.SetName("struct.point")
+	matrix := ir.NewParam("matrix", types.NewPointer(types.NewArray(3, types.NewArray(4, point))))
+	rows := ir.NewParam("rows", types.NewPointer(types.NewArray(4, point)))
+	i := ir.NewParam("i", types.I64)
+	j := ir.NewParam("j", types.I64)
+	i64 := func(x int64) value.Value {
+		return constant.NewInt(types.I64, x)
+	}
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		// Struct field access.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(p), p, i64(0), constant.NewInt(types.I32, 1))
+			},
+			want: "_0 := &p.Field1",
+		},
+		// Array within struct.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(p), p, i64(0), constant.NewInt(types.I32, 1), i64(5))
+			},
+			want: "_0 := &p.Field1[5]",
+		},
+		// Two-dimensional array access.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(q), q, i64(0), i64(2), i64(3))
+			},
+			want: "_0 := &q[2][3]",
+		},
+		// Pointer decay of two-dimensional array.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(q), q, i64(0), i64(0), i64(0))
+			},
+			want: "_0 := &q[0][0]",
+		},
+		// Zero first index only.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(p), p, i64(0))
+			},
+			want: "_0 := p",
+		},
+		// Non-zero first index.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(p), p, i64(2))
+			},
+			want: "_0 := (*struct {\n\tField0\tint32\n\tField1\t[8]int8\n})(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + uintptr(2)*unsafe.Sizeof(*p)))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(q), q, i64(-1), i64(2), i64(3))
+			},
+			want: "_0 := &(*[3][4]int32)(unsafe.Pointer(uintptr(unsafe.Pointer(q)) - uintptr(1)*unsafe.Sizeof(*q)))[2][3]",
+		},
+		// Struct field of two-dimensional array element (matrix[i][j].Field1).
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(matrix), matrix, i64(0), i, j, constant.NewInt(types.I32, 1))
+			},
+			want: "_0 := &matrix[i][j].Field1",
+		},
+		// Pointer, array and struct indices (rows[i][j].Field0).
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewGetElementPtr(elemType(rows), rows, i, j, constant.NewInt(types.I32, 0))
+			},
+			want: "_0 := &(*[4]point)(unsafe.Pointer(uintptr(unsafe.Pointer(rows)) + uintptr(i)*unsafe.Sizeof(*rows)))[j].Field0",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(p, q, g.newInst)
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("statement mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
+func TestGEPDecay(t *testing.T) {
+	// @buf = global [8 x i8] zeroinitializer
+	//
+	// define i8 @f() {
+	//    %a = alloca [4 x i8]
+	//    %1 = getelementptr inbounds [4 x i8], [4 x i8]* %a, i32 0, i32 0
+	//    %2 = call i32 @puts(i8* %1)
+	//    %3 = load i8, i8* getelementptr ([8 x i8], [8 x i8]* @buf, i64 0, i64 0)
+	//    ret i8 %3
+	// }
+	m := ir.NewModule()
+	buf := m.NewGlobalDef("buf", constant.NewZeroInitializer(types.NewArray(8, types.I8)))
+	puts := m.NewFunc("puts", types.I32, ir.NewParam("s", types.NewPointer(types.I8)))
+	f := m.NewFunc("f", types.I8)
+	entry := f.NewBlock("entry")
+	a := entry.NewAlloca(types.NewArray(4, types.I8))
+	a.SetName("a")
+	entry.NewCall(puts, entry.NewGetElementPtr(elemType(a), a, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)))
+	entry.NewRet(entry.NewLoad(elemType(constant.NewGetElementPtr(elemType(buf), buf, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, 0))), constant.NewGetElementPtr(elemType(buf), buf, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, 0))))
+
+	golden := []struct {
+		i8Ptr string
+		want  string
+	}{
+		{
+			i8Ptr: "*int8",
+			want:  "func f() int8 {\n\tvar a [4]int8\n\t_0 := &a[0]\n\t_1 := puts(_0)\n\t_2 := buf[0]\n\treturn _2\n}",
+		},
+		{
+			i8Ptr: "[]byte",
+			want:  "func f() int8 {\n\tvar a [4]int8\n\t_0 := unsafe.Slice((*byte)(unsafe.Pointer(&a[0])), len(a))\n\t_1 := puts(_0)\n\t_2 := buf[0]\n\treturn _2\n}",
+		},
+		{
+			i8Ptr: "unsafe.Pointer",
+			want:  "func f() int8 {\n\tvar a [4]int8\n\t_0 := unsafe.Pointer(&a[0])\n\t_1 := puts(_0)\n\t_2 := buf[0]\n\treturn _2\n}",
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.I8Ptr = g.i8Ptr
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Errorf("%s: unable to decompile function; %v", g.i8Ptr, err)
+			continue
+		}
+		if got := nodeString(t, fn); got != g.want {
+			t.Errorf("%s: function mismatch; expected %q, got %q", g.i8Ptr, g.want, got)
+		}
+	}
+}
+
+func TestI8PtrMemory(t *testing.T) {
+	// define i1 @f(i8* %p, i8* %q, i64 %i) {
+	//    %x = load i8, i8* %p
+	//    store i8 -1, i8* %p
+	//    %r = getelementptr i8, i8* %p, i64 %i
+	//    store i8 %x, i8* %r
+	//    %s = getelementptr i8, i8* %p, i64 -1
+	//    store i8 %x, i8* %s
+	//    %t = bitcast i8* %p to i32*
+	//    %u = bitcast i32* %t to i8*
+	//    store i8 %x, i8* %u
+	//    %eq = icmp eq i8* %p, %q
+	//    %null = icmp eq i8* %p, null
+	//    %c = and i1 %eq, %null
+	//    ret i1 %c
+	// }
+	m := ir.NewModule()
+	p := ir.NewParam("p", types.I8Ptr)
+	q := ir.NewParam("q", types.I8Ptr)
+	i := ir.NewParam("i", types.I64)
+	f := m.NewFunc("f", types.I1, p, q, i)
+	entry := f.NewBlock("entry")
+	x := entry.NewLoad(types.I8, p)
+	x.SetName("x")
+	entry.NewStore(constant.NewInt(types.I8, -1), p)
+	r := entry.NewGetElementPtr(types.I8, p, i)
+	r.SetName("r")
+	entry.NewStore(x, r)
+	s := entry.NewGetElementPtr(types.I8, p, constant.NewInt(types.I64, -1))
+	s.SetName("s")
+	entry.NewStore(x, s)
+	tt := entry.NewBitCast(p, types.NewPointer(types.I32))
+	tt.SetName("t")
+	u := entry.NewBitCast(tt, types.I8Ptr)
+	u.SetName("u")
+	entry.NewStore(x, u)
+	eq := entry.NewICmp(enum.IPredEQ, p, q)
+	eq.SetName("eq")
+	null := entry.NewICmp(enum.IPredEQ, p, constant.NewNull(types.I8Ptr))
+	null.SetName("null")
+	c := entry.NewAnd(eq, null)
+	c.SetName("c")
+	entry.NewRet(c)
+
+	golden := []struct {
+		i8Ptr string
+		want  string
+	}{
+		{
+			i8Ptr: "*int8",
+			want:  "package main\n\nimport \"unsafe\"\n\nfunc f(p *int8, q *int8, i int64) bool {\n\tx := *p\n\t*p = -1\n\tr := (*int8)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + uintptr(i)*unsafe.Sizeof(*p)))\n\t*r = x\n\ts := (*int8)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) - uintptr(1)*unsafe.Sizeof(*p)))\n\t*s = x\n\tt := (*int32)(unsafe.Pointer(p)) /* unsafe */\n\tu := (*int8)(unsafe.Pointer(t))  /* unsafe */\n\t*u = x\n\teq := p == q\n\tnull := p == nil\n\tc := eq && null\n\treturn c\n}\n",
+		},
+		{
+			i8Ptr: "[]byte",
+			want:  "package main\n\nimport \"unsafe\"\n\nfunc f(p []byte, q []byte, i int64) bool {\n\tx := int8(p[0])\n\tp[0] = uint8(255)\n\tr := p[i:]\n\tr[0] = uint8(x)\n\ts := unsafeBytes(unsafe.Add(unsafe.Pointer(unsafe.SliceData(p)), -1))\n\ts[0] = uint8(x)\n\tt := (*int32)(unsafe.Pointer(unsafe.SliceData(p))) /* unsafe */\n\tu := unsafeBytes(unsafe.Pointer(t))                /* unsafe */\n\tu[0] = uint8(x)\n\teq := unsafe.Pointer(unsafe.SliceData(p)) == unsafe.Pointer(unsafe.SliceData(q))\n\tnull := p == nil\n\tc := eq && null\n\treturn c\n}\nfunc unsafeBytes(p unsafe.Pointer) []byte {\n\tif p == nil {\n\t\treturn nil\n\t}\n\treturn unsafe.Slice((*byte)(p), 1<<30)\n}\n",
+		},
+		{
+			i8Ptr: "unsafe.Pointer",
+			want:  "package main\n\nimport \"unsafe\"\n\nfunc f(p unsafe.Pointer, q unsafe.Pointer, i int64) bool {\n\tx := *(*int8)(p)\n\t*(*int8)(p) = -1\n\tr := unsafe.Add(p, i)\n\t*(*int8)(r) = x\n\ts := unsafe.Add(p, -1)\n\t*(*int8)(s) = x\n\tt := (*int32)(p)       /* unsafe */\n\tu := unsafe.Pointer(t) /* unsafe */\n\t*(*int8)(u) = x\n\teq := p == q\n\tnull := p == nil\n\tc := eq && null\n\treturn c\n}\n",
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.I8Ptr = g.i8Ptr
+		file, err := d.Decompile(m, nil)
+		if err != nil {
+			t.Errorf("%s: unable to decompile module; %v", g.i8Ptr, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, FileSet(), file); err != nil {
+			t.Errorf("%s: unable to format Go source file; %v", g.i8Ptr, err)
+			continue
+		}
+		if got := buf.String(); got != g.want {
+			t.Errorf("%s: Go source mismatch; expected %q, got %q", g.i8Ptr, g.want, got)
+		}
+		// The decompiled Go source file type-checks with each Go type of i8
+		// pointers.
+		if err := Verify("f.go", file); err != nil {
+			t.Errorf("%s: unable to verify Go source file; %v", g.i8Ptr, err)
+		}
+	}
+}
+
+func TestInstVectorGEP(t *testing.T) {
+	golden := []struct {
+		newFunc func(m *ir.Module) *ir.Func
+		want    string
+	}{
+		// Vector index.
+		//
+		//    %p = getelementptr [4 x i32], [4 x i32]* %a, i64 0, <2 x i64> %idx
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				a := ir.NewParam("a", types.NewPointer(types.NewArray(4, types.I32)))
+				idx := ir.NewParam("idx", types.NewVector(2, types.I64))
+				f := m.NewFunc("f", types.NewVector(2, types.NewPointer(types.I32)), a, idx)
+				entry := f.NewBlock("entry")
+				p := entry.NewGetElementPtr(elemType(a), a, constant.NewInt(types.I64, 0), idx)
+				p.SetName("p")
+				entry.NewRet(p)
+				return f
+			},
+			want: "func f(a *[4]int32, idx [2]int64 /* vector */) [2]*int32 /* vector */ {\n\t// gather: getelementptr of 2 lanes\n\tvar p [2]*int32\t/* vector */\n\tfor p_lane := range p {\n\t\tp[p_lane] = &a[idx[p_lane]]\n\t}\n\treturn p\n}",
+		},
+		// Vector of base pointers.
+		//
+		//    %p = getelementptr {i32, i32}, <2 x {i32, i32}*> %ps, i64 0, i32 1
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				pair := types.NewStruct(types.I32, types.I32)
+				ps := ir.NewParam("ps", types.NewVector(2, types.NewPointer(pair)))
+				f := m.NewFunc("f", types.NewVector(2, types.NewPointer(types.I32)), ps)
+				entry := f.NewBlock("entry")
+				p := entry.NewGetElementPtr(pair, ps, constant.NewInt(types.I64, 0), constant.NewInt(types.I32, 1))
+				p.SetName("p")
+				entry.NewRet(p)
+				return f
+			},
+			want: "func f(ps [2]*struct {\n\tField0\tint32\n\tField1\tint32\n} /* vector */) [2]*int32 /* vector */ {\n\t// gather: getelementptr of 2 lanes\n\tvar p [2]*int32\t/* vector */\n\tfor p_lane := range p {\n\t\tp[p_lane] = &ps[p_lane].Field1\n\t}\n\treturn p\n}",
+		},
+		// Splat vector indices, including the struct index.
+		//
+		//    %p = getelementptr {i32, i32}, <2 x {i32, i32}*> %ps, <2 x i64> zeroinitializer, <2 x i32> <i32 1, i32 1>
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				pair := types.NewStruct(types.I32, types.I32)
+				ps := ir.NewParam("ps", types.NewVector(2, types.NewPointer(pair)))
+				f := m.NewFunc("f", types.NewVector(2, types.NewPointer(types.I32)), ps)
+				entry := f.NewBlock("entry")
+				zero := constant.NewZeroInitializer(types.NewVector(2, types.I64))
+				one := constant.NewInt(types.I32, 1)
+				p := entry.NewGetElementPtr(pair, ps, zero, constant.NewVector(types.NewVector(2, types.I32), one, one))
+				p.SetName("p")
+				entry.NewRet(p)
+				return f
+			},
+			want: "func f(ps [2]*struct {\n\tField0\tint32\n\tField1\tint32\n} /* vector */) [2]*int32 /* vector */ {\n\t// gather: getelementptr of 2 lanes\n\tvar p [2]*int32\t/* vector */\n\tfor p_lane := range p {\n\t\tp[p_lane] = &ps[p_lane].Field1\n\t}\n\treturn p\n}",
+		},
+	}
+	for _, g := range golden {
+		f := g.newFunc(ir.NewModule())
+		d := withComments(NewDecompiler())
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Errorf("%q: unable to decompile function; %v", f.Name(), err)
+			continue
+		}
+		got := commentString(t, d, fn)
+		if got != g.want {
+			t.Errorf("%q: function mismatch; expected %q, got %q", f.Name(), g.want, got)
+			continue
+		}
+		typeCheck(t, got)
+	}
+}
+
+func TestInstCall(t *testing.T) {
+	m := ir.NewModule()
+	foo := m.NewFunc("foo", types.Void)
+	bar := m.NewFunc("bar", types.I32, ir.NewParam("a", types.I32), ir.NewParam("b", types.I32))
+	printf := m.NewFunc("printf", types.I32, ir.NewParam("format", types.NewPointer(types.I8)))
+	printf.Sig.Variadic = true
+	x := ir.NewParam("x", types.I32)
+	fp := ir.NewParam("fp", types.NewPointer(types.NewPointer(types.NewFunc(types.I32, types.I32))))
+	five := constant.NewInt(types.I32, 5)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		// Void call.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewCall(foo) },
+			want:    "foo()",
+		},
+		// Value-returning call.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewCall(bar, x, five) },
+			want:    "_0 := bar(x, 5)",
+		},
+		// Tail call.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				inst := block.NewCall(bar, five, x)
+				inst.Tail = enum.TailTail
+				return inst
+			},
+			want: "_0 := bar(5, x)",
+		},
+		// Variadic call.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewCall(printf, constant.NewNull(types.NewPointer(types.I8)), x, five)
+			},
+			want: "_0 := printf((*int8)(nil), x, int32(5))",
+		},
+		// Call through loaded function pointer.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				f := block.NewLoad(elemType(fp), fp)
+				return block.NewCall(f, x)
+			},
+			want: "_1 := _0(x)",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, fp, g.newInst)
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("statement mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
+func TestInstICmp(t *testing.T) {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	golden := []struct {
+		cond enum.IPred
+		x, y value.Value
+		want string
+	}{
+		{cond: enum.IPredEQ, x: x, y: y, want: "_0 := x == y"},
+		{cond: enum.IPredNE, x: x, y: y, want: "_0 := x != y"},
+		{cond: enum.IPredSGT, x: x, y: y, want: "_0 := x > y"},
+		{cond: enum.IPredSGE, x: x, y: y, want: "_0 := x >= y"},
+		{cond: enum.IPredSLT, x: x, y: y, want: "_0 := x < y"},
+		{cond: enum.IPredSLE, x: x, y: y, want: "_0 := x <= y"},
+		{cond: enum.IPredUGT, x: x, y: y, want: "_0 := uint32(x) > uint32(y)"},
+		{cond: enum.IPredUGE, x: x, y: y, want: "_0 := uint32(x) >= uint32(y)"},
+		{cond: enum.IPredULT, x: x, y: y, want: "_0 := uint32(x) < uint32(y)"},
+		{cond: enum.IPredULE, x: x, y: y, want: "_0 := uint32(x) <= uint32(y)"},
+		// -1 <u 1 is false, as the unsigned representation of -1 is 0xFFFFFFFF.
+		{
+			cond: enum.IPredULT,
+			x:    constant.NewInt(types.I32, -1),
+			y:    constant.NewInt(types.I32, 1),
+			want: "_0 := uint32(4294967295) < uint32(1)",
+		},
+		{
+			cond: enum.IPredSLT,
+			x:    constant.NewInt(types.I32, -1),
+			y:    constant.NewInt(types.I32, 1),
+			want: "_0 := -1 < 1",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, y, func(block *ir.Block) ir.Instruction {
+			return block.NewICmp(g.cond, g.x, g.y)
+		})
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("%v: statement mismatch; expected %q, got %q", g.cond, g.want, got)
+		}
+	}
+}
+
+func TestInstFCmp(t *testing.T) {
+	x := ir.NewParam("x", types.Double)
+	y := ir.NewParam("y", types.Double)
+	golden := []struct {
+		cond enum.FPred
+		want string
+	}{
+		{cond: enum.FPredFalse, want: "_0 := false"},
+		{cond: enum.FPredOEQ, want: "_0 := x == y"},
+		{cond: enum.FPredOGT, want: "_0 := x > y"},
+		{cond: enum.FPredOGE, want: "_0 := x >= y"},
+		{cond: enum.FPredOLT, want: "_0 := x < y"},
+		{cond: enum.FPredOLE, want: "_0 := x <= y"},
+		{cond: enum.FPredONE, want: "_0 := x < y || x > y"},
+		{cond: enum.FPredORD, want: "_0 := !math.IsNaN(x) && !math.IsNaN(y)"},
+		{cond: enum.FPredUEQ, want: "_0 := !(x < y || x > y)"},
+		{cond: enum.FPredUGT, want: "_0 := !(x <= y)"},
+		{cond: enum.FPredUGE, want: "_0 := !(x < y)"},
+		{cond: enum.FPredULT, want: "_0 := !(x >= y)"},
+		{cond: enum.FPredULE, want: "_0 := !(x > y)"},
+		{cond: enum.FPredUNE, want: "_0 := x != y"},
+		{cond: enum.FPredUNO, want: "_0 := math.IsNaN(x) || math.IsNaN(y)"},
+		{cond: enum.FPredTrue, want: "_0 := true"},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, y, func(block *ir.Block) ir.Instruction {
+			return block.NewFCmp(g.cond, x, y)
+		})
+		d := NewDecompiler()
+		got := instString(t, d, inst)
+		if got != g.want {
+			t.Errorf("%v: statement mismatch; expected %q, got %q", g.cond, g.want, got)
+		}
+	}
+
+	// NaN checks of single precision operands.
+	f := ir.NewParam("f", types.Float)
+	inst := newTestInst(f, f, func(block *ir.Block) ir.Instruction {
+		return block.NewFCmp(enum.FPredUNO, f, constant.NewFloat(types.Float, 1))
+	})
+	d := NewDecompiler()
+	want := "_0 := math.IsNaN(float64(f)) || math.IsNaN(float64(1.0))"
+	if got := instString(t, d, inst); got != want {
+		t.Errorf("statement mismatch; expected %q, got %q", want, got)
+	}
+}
+
+// newTestInst returns the instruction created by newInst in the entry basic
+// block of a function with the given parameters, after local IDs have been
+// assigned.
+func newTestInst(x, y *ir.Param, newInst func(block *ir.Block) ir.Instruction) ir.Instruction {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.Void, x, y)
+	block := f.NewBlock("entry")
+	inst := newInst(block)
+	block.NewRet(nil)
+	if err := f.AssignIDs(); err != nil {
+		panic(err)
+	}
+	return inst
+}
+
+func TestInstSelect(t *testing.T) {
+	m := ir.NewModule()
+	c := ir.NewParam("c", types.I1)
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	f := m.NewFunc("f", types.I32, c, x, y)
+	block := f.NewBlock("entry")
+	sel := block.NewSelect(c, x, y)
+	sum := block.NewAdd(sel, constant.NewInt(types.I32, 1))
+	block.NewRet(sum)
+	if err := f.AssignIDs(); err != nil {
+		t.Fatalf("unable to assign IDs; %v", err)
+	}
+	d := NewDecompiler()
+	var got []string
+	stmts, err := d.insts(block.Insts)
+	if err != nil {
+		t.Fatalf("unable to decompile instructions; %v", err)
+	}
+	for _, stmt := range stmts {
+		got = append(got, nodeString(t, stmt))
+	}
+	want := []string{
+		"var _0 int32",
+		"if c {\n\t_0 = x\n} else {\n\t_0 = y\n}",
+		"_1 := _0 + 1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("number of statements mismatch; expected %d, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d mismatch; expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestInstAlloca(t *testing.T) {
+	m := ir.NewModule()
+	i := ir.NewParam("i", types.I64)
+	f := m.NewFunc("f", types.I32, i)
+	block := f.NewBlock("entry")
+	// Scalar alloca.
+	x := block.NewAlloca(types.I32)
+	block.NewStore(constant.NewInt(types.I32, 42), x)
+	load := block.NewLoad(elemType(x), x)
+	// Indexed array alloca.
+	arr := block.NewAlloca(types.NewArray(10, types.I32))
+	zero := constant.NewInt(types.I64, 0)
+	elem := block.NewGetElementPtr(elemType(arr), arr, zero, i)
+	block.NewStore(load, elem)
+	first := block.NewGetElementPtr(elemType(arr), arr, zero, zero)
+	block.NewRet(block.NewLoad(elemType(first), first))
+	if err := f.AssignIDs(); err != nil {
+		t.Fatalf("unable to assign IDs; %v", err)
+	}
+	d := NewDecompiler()
+	var got []string
+	stmts, err := d.insts(block.Insts)
+	if err != nil {
+		t.Fatalf("unable to decompile instructions; %v", err)
+	}
+	for _, stmt := range stmts {
+		got = append(got, nodeString(t, stmt))
+	}
+	want := []string{
+		"var _0 int32",
+		"_0 = 42",
+		"_1 := _0",
+		"var _2 [10]int32",
+		"_3 := &_2[i]",
+		"*_3 = _1",
+		"_4 := &_2[0]",
+		"_5 := *_4",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("number of statements mismatch; expected %d, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d mismatch; expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestInstIntConv(t *testing.T) {
+	// The zext and sext cases of each source value differ.
+	x := ir.NewParam("x", types.I32)
+	b := ir.NewParam("b", types.I1)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewTrunc(x, types.I8) },
+			want:    "_0 := int8(x)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewTrunc(x, types.I1) },
+			want:    "_0 := x&1 != 0",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewZExt(x, types.I64) },
+			want:    "_0 := int64(uint32(x))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewSExt(x, types.I64) },
+			want:    "_0 := int64(x)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewZExt(b, types.I32) },
+			want:    "_0 := boolToInt32(b)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewSExt(b, types.I32) },
+			want:    "_0 := -boolToInt32(b)",
+		},
+		// The helper function is named after the underlying Go type.
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewZExt(b, types.NewInt(24)) },
+			want:    "_0 := boolToInt32(b)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewZExt(constant.NewInt(types.I8, -1), types.I32)
+			},
+			want: "_0 := int32(uint8(255))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewSExt(constant.NewInt(types.I8, -1), types.I32)
+			},
+			want: "_0 := int32(-1)",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, b, g.newInst)
+		d := NewDecompiler()
+		instStmts, err := d.instStmts(inst)
+		if err != nil {
+			t.Errorf("%v: unable to decompile instruction; %v", inst, err)
+			continue
+		}
+		var stmts []string
+		for _, stmt := range instStmts {
+			stmts = append(stmts, nodeString(t, stmt))
+		}
+		if got := strings.Join(stmts, "\n"); got != g.want {
+			t.Errorf("%v: Go statement mismatch; expected %q, got %q", inst, g.want, got)
+		}
+	}
+}
+
+func TestBoolConv(t *testing.T) {
+	//    int32_t less(int32_t a, int32_t b) {
+	//       return a < b;
+	//    }
+	m := ir.NewModule()
+	a := ir.NewParam("a", types.I32)
+	b := ir.NewParam("b", types.I32)
+	f := m.NewFunc("less", types.I32, a, b)
+	entry := f.NewBlock("entry")
+	cmp := entry.NewICmp(enum.IPredSLT, a, b)
+	entry.NewRet(entry.NewZExt(cmp, types.I32))
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	want := `package main
+
+func less(a int32, b int32) int32 {
+	_0 := a < b
+	_1 := boolToInt32(_0)
+	return _1
+}
+func boolToInt32(x bool) int32 {
+	if x {
+		return 1
+	}
+	return 0
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("less.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
+
+func TestInstFloatConv(t *testing.T) {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewParam("f", types.Double)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFPToSI(f, types.I32) },
+			want:    "_0 := int32(f)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFPToUI(f, types.I32) },
+			want:    "_0 := int32(uint32(f))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewSIToFP(x, types.Double) },
+			want:    "_0 := float64(x)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewUIToFP(x, types.Double) },
+			want:    "_0 := float64(uint32(x))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewFPTrunc(f, types.Float) },
+			want:    "_0 := float32(f)",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewFPExt(constant.NewFloat(types.Float, 1.5), types.Double)
+			},
+			want: "_0 := float64(1.5)",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, f, g.newInst)
+		d := NewDecompiler()
+		if got := instString(t, d, inst); got != g.want {
+			t.Errorf("%v: Go statement mismatch; expected %q, got %q", inst, g.want, got)
+		}
+	}
+}
+
+func TestInstFloatConvRoundTrip(t *testing.T) {
+	// sitofp followed by fptoui.
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	block := f.NewBlock("entry")
+	fp := block.NewSIToFP(x, types.Double)
+	i := block.NewFPToUI(fp, types.I32)
+	block.NewRet(i)
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	src := nodeString(t, fn)
+	want := "func f(x int32) int32 {\n\t_0 := float64(x)\n\t_1 := int32(uint32(_0))\n\treturn _1\n}"
+	if src != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, src)
+	}
+	typeCheck(t, src)
+}
+
+func TestInstPtrConv(t *testing.T) {
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	x := ir.NewParam("x", types.I64)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewPtrToInt(p, types.I64) },
+			want:    "_0 := int64(uintptr(unsafe.Pointer(p))) /* unsafe */",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewIntToPtr(x, types.NewPointer(types.I32))
+			},
+			want: "_0 := (*int32)(unsafe.Pointer(uintptr(x))) /* unsafe */",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewBitCast(p, types.NewPointer(types.Float))
+			},
+			want: "_0 := (*float32)(unsafe.Pointer(p)) /* unsafe */",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewBitCast(x, types.Double) },
+			want:    "_0 := math.Float64frombits(uint64(x))",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				return block.NewBitCast(x, types.NewVector(2, types.I32))
+			},
+			want: "_0 := *(*[2]int32 /* vector */)(unsafe.Pointer(&x)) /* unsafe */",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(p, x, g.newInst)
+		d := NewDecompiler()
+		if got := instString(t, d, inst); got != g.want {
+			t.Errorf("%v: Go statement mismatch; expected %q, got %q", inst, g.want, got)
+		}
+	}
+}
+
+func TestInstPtrConvTypeCheck(t *testing.T) {
+	// ptrtoint, inttoptr and bitcast round trip.
+	m := ir.NewModule()
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	f := m.NewFunc("f", types.Float, p)
+	block := f.NewBlock("entry")
+	addr := block.NewPtrToInt(p, types.I64)
+	q := block.NewIntToPtr(addr, types.NewPointer(types.I32))
+	x := block.NewLoad(elemType(q), q)
+	y := block.NewBitCast(x, types.Float)
+	block.NewRet(y)
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	src := nodeString(t, fn)
+	typeCheck(t, "import (\n\t\"math\"\n\t\"unsafe\"\n)\n\n"+src)
+}
+
+func TestInstVAArg(t *testing.T) {
+	//    declare void @print(i8*, ...)
+	//
+	//    define i32 @sum(i8* %ap, ...) {
+	//       %1 = va_arg i8* %ap, i32
+	//       %2 = va_arg i8* %ap, i32
+	//       %3 = add i32 %1, %2
+	//       call void (i8*, ...) @print(i8* null, i32 %3, i32 5)
+	//       ret i32 %3
+	//    }
+	m := ir.NewModule()
+	print := m.NewFunc("print", types.Void, ir.NewParam("format", types.NewPointer(types.I8)))
+	print.Sig.Variadic = true
+	ap := ir.NewParam("ap", types.NewPointer(types.I8))
+	f := m.NewFunc("sum", types.I32, ap)
+	f.Sig.Variadic = true
+	entry := f.NewBlock("entry")
+	x := entry.NewVAArg(ap, types.I32)
+	y := entry.NewVAArg(ap, types.I32)
+	sum := entry.NewAdd(x, y)
+	entry.NewCall(print, constant.NewNull(types.NewPointer(types.I8)), sum, constant.NewInt(types.I32, 5))
+	entry.NewRet(sum)
+
+	d := NewDecompiler()
+	want := "func(*int8, ...interface{})"
+	if got := nodeString(t, d.GoType(print.Sig)); got != want {
+		t.Errorf("type mismatch; expected %q, got %q", want, got)
+	}
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want = `func sum(ap *int8, _va ...interface{}) int32 {
+	_0 := _va[0].(int32)
+	_va = _va[1:]
+	_1 := _va[0].(int32)
+	_va = _va[1:]
+	_2 := _0 + _1
+	_print((*int8)(nil), _2, int32(5))
+	return _2
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, "func _print(format *int8, _va ...interface{}) {}\n\n"+got)
+}
+
+func TestInstAggregate(t *testing.T) {
+	// %struct.inner = type { i8, [2 x i32] }
+	// %struct.outer = type { i32, %struct.inner }
+	m := ir.NewModule()
+	inner := m.NewTypeDef("struct.inner", types.NewStruct(types.I8, types.NewArray(2, types.I32)))
+	outer := m.NewTypeDef("struct.outer", types.NewStruct(types.I32, inner))
+	x := ir.NewParam("x", outer)
+	f := m.NewFunc("f", outer, x)
+	entry := f.NewBlock("entry")
+	// Extract nested field.
+	elem := entry.NewExtractValue(x, 1, 1, 0)
+	// Insert into two-level struct.
+	y := entry.NewInsertValue(x, elem, 1, 1, 1)
+	entry.NewRet(y)
+
+	d := NewDecompiler()
+	var decls []string
+	for _, typ := range m.TypeDefs {
+		decls = append(decls, nodeString(t, d.TypeDecl(typ)))
+	}
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(x outer) outer {
+	_0 := x.Field1.Field1[0]
+	_1 := x
+	_1.Field1.Field1[1] = _0
+	return _1
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	decls = append(decls, got)
+	typeCheck(t, strings.Join(decls, "\n\n"))
+}
+
+func TestInstVector(t *testing.T) {
+	i32 := func(x int64) constant.Constant {
+		return constant.NewInt(types.I32, x)
+	}
+	vec := types.NewVector(4, types.I32)
+	x := ir.NewParam("x", vec)
+	y := ir.NewParam("y", vec)
+	golden := []struct {
+		newInst func(block *ir.Block) ir.Instruction
+		want    string
+	}{
+		// Reverse the elements of a 4-lane vector.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				mask := constant.NewVector(types.NewVector(4, types.I32), i32(3), i32(2), i32(1), i32(0))
+				return block.NewShuffleVector(x, constant.NewUndef(vec), mask)
+			},
+			want: "_0 := [4]int32 /* vector */ {x[3], x[2], x[1], x[0]}",
+		},
+		// Interleave two vectors, with an undefined lane.
+		{
+			newInst: func(block *ir.Block) ir.Instruction {
+				mask := constant.NewVector(types.NewVector(4, types.I32), i32(0), i32(4), i32(1), constant.NewUndef(types.I32))
+				return block.NewShuffleVector(x, y, mask)
+			},
+			want: "_0 := [4]int32 /* vector */ {x[0], y[0], x[1], 0}",
+		},
+		{
+			newInst: func(block *ir.Block) ir.Instruction { return block.NewExtractElement(x, i32(2)) },
+			want:    "_0 := x[2]",
+		},
+	}
+	for _, g := range golden {
+		inst := newTestInst(x, y, g.newInst)
+		d := withComments(NewDecompiler())
+		if got := instString(t, d, inst); got != g.want {
+			t.Errorf("%v: Go statement mismatch; expected %q, got %q", inst, g.want, got)
+		}
+	}
+
+	// Insert element and reverse the elements.
+	m := ir.NewModule()
+	f := m.NewFunc("f", vec, x)
+	entry := f.NewBlock("entry")
+	z := entry.NewInsertElement(x, i32(7), i32(0))
+	rev := entry.NewShuffleVector(z, constant.NewUndef(vec), constant.NewVector(types.NewVector(4, types.I32), i32(3), i32(2), i32(1), i32(0)))
+	entry.NewRet(rev)
+	d := withComments(NewDecompiler())
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := "func f(x [4]int32 /* vector */) [4]int32 /* vector */ {\n\t_0 := x\n\t_0[0] = 7\n\t_1 := [4]int32 /* vector */ {_0[3], _0[2], _0[1], _0[0]}\n\treturn _1\n}"
+	got := commentString(t, d, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestIRComments(t *testing.T) {
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	sum := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+	entry.NewRet(sum)
+	d := NewDecompiler()
+	d.IRComments = true
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	// The comments survive formatting.
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to print Go source file; %v", err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	want := "package main\n\nfunc f(x int32) int32 {\n\t// %0 = add i32 %x, 1\n\t_0 := x + 1\n\t// ret i32 %0\n\treturn _0\n}\n"
+	if got := string(src); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestInstFence(t *testing.T) {
+	// The fence is kept between the two stores.
+	//
+	//    void f(int *data, int *ready) {
+	//       *data = 42;
+	//       atomic_thread_fence(memory_order_release);
+	//       *ready = 1;
+	//    }
+	m := ir.NewModule()
+	data := ir.NewParam("data", types.NewPointer(types.I32))
+	ready := ir.NewParam("ready", types.NewPointer(types.I32))
+	f := m.NewFunc("f", types.Void, data, ready)
+	entry := f.NewBlock("entry")
+	entry.NewStore(constant.NewInt(types.I32, 42), data)
+	entry.NewFence(enum.AtomicOrderingRelease)
+	entry.NewStore(constant.NewInt(types.I32, 1), ready)
+	entry.NewRet(nil)
+	d := withComments(NewDecompiler())
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(data *int32, ready *int32) {
+	*data = 42
+	// memory barrier (fence release)
+	*ready = 1
+	return
+}`
+	got := commentString(t, d, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestInstFreeze(t *testing.T) {
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	frozen := ir.NewInstFreeze(x)
+	entry.Insts = append(entry.Insts, frozen)
+	sum := entry.NewAdd(frozen, constant.NewInt(types.I32, 1))
+	entry.NewRet(sum)
+	d := withComments(NewDecompiler())
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(x int32) int32 {
+	_0 := x	/* freeze */
+	_1 := _0 + 1
+	return _1
+}`
+	got := commentString(t, d, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+// instString returns the Go source code representation of the Go statement of
+// the given instruction, including comments.
+func instString(t *testing.T, d *Decompiler, inst ir.Instruction) string {
+	stmt, err := withComments(d).inst(inst)
+	if err != nil {
+		t.Fatalf("unable to decompile instruction %v; %v", inst, err)
+	}
+	return commentString(t, d, stmt)
+}
@@ -0,0 +1,347 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// intrinsic converts the given call to an LLVM intrinsic function into a
+// corresponding list of Go statements, using the Go builtin or standard
+// library function of the intrinsic; e.g.
+//
+//    copy(unsafe.Slice(dst, n), unsafe.Slice(src, n))  // call void @llvm.memcpy.p0i8.p0i8.i64(i8* dst, i8* src, i64 n, i1 false)
+//    _0 := math.Sqrt(x)                                 // %0 = call double @llvm.sqrt.f64(double x)
+//    _0 := int32(bits.OnesCount32(uint32(x)))           // %0 = call i32 @llvm.ctpop.i32(i32 x)
+//
+// The boolean return value indicates success; calls to unknown intrinsics are
+// decompiled as regular calls.
+func (d *Decompiler) intrinsic(inst *ir.InstCall) ([]ast.Stmt, bool, error) {
+	callee, ok := inst.Callee.(*ir.Func)
+	if !ok || !strings.HasPrefix(callee.Name(), "llvm.") {
+		return nil, false, nil
+	}
+	// Intrinsic names have the form "llvm.<name>.<overload types>"; e.g.
+	// "llvm.sadd.with.overflow.i32".
+	name := strings.TrimPrefix(callee.Name(), "llvm.")
+	if pos := strings.Index(name, ".with.overflow."); pos != -1 {
+		return d.overflow(inst, name[:pos])
+	}
+	if pos := strings.Index(name, "."); pos != -1 {
+		name = name[:pos]
+	}
+	switch name {
+	case "memcpy", "memmove":
+		// Go's copy handles overlapping slices.
+		n := inst.Args[2]
+		dst, err := d.byteSlice(inst.Args[0], n)
+		if err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		src, err := d.byteSlice(inst.Args[1], n)
+		if err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		call := &ast.CallExpr{
+			Fun:  ast.NewIdent("copy"),
+			Args: []ast.Expr{dst, src},
+		}
+		return []ast.Stmt{&ast.ExprStmt{X: call}}, true, nil
+	case "memset":
+		stmt, err := d.memset(inst.Args[0], inst.Args[1], inst.Args[2])
+		if err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		return []ast.Stmt{stmt}, true, nil
+	}
+	if funcName, ok := mathIntrinsics[name]; ok {
+		typ, ok := inst.Sig().RetType.(*types.FloatType)
+		if !ok {
+			return nil, false, nil
+		}
+		var args []ast.Expr
+		for _, arg := range inst.Args {
+			expr, err := d.float64Arg(arg)
+			if err != nil {
+				return nil, false, errors.WithStack(err)
+			}
+			args = append(args, expr)
+		}
+		return []ast.Stmt{d.define(inst.Name(), d.mathCall(typ, funcName, args...))}, true, nil
+	}
+	if funcName, ok := bitsIntrinsics[name]; ok {
+		typ, ok := inst.Sig().RetType.(*types.IntType)
+		if !ok || !isGoIntSize(typ.BitSize) {
+			return nil, false, nil
+		}
+		x, err := d.unsigned(inst.Args[0])
+		if err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		call := &ast.CallExpr{
+			Fun:  d.pkgSel("math/bits", fmt.Sprintf("%s%d", funcName, typ.BitSize)),
+			Args: []ast.Expr{x},
+		}
+		return []ast.Stmt{d.define(inst.Name(), d.conv(d.GoType(typ), call))}, true, nil
+	}
+	return nil, false, nil
+}
+
+// mathIntrinsics maps from LLVM intrinsic name to the corresponding function of
+// the math package.
+var mathIntrinsics = map[string]string{
+	"sqrt":  "Sqrt",
+	"fabs":  "Abs",
+	"floor": "Floor",
+	"ceil":  "Ceil",
+	"trunc": "Trunc",
+	"sin":   "Sin",
+	"cos":   "Cos",
+	"exp":   "Exp",
+	"log":   "Log",
+	"pow":   "Pow",
+}
+
+// bitsIntrinsics maps from LLVM intrinsic name to the corresponding function of
+// the math/bits package, without the size suffix (e.g. OnesCount32).
+var bitsIntrinsics = map[string]string{
+	"ctpop": "OnesCount",
+	"ctlz":  "LeadingZeros",
+	"cttz":  "TrailingZeros",
+	"bswap": "ReverseBytes",
+}
+
+// isGoIntSize reports whether the given integer size in bits is the size of a
+// Go integer type.
+func isGoIntSize(size uint64) bool {
+	switch size {
+	case 8, 16, 32, 64:
+		return true
+	}
+	return false
+}
+
+// float64Arg returns the Go expression of the given floating-point value,
+// converted to float64 if needed, as expected by the functions of the math
+// package.
+func (d *Decompiler) float64Arg(x value.Value) (ast.Expr, error) {
+	arg, err := d.Value(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	goType, ok := d.GoType(x.Type()).(*ast.Ident)
+	if !ok {
+		if err := d.unsupported("floating-point argument of type", x.Type()); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	if goType.Name != "float64" {
+		arg = d.conv(ast.NewIdent("float64"), arg)
+	}
+	return arg, nil
+}
+
+// byteSlice returns a Go slice expression of n bytes, starting at the address
+// of the given pointer; e.g.
+//
+//    unsafe.Slice(p, n)                           // *int8
+//    p[:n]                                        // []byte
+//    unsafe.Slice((*byte)(p), n)                  // unsafe.Pointer
+//    unsafe.Slice((*byte)(unsafe.Pointer(p)), n)  // other pointer types
+func (d *Decompiler) byteSlice(ptr, n value.Value) (ast.Expr, error) {
+	ops, err := d.values(ptr, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p, size := ops[0], ops[1]
+	typ := d.GoType(ptr.Type())
+	switch {
+	case isInt8Ptr(typ):
+		// nothing to do.
+	case isUnsafePointer(typ):
+		p = d.conv(&ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent("byte")}}, p)
+	default:
+		if _, ok := typ.(*ast.ArrayType); ok {
+			return &ast.SliceExpr{X: p, High: size}, nil
+		}
+		p = d.conv(&ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent("byte")}}, d.conv(d.unsafeSel("Pointer"), p))
+	}
+	return &ast.CallExpr{
+		Fun:  d.unsafeSel("Slice"),
+		Args: []ast.Expr{p, size},
+	}, nil
+}
+
+// isInt8Ptr reports whether the given Go type is *int8.
+func isInt8Ptr(typ ast.Expr) bool {
+	star, ok := typ.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	elem, ok := star.X.(*ast.Ident)
+	return ok && elem.Name == "int8"
+}
+
+// memset returns the Go statement of a call to llvm.memset, which fills n bytes
+// starting at the address of the given pointer with the given byte value. The
+// bytes are cleared if the value is zero, and otherwise assigned in a loop.
+//
+//    clear(unsafe.Slice(p, n))
+//
+//    for _i, _s := 0, unsafe.Slice(p, n); _i < len(_s); _i++ {
+//       _s[_i] = x
+//    }
+func (d *Decompiler) memset(ptr, x, n value.Value) (ast.Stmt, error) {
+	s, err := d.byteSlice(ptr, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if isZero(x) {
+		return &ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("clear"), Args: []ast.Expr{s}}}, nil
+	}
+	// The elements of the slice are int8 for *int8 pointers, and byte otherwise.
+	var elem ast.Expr
+	if isInt8Ptr(d.GoType(ptr.Type())) {
+		elem, err = d.Value(x)
+	} else {
+		elem, err = d.unsigned(x)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	i, sv := ast.NewIdent("_i"), ast.NewIdent("_s")
+	return &ast.ForStmt{
+		Init: &ast.AssignStmt{
+			Lhs: []ast.Expr{i, sv},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{intLit(0), s},
+		},
+		Cond: &ast.BinaryExpr{
+			X:  i,
+			Op: token.LSS,
+			Y:  &ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{sv}},
+		},
+		Post: &ast.IncDecStmt{X: i, Tok: token.INC},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.IndexExpr{X: sv, Index: i}},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{elem},
+			},
+		}},
+	}, nil
+}
+
+// overflow converts the given call to an arithmetic with overflow intrinsic
+// (e.g. llvm.sadd.with.overflow.i32) into a corresponding list of Go
+// statements. The {result, overflow} struct of the intrinsic is assigned the
+// two return values of a helper function, which is declared in the Go source
+// file of the module.
+//
+//    var _0 struct { Field0 int32; Field1 bool }
+//    _0.Field0, _0.Field1 = saddOverflow32(x, y)
+//
+// The boolean return value indicates success.
+func (d *Decompiler) overflow(inst *ir.InstCall, op string) ([]ast.Stmt, bool, error) {
+	body, ok := overflowHelpers[op]
+	if !ok || len(inst.Args) != 2 {
+		return nil, false, nil
+	}
+	typ, ok := inst.Args[0].Type().(*types.IntType)
+	if !ok || !isGoIntSize(typ.BitSize) {
+		return nil, false, nil
+	}
+	args, err := d.values(inst.Args...)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	name := fmt.Sprintf("%sOverflow%d", op, typ.BitSize)
+	r := strings.NewReplacer("{T}", fmt.Sprintf("int%d", typ.BitSize), "{U}", fmt.Sprintf("uint%d", typ.BitSize))
+	d.helper(name, r.Replace(fmt.Sprintf("func %s(x, y {T}) ({T}, bool) {\n%s\n}", name, body)))
+	result := d.local(inst.Name())
+	assign := &ast.AssignStmt{
+		Lhs: []ast.Expr{
+			&ast.SelectorExpr{X: result, Sel: fieldName(0)},
+			&ast.SelectorExpr{X: result, Sel: fieldName(1)},
+		},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.CallExpr{
+			Fun:  ast.NewIdent(name),
+			Args: args,
+		}},
+	}
+	return []ast.Stmt{d.varDecl(inst.Name(), d.GoType(inst.Sig().RetType)), assign}, true, nil
+}
+
+// overflowHelpers maps from arithmetic operation of with overflow intrinsics to
+// the body of the corresponding Go helper function, where {T} and {U} denote
+// the signed and unsigned Go integer types of the operands.
+var overflowHelpers = map[string]string{
+	"sadd": "z := x + y\nreturn z, (z < x) != (y < 0)",
+	"uadd": "z := {U}(x) + {U}(y)\nreturn {T}(z), z < {U}(x)",
+	"ssub": "z := x - y\nreturn z, (z < x) != (y > 0)",
+	"usub": "return x - y, {U}(x) < {U}(y)",
+	"smul": "z := x * y\nreturn z, x != 0 && (z/x != y || (x == -1 && y < 0 && z == y))",
+	"umul": "ux, uy := {U}(x), {U}(y)\nz := ux * uy\nreturn {T}(z), ux != 0 && z/ux != uy",
+}
+
+// A helperSet keeps track of the helper functions referenced by the Go source
+// code decompiled from a module, so that the helper functions may be declared
+// in the Go source file.
+//
+// A helperSet may be used concurrently by multiple goroutines.
+type helperSet struct {
+	sync.Mutex
+	// Go source code of helper functions; mapping from function name to source
+	// code.
+	srcs map[string]string
+}
+
+// newHelperSet returns a new helper set.
+func newHelperSet() *helperSet {
+	return &helperSet{
+		srcs: make(map[string]string),
+	}
+}
+
+// helper records the helper function with the given name and Go source code in
+// the helper set of the decompiler.
+func (d *Decompiler) helper(name, src string) {
+	if d.helpers == nil {
+		return
+	}
+	d.helpers.Lock()
+	d.helpers.srcs[name] = src
+	d.helpers.Unlock()
+}
+
+// decls returns the function declarations of the referenced helper functions,
+// sorted by name.
+func (s *helperSet) decls() []ast.Decl {
+	s.Lock()
+	defer s.Unlock()
+	var names []string
+	for name := range s.srcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var decls []ast.Decl
+	for _, name := range names {
+		file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n\n"+s.srcs[name], 0)
+		if err != nil {
+			panic(fmt.Sprintf("unable to parse helper function %q; %v", name, err))
+		}
+		decls = append(decls, file.Decls...)
+	}
+	return decls
+}
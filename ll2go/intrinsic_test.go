@@ -0,0 +1,101 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestIntrinsic(t *testing.T) {
+	m := ir.NewModule()
+	i8Ptr := types.NewPointer(types.I8)
+	memcpy := m.NewFunc("llvm.memcpy.p0i8.p0i8.i64", types.Void, ir.NewParam("", i8Ptr), ir.NewParam("", i8Ptr), ir.NewParam("", types.I64), ir.NewParam("", types.I1))
+	memset := m.NewFunc("llvm.memset.p0i8.i64", types.Void, ir.NewParam("", i8Ptr), ir.NewParam("", types.I8), ir.NewParam("", types.I64), ir.NewParam("", types.I1))
+	sqrt := m.NewFunc("llvm.sqrt.f32", types.Float, ir.NewParam("", types.Float))
+	sadd := m.NewFunc("llvm.sadd.with.overflow.i32", types.NewStruct(types.I32, types.I1), ir.NewParam("", types.I32), ir.NewParam("", types.I32))
+
+	// void f(char *dst, char *src, long n) {
+	//    memcpy(dst, src, n);
+	//    memset(src, 0, n);
+	//    memset(dst, -1, 4);
+	// }
+	dst := ir.NewParam("dst", i8Ptr)
+	src := ir.NewParam("src", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	f := m.NewFunc("f", types.Void, dst, src, n)
+	entry := f.NewBlock("entry")
+	entry.NewCall(memcpy, dst, src, n, constant.NewInt(types.I1, 0))
+	entry.NewCall(memset, src, constant.NewInt(types.I8, 0), n, constant.NewInt(types.I1, 0))
+	entry.NewCall(memset, dst, constant.NewInt(types.I8, -1), constant.NewInt(types.I64, 4), constant.NewInt(types.I1, 0))
+	entry.NewRet(nil)
+
+	// float g(float x) {
+	//    return sqrtf(x);
+	// }
+	x := ir.NewParam("x", types.Float)
+	g := m.NewFunc("g", types.Float, x)
+	entry = g.NewBlock("entry")
+	entry.NewRet(entry.NewCall(sqrt, x))
+
+	// bool h(int a, int b) {
+	//    int sum;
+	//    return __builtin_sadd_overflow(a, b, &sum);
+	// }
+	a := ir.NewParam("a", types.I32)
+	b := ir.NewParam("b", types.I32)
+	h := m.NewFunc("h", types.I1, a, b)
+	entry = h.NewBlock("entry")
+	entry.NewRet(entry.NewExtractValue(entry.NewCall(sadd, a, b), 1))
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `package main
+
+import (
+	"math"
+	"unsafe"
+)
+
+func f(dst *int8, src *int8, n int64) {
+	copy(unsafe.Slice(dst, n), unsafe.Slice(src, n))
+	clear(unsafe.Slice(src, n))
+	for _i, _s := 0, unsafe.Slice(dst, 4); _i < len(_s); _i++ {
+		_s[_i] = -1
+	}
+	return
+}
+func g(x float32) float32 {
+	_0 := float32(math.Sqrt(float64(x)))
+	return _0
+}
+func h(a int32, b int32) bool {
+	var _0 struct {
+		Field0 int32
+		Field1 bool
+	}
+	_0.Field0, _0.Field1 = saddOverflow32(a, b)
+	_1 := _0.Field1
+	return _1
+}
+func saddOverflow32(x, y int32) (int32, bool) {
+	z := x + y
+	return z, (z < x) != (y < 0)
+}
+`
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		t.Fatalf("unable to format Go source file; %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	if err := Verify("intrinsic.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
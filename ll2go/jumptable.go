@@ -0,0 +1,257 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// Switch statements of C are commonly lowered into jump tables; i.e. constant
+// arrays of blockaddress constants, indexed by the value of the switch (offset
+// by the smallest case value), from which the target of an indirectbr
+// terminator is loaded.
+//
+//    @table = constant [3 x i8*] [i8* blockaddress(@f, %a), i8* blockaddress(@f, %b), i8* blockaddress(@f, %c)]
+//
+//    %idx = sub i32 %x, 10
+//    %0 = zext i32 %idx to i64
+//    %1 = getelementptr [3 x i8*], [3 x i8*]* @table, i64 0, i64 %0
+//    %2 = load i8*, i8** %1
+//    indirectbr i8* %2, [label %a, label %b, label %c]
+//
+// The jump table idiom is recovered into a Go switch statement on the original
+// value, with one case value per entry of the jump table.
+//
+//    switch x {
+//    case 10:
+//       ...
+//    case 11:
+//       ...
+//    case 12:
+//       ...
+//    default:
+//       panic("invalid jump table index")
+//    }
+
+// A jumpTable is the target address of an indirectbr terminator lowered from a
+// switch, as loaded from a jump table of blockaddress constants.
+type jumpTable struct {
+	// Jump table of blockaddress constants.
+	table *ir.Global
+	// Value of the switch.
+	x value.Value
+	// Case value of the first entry of the jump table; i.e. the offset of the
+	// value of the switch from the index into the jump table.
+	offset int64
+	// Target basic blocks of the jump table, in order of entry.
+	targets []*ir.Block
+	// Instructions computing the target address from the value of the switch,
+	// which are subsumed by the Go switch statement.
+	insts map[ir.Instruction]bool
+}
+
+// String returns the LLVM IR assembly of the target address.
+func (jt *jumpTable) String() string {
+	return fmt.Sprintf("jumptable %v[%v - %d]", jt.table.Ident(), jt.x.Ident(), jt.offset)
+}
+
+// Type returns the type of the target address.
+func (jt *jumpTable) Type() types.Type {
+	return types.NewPointer(types.I8)
+}
+
+// Ident returns the identifier associated with the target address.
+func (jt *jumpTable) Ident() string {
+	return jt.String()
+}
+
+// findJumpTable returns the jump table from which the target address of the
+// indirectbr terminator of the given basic block is loaded, and a boolean
+// indicating success.
+//
+// The jump table must be a constant global variable of blockaddress constants
+// of the function, and the instructions computing the target address must be
+// part of the basic block of the terminator, and have no other uses.
+func findJumpTable(block *ir.Block, term *ir.TermIndirectBr) (*jumpTable, bool) {
+	uses := useCounts(block.Parent)
+	// subsumed reports whether the given instruction may be subsumed by the Go
+	// switch statement.
+	subsumed := func(inst ir.Instruction) bool {
+		if uses[inst.(value.Value)] != 1 {
+			return false
+		}
+		for _, i := range block.Insts {
+			if i == inst {
+				return true
+			}
+		}
+		return false
+	}
+	jt := &jumpTable{insts: make(map[ir.Instruction]bool)}
+	// %2 = load i8*, i8** %1
+	load, ok := term.Addr.(*ir.InstLoad)
+	if !ok || load.Volatile || !subsumed(load) {
+		return nil, false
+	}
+	// %1 = getelementptr [3 x i8*], [3 x i8*]* @table, i64 0, i64 %0
+	gep, ok := load.Src.(*ir.InstGetElementPtr)
+	if !ok || !subsumed(gep) || len(gep.Indices) != 2 || !isZero(gep.Indices[0]) {
+		return nil, false
+	}
+	table, ok := gep.Src.(*ir.Global)
+	if !ok || !table.Immutable {
+		return nil, false
+	}
+	elems, ok := table.Init.(*constant.Array)
+	if !ok {
+		return nil, false
+	}
+	for _, elem := range elems.Elems {
+		addr, ok := elem.(*constant.BlockAddress)
+		if !ok || addr.Func != block.Parent {
+			return nil, false
+		}
+		target, ok := addr.Block.(*ir.Block)
+		if !ok {
+			return nil, false
+		}
+		jt.targets = append(jt.targets, target)
+	}
+	jt.table = table
+	jt.insts[load] = true
+	jt.insts[gep] = true
+	// Recover the value of the switch from the index into the jump table, by
+	// skipping integer conversions and the subtraction of the smallest case
+	// value. The skipped instructions are subsumed unless used elsewhere (e.g.
+	// by the bounds check of the jump table).
+	//
+	//    %idx = sub i32 %x, 10
+	//    %0 = zext i32 %idx to i64
+	jt.x = gep.Indices[1]
+	unused := true
+	for {
+		inst, ok := jt.x.(ir.Instruction)
+		if !ok {
+			break
+		}
+		x, ok := jt.skip(inst)
+		if !ok {
+			break
+		}
+		unused = unused && subsumed(inst)
+		if unused {
+			jt.insts[inst] = true
+		}
+		jt.x = x
+	}
+	return jt, true
+}
+
+// skip returns the operand of the given instruction computing the index into
+// the jump table, adjusting the offset of the jump table, and a boolean
+// indicating success.
+func (jt *jumpTable) skip(inst ir.Instruction) (value.Value, bool) {
+	switch inst := inst.(type) {
+	case *ir.InstZExt:
+		return inst.From, true
+	case *ir.InstSExt:
+		return inst.From, true
+	case *ir.InstSub:
+		if c, ok := inst.Y.(*constant.Int); ok && c.X.IsInt64() {
+			jt.offset += c.X.Int64()
+			return inst.X, true
+		}
+	case *ir.InstAdd:
+		if c, ok := inst.Y.(*constant.Int); ok && c.X.IsInt64() {
+			jt.offset -= c.X.Int64()
+			return inst.X, true
+		}
+	}
+	return nil, false
+}
+
+// useCounts returns the number of uses of each value within the given
+// function; mapping from value to number of uses.
+func useCounts(f *ir.Func) map[value.Value]int {
+	uses := make(map[value.Value]int)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			for _, op := range operands(inst) {
+				uses[op]++
+			}
+		}
+		for _, op := range termOperands(block.Term) {
+			uses[op]++
+		}
+	}
+	return uses
+}
+
+// jumpTableBlock returns the conceptual basic block of the given LLVM IR basic
+// block, which is terminated by an indirectbr terminator loading its target
+// address from the given jump table. The instructions computing the target
+// address are removed from the basic block, and the target address of the
+// indirectbr is replaced by the jump table, leaving the original basic block
+// unmodified.
+func jumpTableBlock(block *ir.Block, term *ir.TermIndirectBr, jt *jumpTable) *basicBlock {
+	b := *block
+	b.Insts = nil
+	for _, inst := range block.Insts {
+		if !jt.insts[inst] {
+			b.Insts = append(b.Insts, inst)
+		}
+	}
+	b.Term = &ir.TermIndirectBr{
+		Addr:         jt,
+		ValidTargets: term.ValidTargets,
+		Metadata:     term.Metadata,
+	}
+	return &basicBlock{Block: &b}
+}
+
+// jumpTableSwitch converts the given jump table into a corresponding Go switch
+// statement on the value of the switch.
+//
+// Entries with the same target basic block are grouped into a single case
+// clause, in order of first entry. The statements of target basic blocks which
+// have no other predecessors are inlined into the corresponding case clause
+// (see caseBody). Indices outside of the jump table are invalid.
+func (fc *funcContext) jumpTableSwitch(jt *jumpTable) (ast.Stmt, error) {
+	body := &ast.BlockStmt{}
+	targetClause := make(map[string]*ast.CaseClause)
+	for i, target := range jt.targets {
+		clause, ok := targetClause[target.Name()]
+		if !ok {
+			stmts, err := fc.caseBody(target)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			clause = &ast.CaseClause{
+				Body: stmts,
+			}
+			targetClause[target.Name()] = clause
+			body.List = append(body.List, clause)
+		}
+		clause.List = append(clause.List, intLit(jt.offset+int64(i)))
+	}
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("invalid jump table index")}},
+	}
+	body.List = append(body.List, &ast.CaseClause{Body: []ast.Stmt{&ast.ExprStmt{X: call}}})
+	tag, err := fc.Value(jt.x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.SwitchStmt{
+		Tag:  tag,
+		Body: body,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestJumpTable(t *testing.T) {
+	//    int f(int x) {
+	//       switch (x) {
+	//       case 10:
+	//          return 1;
+	//       case 11:
+	//       case 13:
+	//          return 2;
+	//       case 12:
+	//          return 3;
+	//       default:
+	//          return 0;
+	//       }
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	dispatch := f.NewBlock("dispatch")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	c := f.NewBlock("c")
+	def := f.NewBlock("default")
+	table := m.NewGlobalDef("table", constant.NewArray(types.NewArray(4, types.I8Ptr),
+		constant.NewBlockAddress(f, a),
+		constant.NewBlockAddress(f, b),
+		constant.NewBlockAddress(f, c),
+		constant.NewBlockAddress(f, b),
+	))
+	table.Immutable = true
+	// The index into the jump table is also used by the bounds check.
+	idx := entry.NewSub(x, constant.NewInt(types.I32, 10))
+	idx.SetName("idx")
+	inRange := entry.NewICmp(enum.IPredULT, idx, constant.NewInt(types.I32, 4))
+	inRange.SetName("in_range")
+	entry.NewCondBr(inRange, dispatch, def)
+	ext := dispatch.NewZExt(idx, types.I64)
+	p := dispatch.NewGetElementPtr(elemType(table), table, constant.NewInt(types.I64, 0), ext)
+	addr := dispatch.NewLoad(elemType(p), p)
+	dispatch.NewIndirectBr(addr, a, b, c)
+	a.NewRet(constant.NewInt(types.I32, 1))
+	b.NewRet(constant.NewInt(types.I32, 2))
+	c.NewRet(constant.NewInt(types.I32, 3))
+	def.NewRet(constant.NewInt(types.I32, 0))
+
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	want := `func f(x int32) int32 {
+	idx := x - 10
+	in_range := uint32(idx) < uint32(4)
+	if !in_range {
+		return 0
+	}
+	switch x {
+	case 10:
+		return 1
+	case 11, 13:
+		return 2
+	case 12:
+		return 3
+	default:
+		panic("invalid jump table index")
+	}
+}`
+	fn := file.Decls[len(file.Decls)-1]
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,56 @@
+package ll2go
+
+import (
+	"go/ast"
+
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// A CallLowering converts the given LLVM IR call instruction into a
+// corresponding Go statement, overriding the default lowering of calls to a
+// given function (see RegisterCallLowering).
+//
+// The Go expressions of the call arguments are given by d.Value. The result of
+// non-void calls is referred to by the Go expression of the call instruction
+// itself, and must thus be defined by the returned statement; e.g.
+//
+//    lhs, err := d.Value(call)
+//    ...
+//    return &ast.AssignStmt{Lhs: []ast.Expr{lhs}, Tok: token.DEFINE, Rhs: ...}, nil
+type CallLowering func(d *Decompiler, call *ir.InstCall) (ast.Stmt, error)
+
+// RegisterCallLowering registers the given lowering of calls to the named
+// function, which takes precedence over the default lowering of calls,
+// including calls to LLVM intrinsics (e.g. "llvm.memcpy.p0i8.p0i8.i64").
+// Registering a lowering of an already registered function name replaces the
+// previous lowering.
+//
+// Lowerings are registered before decompilation, and must not be registered
+// concurrently with the decompilation of functions.
+func (d *Decompiler) RegisterCallLowering(name string, fn CallLowering) {
+	if d.calls == nil {
+		d.calls = make(map[string]CallLowering)
+	}
+	d.calls[name] = fn
+}
+
+// lowerCall converts the given LLVM IR call instruction into a corresponding Go
+// statement, using the registered lowering of the callee, if any. The boolean
+// return value indicates success; calls to functions without registered
+// lowerings are decompiled as usual.
+func (d *Decompiler) lowerCall(inst *ir.InstCall) (ast.Stmt, bool, error) {
+	callee, ok := inst.Callee.(*ir.Func)
+	if !ok {
+		return nil, false, nil
+	}
+	fn, ok := d.calls[callee.Name()]
+	if !ok {
+		return nil, false, nil
+	}
+	stmt, err := fn(d, inst)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "unable to lower call to %q", callee.Name())
+	}
+	return stmt, true, nil
+}
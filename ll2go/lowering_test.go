@@ -0,0 +1,57 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestRegisterCallLowering(t *testing.T) {
+	m := ir.NewModule()
+	popcnt := m.NewFunc("my.popcnt", types.I32, ir.NewParam("", types.I32))
+	abs := m.NewFunc("abs", types.I32, ir.NewParam("", types.I32))
+
+	// int f(int x) {
+	//    return abs(__my_popcnt(x) + 1);
+	// }
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	n := entry.NewCall(popcnt, x)
+	sum := entry.NewAdd(n, constant.NewInt(types.I32, 1))
+	entry.NewRet(entry.NewCall(abs, sum))
+
+	d := NewDecompiler()
+	calls := 0
+	d.RegisterCallLowering("my.popcnt", func(d *Decompiler, call *ir.InstCall) (ast.Stmt, error) {
+		calls++
+		lhs, err := d.Value(call)
+		if err != nil {
+			return nil, err
+		}
+		arg, err := d.Value(call.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		expr := &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent("mylib"), Sel: ast.NewIdent("PopCount")},
+			Args: []ast.Expr{arg},
+		}
+		return &ast.AssignStmt{Lhs: []ast.Expr{lhs}, Tok: token.DEFINE, Rhs: []ast.Expr{expr}}, nil
+	})
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := "func f(x int32) int32 {\n\t_0 := mylib.PopCount(x)\n\t_1 := _0 + 1\n\t_2 := abs(_1)\n\treturn _2\n}"
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	if calls != 1 {
+		t.Errorf("number of lowered calls mismatch; expected 1, got %d", calls)
+	}
+}
@@ -0,0 +1,148 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/metadata"
+)
+
+// lineComment returns a line comment with the source location of the given
+// LLVM IR instruction or terminator, as specified by its !dbg metadata
+// attachment; e.g.
+//
+//    // line 12:5
+//
+// A nil list of statements is returned if line comments are disabled, if the
+// source location is not known, or if comments are not tracked.
+func (d *Decompiler) lineComment(inst interface{}) []ast.Stmt {
+	if !d.LineComments {
+		return nil
+	}
+	line, col, ok := debugLoc(dbgAttachment(inst))
+	if !ok {
+		return nil
+	}
+	text := fmt.Sprintf("line %d", line)
+	if col != 0 {
+		text = fmt.Sprintf("line %d:%d", line, col)
+	}
+	return d.commentStmts(text)
+}
+
+// dbgAttachment returns the !dbg metadata attachment of the given LLVM IR
+// instruction or terminator, or nil if not present.
+func dbgAttachment(inst interface{}) metadata.MDNode {
+	// All LLVM IR instructions and terminators store their metadata attachments
+	// in a Metadata field.
+	md, ok := inst.(interface {
+		MDAttachments() []*metadata.Attachment
+	})
+	if !ok {
+		return nil
+	}
+	return dbgNode(md.MDAttachments())
+}
+
+// dbgNode returns the node of the !dbg metadata attachment among the given
+// metadata attachments, or nil if not present.
+func dbgNode(mds []*metadata.Attachment) metadata.MDNode {
+	for _, md := range mds {
+		if md.Name == "dbg" {
+			return md.Node
+		}
+	}
+	return nil
+}
+
+// debugLoc returns the line and column of the given !dbg metadata node; e.g.
+//
+//    !DILocation(line: 12, column: 5, scope: !7) => 12, 5
+//    !DISubprogram(name: "f", line: 12, ...)     => 12, 0
+//
+// The boolean return value indicates success. A column of 0 indicates an
+// unknown column.
+func debugLoc(node metadata.MDNode) (line, col int64, ok bool) {
+	switch node := node.(type) {
+	case *metadata.DILocation:
+		return node.Line, node.Column, node.Line != 0
+	case *metadata.DISubprogram:
+		return node.Line, 0, node.Line != 0
+	default:
+		return 0, 0, false
+	}
+}
+
+// attrDocs maps from LLVM IR function attribute to the documentation of the
+// semantic hint it provides, as emitted by funcDoc.
+var attrDocs = map[string]string{
+	"alwaysinline": "should always be inlined",
+	"argmemonly":   "only accesses memory through its pointer arguments",
+	"cold":         "is rarely called",
+	"noinline":     "should never be inlined",
+	"noreturn":     "does not return",
+	"nounwind":     "does not unwind the stack (e.g. by panicking)",
+	"readnone":     "has no side effects and does not read memory",
+	"readonly":     "has no side effects",
+	"writeonly":    "does not read memory",
+}
+
+// funcDoc returns the doc comment of the Go function with the given name,
+// documenting the mangled C++ name of the given LLVM IR function if demangled,
+// the semantic hints of its function attributes, and its source location as
+// specified by its !dbg metadata attachment if line comments are enabled; e.g.
+//
+//    // f has the mangled C++ name _Z1fv.
+//    // f does not return.
+//    // f has no side effects.
+//    // f uses the "shadow-stack" garbage collection strategy.
+//    //
+//    // line 12
+//
+// The garbage collection strategy of the function, if any, is documented only,
+// as Go is garbage collected. A nil comment group is returned if there is
+// nothing to document.
+func (d *Decompiler) funcDoc(f *ir.Func, name string) *ast.CommentGroup {
+	var list []*ast.Comment
+	if c := d.mangledDoc(f.Name(), name); c != nil {
+		list = append(list, c)
+	}
+	seen := make(map[string]bool)
+	for _, attr := range f.FuncAttrs {
+		s := fmt.Sprint(attr)
+		doc, ok := attrDocs[s]
+		if !ok || seen[s] {
+			continue
+		}
+		seen[s] = true
+		list = append(list, &ast.Comment{Text: fmt.Sprintf("// %s %s.", name, doc)})
+	}
+	if len(f.GC) > 0 {
+		list = append(list, &ast.Comment{Text: fmt.Sprintf("// %s uses the %q garbage collection strategy.", name, f.GC)})
+	}
+	if d.LineComments {
+		if line, _, ok := debugLoc(dbgNode(f.Metadata)); ok {
+			if len(list) > 0 {
+				list = append(list, &ast.Comment{Text: "//"})
+			}
+			list = append(list, &ast.Comment{Text: fmt.Sprintf("// line %d", line)})
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return &ast.CommentGroup{List: list}
+}
+
+// mangledDoc returns the comment documenting the mangled C++ name of the given
+// global name, with the given Go identifier, if demangled; or nil otherwise.
+func (d *Decompiler) mangledDoc(name, ident string) *ast.Comment {
+	if !d.Demangle {
+		return nil
+	}
+	if _, ok := demangle(name); !ok {
+		return nil
+	}
+	return &ast.Comment{Text: fmt.Sprintf("// %s has the mangled C++ name %s.", ident, name)}
+}
@@ -0,0 +1,112 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestLineComments(t *testing.T) {
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	sum := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+	sum.Metadata = []*metadata.Attachment{
+		{Name: "dbg", Node: &metadata.DILocation{MetadataID: 12, Line: 3, Column: 11}},
+	}
+	entry.NewMul(sum, constant.NewInt(types.I32, 2))
+	ret := entry.NewRet(sum)
+	ret.Metadata = []*metadata.Attachment{
+		{Name: "dbg", Node: &metadata.DILocation{MetadataID: 13, Line: 4}},
+	}
+	golden := []struct {
+		lineComments bool
+		want         string
+	}{
+		{
+			lineComments: false,
+			want:         "func f(x int32) int32 {\n\t_0 := x + 1\n\t_1 := _0 * 2\n\treturn _0\n}",
+		},
+		{
+			lineComments: true,
+			want:         "func f(x int32) int32 {\n\t// line 3:11\n\t_0 := x + 1\n\t_1 := _0 * 2\n\t// line 4\n\treturn _0\n}",
+		},
+	}
+	for _, g := range golden {
+		d := withComments(NewDecompiler())
+		d.LineComments = g.lineComments
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Fatalf("unable to decompile function; %v", err)
+		}
+		if got := commentString(t, d, fn); got != g.want {
+			t.Errorf("function mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
+func TestFuncDoc(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("abort", types.Void)
+	f.FuncAttrs = []ir.FuncAttribute{enum.FuncAttrNoReturn, enum.FuncAttrNoUnwind, enum.FuncAttrOptSize, enum.FuncAttrNoReturn}
+	f.Metadata = []*metadata.Attachment{
+		{Name: "dbg", Node: &metadata.DISubprogram{MetadataID: 7, Name: "abort", Line: 21}},
+	}
+	f.NewBlock("entry").NewUnreachable()
+	h := m.NewFunc("h", types.Void)
+	h.NewBlock("entry").NewRet(nil)
+	g := m.NewFunc("g", types.Void)
+	g.GC = "shadow-stack"
+	g.NewBlock("entry").NewRet(nil)
+	golden := []struct {
+		f            *ir.Func
+		lineComments bool
+		want         []string
+	}{
+		{
+			f:    f,
+			want: []string{"// abort does not return.", "// abort does not unwind the stack (e.g. by panicking)."},
+		},
+		{
+			f:            f,
+			lineComments: true,
+			want:         []string{"// abort does not return.", "// abort does not unwind the stack (e.g. by panicking).", "//", "// line 21"},
+		},
+		// Function without attributes.
+		{f: h, lineComments: true},
+		// Function with a garbage collection strategy.
+		{
+			f:    g,
+			want: []string{`// g uses the "shadow-stack" garbage collection strategy.`},
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.LineComments = g.lineComments
+		fn, err := d.FuncDecl(g.f, nil)
+		if err != nil {
+			t.Fatalf("%q: unable to decompile function; %v", g.f.Name(), err)
+		}
+		var got []string
+		if fn.Doc != nil {
+			for _, c := range fn.Doc.List {
+				got = append(got, c.Text)
+			}
+		}
+		if len(got) != len(g.want) {
+			t.Errorf("%q: doc comment mismatch; expected %q, got %q", g.f.Name(), g.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != g.want[i] {
+				t.Errorf("%q: doc comment mismatch; expected %q, got %q", g.f.Name(), g.want, got)
+				break
+			}
+		}
+	}
+}
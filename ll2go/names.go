@@ -0,0 +1,125 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/token"
+	gotypes "go/types"
+	"strings"
+)
+
+// A SymbolMap maps LLVM IR names to Go identifiers, which take precedence over
+// the Go identifiers derived from the LLVM IR names; e.g. to rename the
+// function @func_1234 to ParseHeader.
+//
+// Global names are module-scoped, and local names are function-scoped. LLVM IR
+// names are specified without sigil (e.g. "func_1234" for @func_1234 and "3"
+// for %3).
+type SymbolMap struct {
+	// Go identifiers of global names; mapping from LLVM IR global name to Go
+	// identifier.
+	Globals map[string]string `json:"globals"`
+	// Go identifiers of local names of each function; mapping from LLVM IR
+	// function name to LLVM IR local name to Go identifier.
+	Locals map[string]map[string]string `json:"locals"`
+}
+
+// A nameAllocator allocates unique Go identifiers for the local names of a
+// function, and remembers the allocated identifiers so that repeated
+// references to a local name resolve to the same Go identifier.
+//
+// Each local name is given a distinct Go identifier, as local names are SSA
+// values. The Go identifier is based on the local name without numeric suffix,
+// and local names which map to the same Go identifier (e.g. "i.0" and "i.1", or
+// "a.b" and "a_b") are disambiguated by a numeric suffix (e.g. "i" and "i_1").
+// Go keywords and predeclared identifiers are prefixed with an underscore (e.g.
+// "_range").
+type nameAllocator struct {
+	// Go identifiers of local names; mapping from LLVM IR local name to Go
+	// identifier.
+	idents map[string]string
+	// Allocated Go identifiers.
+	taken map[string]bool
+	// Preferred Go identifiers of local names, as specified by a symbol map;
+	// mapping from LLVM IR local name to Go identifier, or nil if none.
+	renames map[string]string
+}
+
+// newNameAllocator returns a new name allocator.
+func newNameAllocator() *nameAllocator {
+	return &nameAllocator{
+		idents: make(map[string]string),
+		taken:  make(map[string]bool),
+	}
+}
+
+// ident returns the Go identifier of the given local name, allocating a unique
+// Go identifier on first reference.
+func (a *nameAllocator) ident(name string) string {
+	if ident, ok := a.idents[name]; ok {
+		return ident
+	}
+	base := Sanitize(varName(name))
+	if rename, ok := a.renames[name]; ok {
+		base = Sanitize(rename)
+	}
+	base = unreserved(base)
+	ident := base
+	for i := 1; a.taken[ident]; i++ {
+		ident = fmt.Sprintf("%s_%d", base, i)
+	}
+	a.idents[name] = ident
+	a.taken[ident] = true
+	return ident
+}
+
+// varName returns the source variable name of the given local name, by dropping
+// any numeric suffixes; e.g. "i" for "i.0" and "x.addr" for "x.addr.1".
+func varName(name string) string {
+	for {
+		pos := strings.LastIndex(name, ".")
+		if pos <= 0 || !isDigits(name[pos+1:]) {
+			return name
+		}
+		name = name[:pos]
+	}
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isReserved reports whether the given identifier is reserved, and may thus
+// not be used as the name of a Go variable, function or type; i.e. Go keywords,
+// predeclared identifiers (e.g. int32 and len) and the names of packages
+// imported by the decompiled Go source code.
+func isReserved(ident string) bool {
+	if token.Lookup(ident).IsKeyword() {
+		return true
+	}
+	if gotypes.Universe.Lookup(ident) != nil {
+		return true
+	}
+	switch ident {
+	case "atomic", "bits", "math", "unsafe":
+		return true
+	}
+	return false
+}
+
+// unreserved returns the given identifier, prefixed with an underscore if
+// reserved (see isReserved).
+func unreserved(ident string) string {
+	if isReserved(ident) {
+		return "_" + ident
+	}
+	return ident
+}
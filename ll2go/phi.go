@@ -0,0 +1,243 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// A phiStrategy determines how the incoming values of PHI instructions are
+// assigned to the variables of the PHI instructions.
+type phiStrategy interface {
+	// decls returns the Go statements declaring the PHI variables of the given
+	// function, which are placed at the start of the function body.
+	decls(fc *funcContext) []ast.Stmt
+	// assign records the assignment of the incoming value x to the variable of
+	// the PHI instruction, in the given predecessor basic block.
+	assign(fc *funcContext, phi *ir.InstPhi, pred *basicBlock, x value.Value) error
+}
+
+// phiStrategy returns the strategy of the decompiler for PHI instructions.
+func (d *Decompiler) phiStrategy() phiStrategy {
+	if d.NoPhiPropagation {
+		return explicitPhis{}
+	}
+	return propagatePhis{}
+}
+
+// propagatePhis declares the variables of PHI instructions at the start of the
+// function body, and assigns the incoming values of PHI instructions directly
+// after their definition in the predecessor basic block (see
+// basicBlock.addOut).
+type propagatePhis struct{}
+
+// decls returns the declarations of the PHI variables of the function.
+func (propagatePhis) decls(fc *funcContext) []ast.Stmt {
+	return phiDecls(fc)
+}
+
+// assign assigns x to the PHI variable after the definition of x in pred, or to
+// the temporary of the PHI variable at the end of pred (see edgePhis).
+func (propagatePhis) assign(fc *funcContext, phi *ir.InstPhi, pred *basicBlock, x value.Value) error {
+	expr, err := fc.Value(x)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if fc.edgePhis[phi] {
+		pred.out = append(pred.out, fc.assign(phiTemp(phi), expr))
+		return nil
+	}
+	pred.addOut(phi, x, fc.assign(phi.Name(), expr))
+	return nil
+}
+
+// explicitPhis declares the variables of PHI instructions at the start of the
+// function body, and assigns the incoming values of PHI instructions at the end
+// of the predecessor basic block, preceded by a comment naming the PHI
+// variable and the basic block of the PHI instruction.
+//
+//    // phi: x (block_loop)
+//    x = y
+type explicitPhis struct{}
+
+// decls returns the declarations of the PHI variables of the function.
+func (explicitPhis) decls(fc *funcContext) []ast.Stmt {
+	return phiDecls(fc)
+}
+
+// phiDecls returns the declarations of the PHI variables of the function, which
+// are placed at the start of the function body. Each PHI variable is declared
+// once with the Go type of its PHI instruction, as the incoming values of each
+// predecessor are assigned to the same variable; e.g. pointers and structures
+// of the same type as the PHI instruction, or nil and composite literals. The
+// temporaries of PHI variables are declared alongside (see edgePhis).
+func phiDecls(fc *funcContext) []ast.Stmt {
+	var stmts []ast.Stmt
+	for _, block := range fc.f.Blocks {
+		for _, inst := range block.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			spec := &ast.ValueSpec{
+				Names: []*ast.Ident{fc.local(phi.Name())},
+				Type:  fc.GoType(phi.Typ),
+			}
+			if fc.edgePhis[phi] {
+				spec.Names = append(spec.Names, fc.local(phiTemp(phi)))
+			}
+			decl := &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{spec}}
+			stmts = append(stmts, &ast.DeclStmt{Decl: decl})
+		}
+	}
+	return stmts
+}
+
+// assign assigns x to the PHI variable at the end of pred, or to the temporary
+// of the PHI variable (see edgePhis).
+func (explicitPhis) assign(fc *funcContext, phi *ir.InstPhi, pred *basicBlock, x value.Value) error {
+	expr, err := fc.Value(x)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	name := phi.Name()
+	if fc.edgePhis[phi] {
+		name = phiTemp(phi)
+	}
+	comment := fc.commentStmts("phi: " + fc.local(phi.Name()).Name + " (" + fc.label(fc.parents[phi].Name()).Name + ")")
+	pred.out = append(append(pred.out, comment...), fc.assign(name, expr))
+	return nil
+}
+
+// edgePhis returns the PHI instructions of the given function whose incoming
+// values are copied through temporaries; i.e. the PHI instructions whose
+// variables are used after the outgoing assignment in one of their predecessor
+// basic blocks (see phiLive).
+//
+// The outgoing assignments of PHI instructions are placed in the predecessor
+// basic block, regardless of the edge taken, and thus overwrite the PHI
+// variable before its later uses; e.g. by the terminator of the predecessor, by
+// the incoming values of other PHI instructions (as when swapping variables),
+// or by a successor basic block outside of the loop (the "lost copy" problem).
+// The incoming values of such PHI instructions are instead assigned to a
+// temporary at the end of each predecessor, and the temporary is assigned to
+// the PHI variable at the start of the basic block of the PHI instruction; i.e.
+// at the end of the edge.
+//
+//    x_phi = y    // end of predecessor
+//    ...
+//    x = x_phi    // start of basic block of PHI instruction
+func edgePhis(f *ir.Func) map[*ir.InstPhi]bool {
+	edge := make(map[*ir.InstPhi]bool)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			for _, inc := range phi.Incs {
+				if phiLive(f, phi, block, inc.Pred.(*ir.Block)) {
+					edge[phi] = true
+					break
+				}
+			}
+		}
+	}
+	return edge
+}
+
+// phiLive reports whether the variable of the given PHI instruction of the
+// given basic block is used after its outgoing assignment in the given
+// predecessor basic block; i.e. by the terminator of the predecessor, or by any
+// basic block reachable from the predecessor without passing through the basic
+// block of the PHI instruction, which redefines the PHI variable. The incoming
+// values of PHI instructions are used at the end of their predecessor basic
+// block, and thus by the outgoing assignments of other PHI instructions from
+// the predecessor (e.g. when swapping variables).
+func phiLive(f *ir.Func, phi *ir.InstPhi, block, pred *ir.Block) bool {
+	if uses(termOperands(pred.Term), phi) {
+		return true
+	}
+	// Basic blocks reachable from the predecessor.
+	reached := map[*ir.Block]bool{pred: true}
+	seen := map[*ir.Block]bool{block: true}
+	queue := append([]*ir.Block(nil), pred.Term.Succs()...)
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		reached[b] = true
+		for _, inst := range b.Insts {
+			if _, ok := inst.(*ir.InstPhi); !ok && uses(operands(inst), phi) {
+				return true
+			}
+		}
+		if uses(termOperands(b.Term), phi) {
+			return true
+		}
+		queue = append(queue, b.Term.Succs()...)
+	}
+	for _, b := range f.Blocks {
+		for _, inst := range b.Insts {
+			other, ok := inst.(*ir.InstPhi)
+			if !ok || other == phi {
+				continue
+			}
+			for _, inc := range other.Incs {
+				if inc.X == phi && reached[inc.Pred.(*ir.Block)] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// phiTemp returns the local name of the temporary of the given PHI instruction
+// (see edgePhis).
+func phiTemp(phi *ir.InstPhi) string {
+	return phi.Name() + ".phi"
+}
+
+// phiIncs returns the incoming values of the given PHI instruction, with one
+// incoming value per predecessor basic block.
+//
+// A PHI instruction has one incoming value per edge, and thus repeats the
+// incoming value of predecessors which branch to its basic block through
+// multiple edges (e.g. a switch with two cases to the same basic block). The
+// incoming values of the same predecessor are required to be identical, as the
+// PHI variable is assigned once in the predecessor, regardless of the edge
+// taken; an error is returned otherwise.
+func phiIncs(phi *ir.InstPhi) ([]*ir.Incoming, error) {
+	var incs []*ir.Incoming
+	seen := make(map[string]*ir.Incoming)
+	for _, inc := range phi.Incs {
+		prev, ok := seen[blockName(inc.Pred)]
+		if !ok {
+			seen[blockName(inc.Pred)] = inc
+			incs = append(incs, inc)
+			continue
+		}
+		if !sameValue(prev.X, inc.X) {
+			return nil, errors.Errorf("invalid PHI instruction %q; incoming values %v and %v of predecessor basic block %q differ", phi.Name(), prev.X.Ident(), inc.X.Ident(), blockName(inc.Pred))
+		}
+	}
+	return incs, nil
+}
+
+// sameValue reports whether the given LLVM IR values are identical; i.e. the
+// same value, or constants of the same type and contents.
+func sameValue(x, y value.Value) bool {
+	if x == y {
+		return true
+	}
+	_, ok := x.(constant.Constant)
+	return ok && x.Type().Equal(y.Type()) && x.Ident() == y.Ident()
+}
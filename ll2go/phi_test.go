@@ -0,0 +1,464 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"testing"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestPhiStrategy(t *testing.T) {
+	//    int f(int n) {
+	//       int i = 0;
+	//       while (i < n) {
+	//          i = g(i + 1);
+	//       }
+	//       return i;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	g := m.NewFunc("g", types.I32, x)
+	n := ir.NewParam("n", types.I32)
+	f := m.NewFunc("f", types.I32, n)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	loop.NewCondBr(loop.NewICmp(enum.IPredSLT, i, n), body, exit)
+	inc := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	next := body.NewCall(g, inc)
+	body.NewBr(loop)
+	i.Incs = append(i.Incs, ir.NewIncoming(next, body))
+	exit.NewRet(i)
+
+	prims := []*primitive.Primitive{
+		{
+			Prim: "pre_loop",
+			Node: "pre_loop_0",
+			Nodes: map[string]string{
+				"cond": "loop",
+				"body": "body",
+				"exit": "exit",
+			},
+			Entry: "loop",
+			Exit:  "exit",
+		},
+		{
+			Prim: "seq",
+			Node: "seq_0",
+			Nodes: map[string]string{
+				"entry": "entry",
+				"exit":  "pre_loop_0",
+			},
+			Entry: "entry",
+			Exit:  "pre_loop_0",
+		},
+	}
+	golden := []struct {
+		noPhiPropagation bool
+		want             string
+	}{
+		// PHI variables are declared up front, and incoming values are assigned
+		// directly after their definition.
+		{
+			noPhiPropagation: false,
+			want: `func f(n int32) int32 {
+	var i int32
+	i = 0
+	for i < n {
+		_1 := i + 1
+		_2 := g(_1)
+		i = _2
+	}
+	return i
+}`,
+		},
+		// PHI variables are declared up front, and incoming values are assigned
+		// at the end of each predecessor basic block.
+		{
+			noPhiPropagation: true,
+			want: `func f(n int32) int32 {
+	var i int32
+	// phi: i (block_loop)
+	i = 0
+	for i < n {
+		_1 := i + 1
+		_2 := g(_1)
+		// phi: i (block_loop)
+		i = _2
+	}
+	return i
+}`,
+		},
+	}
+	for _, gold := range golden {
+		d := withComments(NewDecompiler())
+		d.NoPhiPropagation = gold.noPhiPropagation
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Errorf("unable to decompile function; %v", err)
+			continue
+		}
+		got := commentString(t, d, fn)
+		if got != gold.want {
+			t.Errorf("function mismatch (no phi propagation %v); expected %q, got %q", gold.noPhiPropagation, gold.want, got)
+		}
+	}
+}
+
+func TestPhiStruct(t *testing.T) {
+	//    %struct.pair = type { i32, i32* }
+	//
+	//    define %struct.pair @f(i1 %c, %struct.pair* %p) {
+	//    entry:
+	//       br i1 %c, label %load, label %exit
+	//    load:
+	//       %x = load %struct.pair, %struct.pair* %p
+	//       br label %exit
+	//    exit:
+	//       %y = phi %struct.pair [ zeroinitializer, %entry ], [ %x, %load ]
+	//       ret %struct.pair %y
+	//    }
+	m := ir.NewModule()
+	pair := m.NewTypeDef("struct.pair", types.NewStruct(types.I32, types.NewPointer(types.I32)))
+	c := ir.NewParam("c", types.I1)
+	p := ir.NewParam("p", types.NewPointer(pair))
+	f := m.NewFunc("f", pair, c, p)
+	entry := f.NewBlock("entry")
+	load := f.NewBlock("load")
+	exit := f.NewBlock("exit")
+	entry.NewCondBr(c, load, exit)
+	x := load.NewLoad(elemType(p), p)
+	x.SetName("x")
+	load.NewBr(exit)
+	y := exit.NewPhi(ir.NewIncoming(constant.NewZeroInitializer(pair), entry), ir.NewIncoming(x, load))
+	y.SetName("y")
+	exit.NewRet(y)
+
+	golden := []struct {
+		noPhiPropagation bool
+		want             string
+	}{
+		{
+			noPhiPropagation: false,
+			want: `package main
+
+type pair struct {
+	Field0 int32
+	Field1 *int32
+}
+
+func f(c bool, p *pair) pair {
+	var y pair
+	y = pair{}
+	if c {
+		x := *p
+		y = x
+	}
+	return y
+}
+`,
+		},
+		{
+			noPhiPropagation: true,
+			want: `package main
+
+type pair struct {
+	Field0 int32
+	Field1 *int32
+}
+
+func f(c bool, p *pair) pair {
+	var y pair
+	// phi: y (block_exit)
+	y = pair{}
+	if c {
+		x := *p
+		// phi: y (block_exit)
+		y = x
+	}
+	return y
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.NoPhiPropagation = g.noPhiPropagation
+		file, err := d.Decompile(m, nil)
+		if err != nil {
+			t.Errorf("unable to decompile module (no phi propagation %v); %v", g.noPhiPropagation, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, FileSet(), file); err != nil {
+			t.Fatalf("unable to format Go source file; %v", err)
+		}
+		if got := buf.String(); got != g.want {
+			t.Errorf("Go source mismatch (no phi propagation %v); expected %q, got %q", g.noPhiPropagation, g.want, got)
+		}
+		if err := Verify("f.go", file); err != nil {
+			t.Errorf("unable to type-check Go source file (no phi propagation %v); %v", g.noPhiPropagation, err)
+		}
+	}
+}
+
+func TestPhiIncs(t *testing.T) {
+	//    int f(int x) {
+	//       int r;
+	//       switch (x) {
+	//       case 1:
+	//       case 2:
+	//          r = 10;
+	//          break;
+	//       default:
+	//          r = 20;
+	//       }
+	//       return r;
+	//    }
+	newFunc := func(y int64) *ir.Func {
+		m := ir.NewModule()
+		x := ir.NewParam("x", types.I32)
+		f := m.NewFunc("f", types.I32, x)
+		entry := f.NewBlock("entry")
+		other := f.NewBlock("other")
+		exit := f.NewBlock("exit")
+		entry.NewSwitch(x, other, ir.NewCase(constant.NewInt(types.I32, 1), exit), ir.NewCase(constant.NewInt(types.I32, 2), exit))
+		other.NewBr(exit)
+		r := exit.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 10), entry), ir.NewIncoming(constant.NewInt(types.I32, y), entry), ir.NewIncoming(constant.NewInt(types.I32, 20), other))
+		r.SetName("r")
+		exit.NewRet(r)
+		return f
+	}
+
+	// The incoming value of each edge from the switch is assigned once.
+	fn, err := NewDecompiler().FuncDecl(newFunc(10), nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := "func f(x int32) int32 {\n\tvar r int32\n\tr = 10\n\tswitch x {\n\tcase 1, 2:\n\t\tgoto block_exit\n\tdefault:\n\t\tr = 20\n\t\tgoto block_exit\n\t}\nblock_exit:\n\treturn r\n}"
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+
+	// Differing incoming values of the same predecessor are invalid.
+	_, err = NewDecompiler().FuncDecl(newFunc(30), nil)
+	const wantErr = `invalid PHI instruction "r"; incoming values 10 and 30 of predecessor basic block "entry" differ`
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("error mismatch; expected %q, got %v", wantErr, err)
+	}
+}
+
+func TestPhiParallelCopies(t *testing.T) {
+	// Post-test loop of each function.
+	prims := []*primitive.Primitive{
+		{
+			Prim: "post_loop",
+			Node: "post_loop_0",
+			Nodes: map[string]string{
+				"cond": "loop",
+				"exit": "exit",
+			},
+			Entry: "loop",
+			Exit:  "exit",
+		},
+	}
+	golden := []struct {
+		newFunc func(m *ir.Module) *ir.Func
+		want    string
+	}{
+		// Lost copy; the PHI variable is used after the loop, and its value
+		// prior to the outgoing assignment is returned.
+		//
+		//    define i32 @f(i32 %n) {
+		//    entry:
+		//       br label %loop
+		//    loop:
+		//       %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+		//       %next = add i32 %i, 1
+		//       %c = icmp slt i32 %next, %n
+		//       br i1 %c, label %loop, label %exit
+		//    exit:
+		//       ret i32 %i
+		//    }
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				n := ir.NewParam("n", types.I32)
+				f := m.NewFunc("f", types.I32, n)
+				entry := f.NewBlock("entry")
+				loop := f.NewBlock("loop")
+				exit := f.NewBlock("exit")
+				entry.NewBr(loop)
+				i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+				i.SetName("i")
+				next := loop.NewAdd(i, constant.NewInt(types.I32, 1))
+				next.SetName("next")
+				c := loop.NewICmp(enum.IPredSLT, next, n)
+				c.SetName("c")
+				loop.NewCondBr(c, loop, exit)
+				i.Incs = append(i.Incs, ir.NewIncoming(next, loop))
+				exit.NewRet(i)
+				return f
+			},
+			want: `func f(n int32) int32 {
+	var i, i_phi int32
+	i_phi = 0
+	goto block_loop
+block_loop:
+	for {
+		i = i_phi
+		next := i + 1
+		c := next < n
+		i_phi = next
+		if !c {
+			break
+		}
+	}
+	return i
+}`,
+		},
+		// Swap; the incoming value of each PHI instruction is the other PHI
+		// variable.
+		//
+		//    define void @f(i32 %n) {
+		//    entry:
+		//       br label %loop
+		//    loop:
+		//       %a = phi i32 [ 1, %entry ], [ %b, %loop ]
+		//       %b = phi i32 [ 2, %entry ], [ %a, %loop ]
+		//       %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+		//       %d = sub i32 %a, %b
+		//       call void @g(i32 %d)
+		//       %next = add i32 %i, 1
+		//       %c = icmp slt i32 %next, %n
+		//       br i1 %c, label %loop, label %exit
+		//    exit:
+		//       ret void
+		//    }
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				g := m.NewFunc("g", types.Void, ir.NewParam("x", types.I32))
+				n := ir.NewParam("n", types.I32)
+				f := m.NewFunc("f", types.Void, n)
+				entry := f.NewBlock("entry")
+				loop := f.NewBlock("loop")
+				exit := f.NewBlock("exit")
+				entry.NewBr(loop)
+				a := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 1), entry))
+				a.SetName("a")
+				b := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 2), entry))
+				b.SetName("b")
+				i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+				i.SetName("i")
+				d := loop.NewSub(a, b)
+				d.SetName("d")
+				loop.NewCall(g, d)
+				next := loop.NewAdd(i, constant.NewInt(types.I32, 1))
+				next.SetName("next")
+				c := loop.NewICmp(enum.IPredSLT, next, n)
+				c.SetName("c")
+				loop.NewCondBr(c, loop, exit)
+				a.Incs = append(a.Incs, ir.NewIncoming(b, loop))
+				b.Incs = append(b.Incs, ir.NewIncoming(a, loop))
+				i.Incs = append(i.Incs, ir.NewIncoming(next, loop))
+				exit.NewRet(nil)
+				return f
+			},
+			want: `func f(n int32) {
+	var a, a_phi int32
+	var b, b_phi int32
+	var i int32
+	a_phi = 1
+	b_phi = 2
+	i = 0
+	goto block_loop
+block_loop:
+	for {
+		a = a_phi
+		b = b_phi
+		d := a - b
+		g(d)
+		next := i + 1
+		i = next
+		c := next < n
+		a_phi = b
+		b_phi = a
+		if !c {
+			break
+		}
+	}
+	return
+}`,
+		},
+		// The terminator uses the PHI variable, prior to the outgoing
+		// assignment.
+		//
+		//    define void @f(i32 %n) {
+		//    entry:
+		//       br label %loop
+		//    loop:
+		//       %c = phi i1 [ true, %entry ], [ %next, %loop ]
+		//       %next = icmp sgt i32 %n, 0
+		//       br i1 %c, label %loop, label %exit
+		//    exit:
+		//       ret void
+		//    }
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				n := ir.NewParam("n", types.I32)
+				f := m.NewFunc("f", types.Void, n)
+				entry := f.NewBlock("entry")
+				loop := f.NewBlock("loop")
+				exit := f.NewBlock("exit")
+				entry.NewBr(loop)
+				c := loop.NewPhi(ir.NewIncoming(constant.True, entry))
+				c.SetName("c")
+				next := loop.NewICmp(enum.IPredSGT, n, constant.NewInt(types.I32, 0))
+				next.SetName("next")
+				loop.NewCondBr(c, loop, exit)
+				c.Incs = append(c.Incs, ir.NewIncoming(next, loop))
+				exit.NewRet(nil)
+				return f
+			},
+			want: `func f(n int32) {
+	var c, c_phi bool
+	c_phi = true
+	goto block_loop
+block_loop:
+	for {
+		c = c_phi
+		next := n > 0
+		c_phi = next
+		if !c {
+			break
+		}
+	}
+	return
+}`,
+		},
+	}
+	for _, g := range golden {
+		f := g.newFunc(ir.NewModule())
+		d := NewDecompiler()
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Errorf("unable to decompile function; %v", err)
+			continue
+		}
+		got := nodeString(t, fn)
+		if got != g.want {
+			t.Errorf("function mismatch; expected %q, got %q", g.want, got)
+			continue
+		}
+		typeCheck(t, got+"\n\nfunc g(x int32) {}")
+	}
+}
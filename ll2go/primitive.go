@@ -0,0 +1,688 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/decomp/decomp/cfa"
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// RecoverPrims recovers the high-level control flow primitives of the given
+// function. It does so by repeatedly locating and merging control flow
+// primitives of the control flow graph of the function, until the graph is
+// reduced into a single node or no further primitives may be located; the
+// returned list of primitives is ordered in the same sequence as they were
+// located.
+//
+// Conditional branches to the exit or header of a loop, from within the loop,
+// and unconditional branches to the loop exit, from basic blocks only entered
+// from within the loop, are removed from the control flow graph if no
+// primitive may be located otherwise; FuncDecl emits such branches as break
+// and continue statements.
+//
+// An incomplete list of primitives is not considered an error, as FuncDecl
+// falls back to goto statements for the remaining basic blocks.
+func RecoverPrims(f *ir.Func) ([]*primitive.Primitive, error) {
+	g, err := cfg.New(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	entry := g.NodeByLabel(f.Blocks[0].Name())
+	if entry == nil {
+		return nil, errors.Errorf("unable to locate entry node %q", f.Blocks[0].Name())
+	}
+	var prims []*primitive.Primitive
+	for len(g.Nodes()) > 1 {
+		// Locate primitive.
+		dom := cfg.NewDom(g, entry)
+		prim, err := cfa.FindPrim(g, dom)
+		if err != nil {
+			// Remove the break and continue edges of loops, which are emitted as
+			// break and continue statements, and try again.
+			if cfa.CutLoopEdges(g, dom) {
+				continue
+			}
+			var labels []string
+			for _, n := range g.Nodes() {
+				labels = append(labels, n.(*cfg.Node).Label)
+			}
+			dbg.Printf("unable to recover control flow primitives of function %q; %d nodes remain (%s) after %s; %v", f.Name(), len(labels), strings.Join(labels, ", "), primsString(prims), err)
+			break
+		}
+		prims = append(prims, prim)
+
+		// Merge the nodes of the primitive into a single node.
+		if err := cfa.Merge(g, prim); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// Handle special case where entry node has been replaced by primitive
+		// node.
+		if !g.Has(entry) {
+			entry = g.NodeByLabel(prim.Node)
+			if entry == nil {
+				return nil, errors.Errorf("unable to locate entry node %q", prim.Node)
+			}
+		}
+	}
+	return prims, nil
+}
+
+// primsString returns a description of the given control flow primitives, as
+// applied during control flow recovery; e.g.
+//
+//    2 primitives: if_0 = if(body=b, cond=a, exit=c), seq_1 = seq(entry=if_0, exit=d)
+func primsString(prims []*primitive.Primitive) string {
+	if len(prims) == 0 {
+		return "0 primitives"
+	}
+	var descs []string
+	for _, prim := range prims {
+		var nodes []string
+		for name, node := range prim.Nodes {
+			nodes = append(nodes, name+"="+node)
+		}
+		sort.Strings(nodes)
+		descs = append(descs, fmt.Sprintf("%s = %s(%s)", prim.Node, prim.Prim, strings.Join(nodes, ", ")))
+	}
+	if len(prims) == 1 {
+		return "1 primitive: " + descs[0]
+	}
+	return fmt.Sprintf("%d primitives: %s", len(prims), strings.Join(descs, ", "))
+}
+
+// loopContext identifies the innermost recovered loop enclosing a control flow
+// primitive, by the names of the original entry basic blocks of the loop header
+// and the loop exit.
+//
+// Branches to the header or exit of the loop, from within the loop, are emitted
+// as continue and break statements respectively (see branchStmt).
+type loopContext struct {
+	// Loop header; i.e. the cond basic block of the loop primitive.
+	header string
+	// Loop exit; i.e. the exit basic block of the loop primitive.
+	exit string
+}
+
+// enclosingLoops returns the innermost loop enclosing each of the given control
+// flow primitives, as recovered by the pre-test and post-test loop primitives
+// following them; the loop of a primitive is nil if it is outside of recovered
+// loops.
+//
+// The exit of a loop primitive, and the primitives merged into it, are outside
+// of the loop.
+func enclosingLoops(prims []*primitive.Primitive) []*loopContext {
+	// Locate the enclosing primitive of each primitive, by the index of the
+	// primitive and the name of the node within the enclosing primitive. The
+	// node names of merged primitives may be reused by later primitives, thus
+	// the primitives are tracked by index until merged.
+	parents := make([]int, len(prims))
+	roles := make([]string, len(prims))
+	pending := make(map[string]int)
+	// Loop context of each loop primitive; based on the names of the original
+	// entry basic blocks of the cond and exit nodes, as located at the time of
+	// the loop primitive.
+	own := make([]*loopContext, len(prims))
+	entries := make(map[string]string)
+	entryName := func(node string) string {
+		if orig, ok := entries[node]; ok {
+			return orig
+		}
+		return node
+	}
+	for i, prim := range prims {
+		parents[i] = -1
+		for role, node := range prim.Nodes {
+			if child, ok := pending[node]; ok {
+				parents[child], roles[child] = i, role
+				delete(pending, node)
+			}
+		}
+		if prim.Prim == "pre_loop" || prim.Prim == "post_loop" {
+			own[i] = &loopContext{
+				header: entryName(prim.Nodes["cond"]),
+				exit:   entryName(prim.Nodes["exit"]),
+			}
+		}
+		pending[prim.Node] = i
+		entries[prim.Node] = entryName(prim.Entry)
+	}
+
+	// The enclosing primitives follow the primitives they enclose.
+	loops := make([]*loopContext, len(prims))
+	for i := len(prims) - 1; i >= 0; i-- {
+		parent := parents[i]
+		switch {
+		case parent == -1:
+			// nothing to do.
+		case own[parent] != nil && roles[i] != "exit":
+			loops[i] = own[parent]
+		default:
+			loops[i] = loops[parent]
+		}
+	}
+	return loops
+}
+
+// prim merges the basic blocks of the given high-level control flow primitive
+// into a single basic block. The loop context identifies the innermost
+// recovered loop enclosing the primitive, and is nil for primitives outside of
+// loops.
+func (fc *funcContext) prim(prim *primitive.Primitive, loop *loopContext) (*basicBlock, error) {
+	switch prim.Prim {
+	case "if":
+		return fc.primIf(prim, loop)
+	case "if_else":
+		return fc.primIfElse(prim, loop)
+	case "if_return":
+		return fc.primIfReturn(prim, loop)
+	case "pre_loop":
+		return fc.primPreLoop(prim)
+	case "post_loop":
+		return fc.primPostLoop(prim)
+	case "seq":
+		return fc.primSeq(prim, loop)
+	default:
+		return nil, errors.Errorf("support for control flow primitive %q not yet implemented", prim.Prim)
+	}
+}
+
+// primIf merges the basic blocks of the given 1-way conditional primitive into
+// a single basic block.
+//
+// Pseudo-code:
+//
+//    if (A) {
+//       B
+//    }
+//    C
+func (fc *funcContext) primIf(prim *primitive.Primitive, loop *loopContext) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "cond", "body", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, body, exit := nodes[0], nodes[1], nodes[2]
+	term, err := condBr(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Create if statement; the condition is negated if the body is entered
+	// through the false branch.
+	expr, err := fc.Value(term.Cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if blockName(term.TargetTrue) != fc.entryName(body) {
+		expr = not(expr)
+	}
+	stmts, err := fc.stmts(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	bodyStmts, err := fc.seqStmts(body, exit, loop)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ifStmt := &ast.IfStmt{
+		Cond: expr,
+		Body: &ast.BlockStmt{List: bodyStmts},
+	}
+	stmts = append(stmts, ifStmt)
+	return fc.mergeExit(prim, stmts, exit)
+}
+
+// primIfElse merges the basic blocks of the given 2-way conditional primitive
+// into a single basic block.
+//
+// Pseudo-code:
+//
+//    if (A) {
+//       B
+//    } else {
+//       C
+//    }
+//    D
+func (fc *funcContext) primIfElse(prim *primitive.Primitive, loop *loopContext) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "cond", "body_true", "body_false", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, bodyTrue, bodyFalse, exit := nodes[0], nodes[1], nodes[2], nodes[3]
+	term, err := condBr(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The true and false bodies of the primitive are not necessarily ordered
+	// as the targets of the conditional branch.
+	if blockName(term.TargetTrue) != fc.entryName(bodyTrue) {
+		bodyTrue, bodyFalse = bodyFalse, bodyTrue
+	}
+
+	// Create if-else statement; the outgoing PHI assignments of each branch
+	// are placed at the end of the respective branch body.
+	stmts, err := fc.stmts(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	expr, err := fc.Value(term.Cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	trueStmts, err := fc.seqStmts(bodyTrue, exit, loop)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	falseStmts, err := fc.seqStmts(bodyFalse, exit, loop)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ifStmt := &ast.IfStmt{
+		Cond: expr,
+		Body: &ast.BlockStmt{List: trueStmts},
+		Else: &ast.BlockStmt{List: falseStmts},
+	}
+	stmts = append(stmts, ifStmt)
+	return fc.mergeExit(prim, stmts, exit)
+}
+
+// primIfReturn merges the basic blocks of the given 1-way conditional primitive
+// with a body return statement into a single basic block.
+//
+// Pseudo-code:
+//
+//    if (A) {
+//       B
+//       return
+//    }
+//    C
+//
+// Within loops, the body may instead end with a break or continue statement, as
+// the body of a break edge removed during control flow recovery (see
+// RecoverPrims).
+func (fc *funcContext) primIfReturn(prim *primitive.Primitive, loop *loopContext) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "cond", "body", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, body, exit := nodes[0], nodes[1], nodes[2]
+	term, err := condBr(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Create if statement; the body ends with the return statement of its
+	// terminator, and the exit follows the if statement.
+	expr, err := fc.Value(term.Cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if blockName(term.TargetTrue) != fc.entryName(body) {
+		expr = not(expr)
+	}
+	stmts, err := fc.stmts(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	bodyStmts, err := fc.stmts(body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var termStmts []ast.Stmt
+	if br, ok := body.Term.(*ir.TermBr); ok {
+		termStmts = append(fc.instComments(br), fc.branchStmt(blockName(br.Target), loop))
+	} else {
+		termStmts, err = fc.term(body.Term)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	ifStmt := &ast.IfStmt{
+		Cond: expr,
+		Body: &ast.BlockStmt{List: append(bodyStmts, termStmts...)},
+	}
+	stmts = append(stmts, ifStmt)
+	return fc.mergeExit(prim, stmts, exit)
+}
+
+// primPreLoop merges the basic blocks of the given pre-test loop primitive into
+// a single basic block.
+//
+// Pseudo-code:
+//
+//    while (A) {
+//       B
+//    }
+//    C
+func (fc *funcContext) primPreLoop(prim *primitive.Primitive) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "cond", "body", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, body, exit := nodes[0], nodes[1], nodes[2]
+	term, err := condBr(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Create for statement; the loop continues while the body is entered, and
+	// the outgoing PHI assignments of the body are placed at the end of the
+	// loop body.
+	negate := blockName(term.TargetTrue) != fc.entryName(body)
+	head, err := fc.stmts(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	loop := &loopContext{header: fc.entryName(cond), exit: fc.entryName(exit)}
+	bodyStmts, err := fc.seqStmts(body, cond, loop)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stmt, err := fc.loopStmt(head, term.Cond, negate, bodyStmts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := fc.recordRangeLoop(stmt, cond, body, term); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fc.mergeExit(prim, []ast.Stmt{stmt}, exit)
+}
+
+// primPostLoop merges the basic blocks of the given post-test loop primitive
+// into a single basic block.
+//
+// Pseudo-code:
+//
+//    do {
+//    } while (A)
+//    B
+func (fc *funcContext) primPostLoop(prim *primitive.Primitive) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "cond", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, exit := nodes[0], nodes[1]
+	term, err := condBr(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Create for statement; Go has no do-while loops, thus the loop condition
+	// is evaluated at the end of each iteration, and the loop continues while
+	// the cond basic block is re-entered.
+	negate := blockName(term.TargetTrue) != fc.entryName(cond)
+	head, err := fc.stmts(cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stmt, err := fc.loopStmt(head, term.Cond, negate, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fc.mergeExit(prim, []ast.Stmt{stmt}, exit)
+}
+
+// primSeq merges the basic blocks of the given sequence primitive into a single
+// basic block.
+//
+// Pseudo-code:
+//
+//    A
+//    B
+func (fc *funcContext) primSeq(prim *primitive.Primitive, loop *loopContext) (*basicBlock, error) {
+	// Locate basic blocks.
+	nodes, err := fc.primNodes(prim, "entry", "exit")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	entry, exit := nodes[0], nodes[1]
+
+	// The terminator of the entry basic block is an unconditional branch to the
+	// exit basic block, and is thus omitted.
+	stmts, err := fc.seqStmts(entry, exit, loop)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fc.mergeExit(prim, stmts, exit)
+}
+
+// seqStmts returns the Go statements of the given basic block, which continues
+// to the succ basic block within the given loop context.
+//
+// A conditional branch of the basic block, with one target other than succ, is
+// the branch of a loop edge removed during control flow recovery (see
+// RecoverPrims); the branch to the other target is emitted as a break or
+// continue statement of the enclosing loop (see branchStmt).
+func (fc *funcContext) seqStmts(block, succ *basicBlock, loop *loopContext) ([]ast.Stmt, error) {
+	stmts, err := fc.stmts(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	term, ok := block.Term.(*ir.TermCondBr)
+	if !ok {
+		return stmts, nil
+	}
+	next := fc.entryName(succ)
+	var negate bool
+	var target string
+	switch {
+	case blockName(term.TargetTrue) == next && blockName(term.TargetFalse) != next:
+		negate, target = true, blockName(term.TargetFalse)
+	case blockName(term.TargetFalse) == next && blockName(term.TargetTrue) != next:
+		target = blockName(term.TargetTrue)
+	default:
+		return stmts, nil
+	}
+	cond, err := fc.Value(term.Cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if negate {
+		cond = not(cond)
+	}
+	ifStmt := &ast.IfStmt{
+		Cond: cond,
+		Body: &ast.BlockStmt{List: []ast.Stmt{fc.branchStmt(target, loop)}},
+	}
+	return append(stmts, ifStmt), nil
+}
+
+// branchStmt returns a branch statement to the basic block with the given name,
+// from within the given loop context; i.e. a continue statement for branches to
+// the loop header, a break statement for branches to the loop exit, and a goto
+// statement otherwise.
+func (fc *funcContext) branchStmt(name string, loop *loopContext) ast.Stmt {
+	if loop != nil {
+		switch name {
+		case loop.header:
+			return &ast.BranchStmt{Tok: token.CONTINUE}
+		case loop.exit:
+			return &ast.BranchStmt{Tok: token.BREAK}
+		}
+	}
+	return fc.gotoStmt(name)
+}
+
+// condBr returns the conditional branch terminator of the given cond basic
+// block.
+func condBr(cond *basicBlock) (*ir.TermCondBr, error) {
+	term, ok := cond.Term.(*ir.TermCondBr)
+	if !ok {
+		return nil, errors.Errorf("invalid terminator type of cond basic block %q; expected *ir.TermCondBr, got %T", cond.Name(), cond.Term)
+	}
+	return term, nil
+}
+
+// loopStmt returns a Go for statement which repeatedly executes the given head
+// statements, evaluates the loop condition and, while the loop condition holds,
+// executes the given body statements. The loop condition is the given branch
+// condition, or its negation if negate is set.
+//
+// The loop condition is moved into the for clause if the head statements are
+// empty, or consist solely of the definition of a single-use branch condition;
+// otherwise the loop is exited using an explicit break statement.
+//
+//    for cond {
+//       B
+//    }
+//
+//    for {
+//       A
+//       if !cond {
+//          break
+//       }
+//       B
+//    }
+func (fc *funcContext) loopStmt(head []ast.Stmt, branchCond value.Value, negate bool, body []ast.Stmt) (*ast.ForStmt, error) {
+	cond, err := fc.Value(branchCond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(head) == 1 {
+		if def, ok := fc.condDef(head[0], branchCond); ok {
+			cond = def
+			head = nil
+		}
+	}
+	if negate {
+		cond = not(cond)
+	}
+	if len(head) == 0 {
+		return &ast.ForStmt{
+			Cond: cond,
+			Body: &ast.BlockStmt{List: body},
+		}, nil
+	}
+	brk := &ast.IfStmt{
+		Cond: not(cond),
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.BranchStmt{Tok: token.BREAK}}},
+	}
+	var stmts []ast.Stmt
+	stmts = append(stmts, head...)
+	stmts = append(stmts, brk)
+	stmts = append(stmts, body...)
+	return &ast.ForStmt{
+		Body: &ast.BlockStmt{List: stmts},
+	}, nil
+}
+
+// condDef returns the defining expression of the given branch condition, if
+// the given statement is the definition of the branch condition and the branch
+// condition has no other uses than the branch. The boolean return value
+// indicates success.
+func (fc *funcContext) condDef(stmt ast.Stmt, branchCond value.Value) (ast.Expr, bool) {
+	if _, ok := branchCond.(ir.Instruction); !ok {
+		return nil, false
+	}
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 {
+		return nil, false
+	}
+	lhs, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || lhs.Name != fc.local(branchCond.(value.Named).Name()).Name {
+		return nil, false
+	}
+	if fc.numUses(branchCond) != 1 {
+		return nil, false
+	}
+	return assign.Rhs[0], true
+}
+
+// numUses returns the number of uses of the given value in the function.
+//
+// The uses of all values are counted once per function, on first use.
+func (fc *funcContext) numUses(v value.Value) int {
+	if fc.uses == nil {
+		fc.uses = make(map[value.Value]int)
+		for _, block := range fc.f.Blocks {
+			for _, inst := range block.Insts {
+				for _, op := range operands(inst) {
+					fc.uses[op]++
+				}
+			}
+			for _, op := range termOperands(block.Term) {
+				fc.uses[op]++
+			}
+		}
+	}
+	return fc.uses[v]
+}
+
+// countUses returns the number of occurrences of the given value in ops.
+func countUses(ops []value.Value, v value.Value) int {
+	n := 0
+	for _, op := range ops {
+		if op == v {
+			n++
+		}
+	}
+	return n
+}
+
+// primNodes returns the basic blocks of the given primitive, corresponding to
+// the specified primitive node names.
+func (fc *funcContext) primNodes(prim *primitive.Primitive, names ...string) ([]*basicBlock, error) {
+	var blocks []*basicBlock
+	for _, name := range names {
+		blockName, ok := prim.Nodes[name]
+		if !ok {
+			return nil, errors.Errorf("unable to locate %q node of %q primitive", name, prim.Prim)
+		}
+		block, ok := fc.blocks[blockName]
+		if !ok {
+			return nil, errors.Errorf("unable to locate basic block %q", blockName)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// entryName returns the name of the original entry basic block of the given
+// basic block, which may have been merged from a control flow primitive.
+func (fc *funcContext) entryName(block *basicBlock) string {
+	if orig, ok := fc.entries[block.Name()]; ok {
+		return orig
+	}
+	return block.Name()
+}
+
+// not returns the logical negation of the given boolean expression.
+func not(expr ast.Expr) ast.Expr {
+	if _, ok := expr.(*ast.Ident); !ok {
+		expr = &ast.ParenExpr{X: expr}
+	}
+	return &ast.UnaryExpr{Op: token.NOT, X: expr}
+}
+
+// mergeExit returns the merged basic block of the given primitive, containing
+// the given Go statements followed by the statements of the exit basic block.
+//
+// The merged basic block inherits the terminator and outgoing PHI assignments
+// of the exit basic block, as the exit is the only basic block of the
+// primitive with successors outside of the primitive.
+func (fc *funcContext) mergeExit(prim *primitive.Primitive, stmts []ast.Stmt, exit *basicBlock) (*basicBlock, error) {
+	exitStmts, err := fc.body(exit)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block := &basicBlock{
+		Block: &ir.Block{LocalIdent: ir.LocalIdent{LocalName: prim.Node}, Term: exit.Term},
+		out:   exit.out,
+	}
+	block.stmts = append(block.stmts, stmts...)
+	block.stmts = append(block.stmts, exitStmts...)
+	return block, nil
+}
@@ -0,0 +1,661 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestPrimIfElsePhi(t *testing.T) {
+	// The outgoing PHI assignments of the merged branches are carried into the
+	// respective branch bodies.
+	//
+	//    int f(int x) {
+	//       int y;
+	//       if (x < 10) {
+	//          y = x + 1;
+	//       } else {
+	//          y = x - 1;
+	//       }
+	//       return y;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	bodyTrue := f.NewBlock("body_true")
+	bodyFalse := f.NewBlock("body_false")
+	exit := f.NewBlock("exit")
+	cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 10))
+	entry.NewCondBr(cond, bodyTrue, bodyFalse)
+	sum := bodyTrue.NewAdd(x, constant.NewInt(types.I32, 1))
+	bodyTrue.NewBr(exit)
+	diff := bodyFalse.NewSub(x, constant.NewInt(types.I32, 1))
+	bodyFalse.NewBr(exit)
+	y := exit.NewPhi(ir.NewIncoming(sum, bodyTrue), ir.NewIncoming(diff, bodyFalse))
+	y.SetName("y")
+	exit.NewRet(y)
+
+	prims := []*primitive.Primitive{
+		{
+			Prim: "if_else",
+			Node: "if_else_0",
+			Nodes: map[string]string{
+				"cond":       "entry",
+				"body_true":  "body_true",
+				"body_false": "body_false",
+				"exit":       "exit",
+			},
+			Entry: "entry",
+			Exit:  "exit",
+		},
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(x int32) int32 {
+	var y int32
+	_0 := x < 10
+	if _0 {
+		_1 := x + 1
+		y = _1
+	} else {
+		_2 := x - 1
+		y = _2
+	}
+	return y
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPrimIf(t *testing.T) {
+	//    int f(int x) {
+	//       int y = 0;
+	//       if (x < 10) {
+	//          y = x + 1;
+	//       }
+	//       return y;
+	//    }
+	golden := []struct {
+		// Enter the body through the false branch.
+		swap bool
+		want string
+	}{
+		{
+			want: "func f(x int32) int32 {\n\tvar y int32\n\t_0 := x < 10\n\ty = 0\n\tif _0 {\n\t\t_1 := x + 1\n\t\ty = _1\n\t}\n\treturn y\n}",
+		},
+		{
+			swap: true,
+			want: "func f(x int32) int32 {\n\tvar y int32\n\t_0 := x < 10\n\ty = 0\n\tif !_0 {\n\t\t_1 := x + 1\n\t\ty = _1\n\t}\n\treturn y\n}",
+		},
+	}
+	for _, g := range golden {
+		m := ir.NewModule()
+		x := ir.NewParam("x", types.I32)
+		f := m.NewFunc("f", types.I32, x)
+		entry := f.NewBlock("entry")
+		body := f.NewBlock("body")
+		exit := f.NewBlock("exit")
+		cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 10))
+		if g.swap {
+			entry.NewCondBr(cond, exit, body)
+		} else {
+			entry.NewCondBr(cond, body, exit)
+		}
+		sum := body.NewAdd(x, constant.NewInt(types.I32, 1))
+		body.NewBr(exit)
+		y := exit.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry), ir.NewIncoming(sum, body))
+		y.SetName("y")
+		exit.NewRet(y)
+
+		prims := []*primitive.Primitive{
+			{
+				Prim: "if",
+				Node: "if_0",
+				Nodes: map[string]string{
+					"cond": "entry",
+					"body": "body",
+					"exit": "exit",
+				},
+				Entry: "entry",
+				Exit:  "exit",
+			},
+		}
+		d := NewDecompiler()
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Errorf("swap=%v: unable to decompile function; %v", g.swap, err)
+			continue
+		}
+		if got := nodeString(t, fn); got != g.want {
+			t.Errorf("swap=%v: function mismatch; expected %q, got %q", g.swap, g.want, got)
+		}
+	}
+}
+
+func TestPrimIfElseArms(t *testing.T) {
+	// The arms of the if-else statement follow the targets of the conditional
+	// branch, regardless of the order of the body nodes of the primitive; and
+	// the PHI variable at the merge point is assigned in both arms.
+	//
+	//    int f(int x) {
+	//       int y;
+	//       if (x < 10) {
+	//          y = 1;
+	//       } else {
+	//          y = 2;
+	//       }
+	//       return y;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	small := f.NewBlock("small")
+	large := f.NewBlock("large")
+	exit := f.NewBlock("exit")
+	cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 10))
+	entry.NewCondBr(cond, small, large)
+	small.NewBr(exit)
+	large.NewBr(exit)
+	y := exit.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 1), small), ir.NewIncoming(constant.NewInt(types.I32, 2), large))
+	y.SetName("y")
+	exit.NewRet(y)
+
+	want := `func f(x int32) int32 {
+	var y int32
+	_0 := x < 10
+	if _0 {
+		y = 1
+	} else {
+		y = 2
+	}
+	return y
+}`
+	swapped := []*primitive.Primitive{
+		{
+			Prim: "if_else",
+			Node: "if_else_0",
+			Nodes: map[string]string{
+				"cond":       "entry",
+				"body_true":  "large",
+				"body_false": "small",
+				"exit":       "exit",
+			},
+			Entry: "entry",
+			Exit:  "exit",
+		},
+	}
+	recovered, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	for _, prims := range [][]*primitive.Primitive{swapped, recovered} {
+		d := NewDecompiler()
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Errorf("unable to decompile function; %v", err)
+			continue
+		}
+		if got := nodeString(t, fn); got != want {
+			t.Errorf("function mismatch; expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestPrimPreLoop(t *testing.T) {
+	// The loop-carried value of the induction variable is assigned at the end
+	// of the loop body.
+	//
+	//    int f(int n) {
+	//       int i = 0;
+	//       while (i < n) {
+	//          i++;
+	//       }
+	//       return i;
+	//    }
+	m := ir.NewModule()
+	n := ir.NewParam("n", types.I32)
+	f := m.NewFunc("f", types.I32, n)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	cond := loop.NewICmp(enum.IPredSLT, i, n)
+	loop.NewCondBr(cond, body, exit)
+	inc := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	body.NewBr(loop)
+	i.Incs = append(i.Incs, ir.NewIncoming(inc, body))
+	exit.NewRet(i)
+
+	prims := []*primitive.Primitive{
+		{
+			Prim: "pre_loop",
+			Node: "pre_loop_0",
+			Nodes: map[string]string{
+				"cond": "loop",
+				"body": "body",
+				"exit": "exit",
+			},
+			Entry: "loop",
+			Exit:  "exit",
+		},
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32) int32 {
+	var i int32
+	i = 0
+	goto block_loop
+block_loop:
+	for i < n {
+		_1 := i + 1
+		i = _1
+	}
+	return i
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPrimPostLoop(t *testing.T) {
+	// The loop body is executed at least once.
+	//
+	//    void f(int n) {
+	//       int i = 0;
+	//       do {
+	//          g();
+	//          i++;
+	//       } while (i < n);
+	//    }
+	m := ir.NewModule()
+	g := m.NewFunc("g", types.Void)
+	n := ir.NewParam("n", types.I32)
+	f := m.NewFunc("f", types.Void, n)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	loop.NewCall(g)
+	inc := loop.NewAdd(i, constant.NewInt(types.I32, 1))
+	cond := loop.NewICmp(enum.IPredSLT, inc, n)
+	loop.NewCondBr(cond, loop, exit)
+	i.Incs = append(i.Incs, ir.NewIncoming(inc, loop))
+	exit.NewRet(nil)
+
+	prims := []*primitive.Primitive{
+		{
+			Prim: "post_loop",
+			Node: "post_loop_0",
+			Nodes: map[string]string{
+				"cond": "loop",
+				"exit": "exit",
+			},
+			Entry: "loop",
+			Exit:  "exit",
+		},
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32) {
+	var i int32
+	i = 0
+	goto block_loop
+block_loop:
+	for {
+		g()
+		_0 := i + 1
+		i = _0
+		_1 := _0 < n
+		if !_1 {
+			break
+		}
+	}
+	return
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPrimSeq(t *testing.T) {
+	//    int f(int x) {
+	//       int y = x + 1;
+	//       int z = y * 2;
+	//       return z - 3;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	mid := f.NewBlock("mid")
+	exit := f.NewBlock("exit")
+	y := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+	entry.NewBr(mid)
+	z := mid.NewMul(y, constant.NewInt(types.I32, 2))
+	mid.NewBr(exit)
+	w := exit.NewSub(z, constant.NewInt(types.I32, 3))
+	exit.NewRet(w)
+
+	want := `func f(x int32) int32 {
+	_0 := x + 1
+	_1 := _0 * 2
+	_2 := _1 - 3
+	return _2
+}`
+	manual := []*primitive.Primitive{
+		{
+			Prim:  "seq",
+			Node:  "seq_0",
+			Nodes: map[string]string{"entry": "entry", "exit": "mid"},
+			Entry: "entry",
+			Exit:  "mid",
+		},
+		{
+			Prim:  "seq",
+			Node:  "seq_1",
+			Nodes: map[string]string{"entry": "seq_0", "exit": "exit"},
+			Entry: "seq_0",
+			Exit:  "exit",
+		},
+	}
+	recovered, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	for _, prims := range [][]*primitive.Primitive{manual, recovered} {
+		fc := NewDecompiler().newFuncContext()
+		fn, err := fc.funcDecl(f, prims)
+		if err != nil {
+			t.Errorf("unable to decompile function; %v", err)
+			continue
+		}
+		if got := nodeString(t, fn); got != want {
+			t.Errorf("function mismatch; expected %q, got %q", want, got)
+		}
+		if n := len(fc.blocks); n != 1 {
+			t.Errorf("control flow recovery failed; expected 1 basic block, got %d", n)
+		}
+	}
+}
+
+func TestPrimIfReturn(t *testing.T) {
+	//    int f(int *p) {
+	//       if (p == NULL) {
+	//          return -1;
+	//       }
+	//       return *p;
+	//    }
+	m := ir.NewModule()
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	f := m.NewFunc("f", types.I32, p)
+	entry := f.NewBlock("entry")
+	invalid := f.NewBlock("invalid")
+	valid := f.NewBlock("valid")
+	cond := entry.NewICmp(enum.IPredEQ, p, constant.NewNull(p.Typ.(*types.PointerType)))
+	entry.NewCondBr(cond, invalid, valid)
+	invalid.NewRet(constant.NewInt(types.I32, -1))
+	x := valid.NewLoad(elemType(p), p)
+	valid.NewRet(x)
+
+	recovered, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	if len(recovered) != 1 || recovered[0].Prim != "if_return" {
+		t.Fatalf("control flow primitive mismatch; expected if_return, got %v", recovered)
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, recovered)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(p *int32) int32 {
+	_0 := p == nil
+	if _0 {
+		return -1
+	}
+	_1 := *p
+	return _1
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestPrimLoopBreak(t *testing.T) {
+	// The early exit of the loop body is emitted as a break statement.
+	//
+	//    int f(int n, int k) {
+	//       int i = 0;
+	//       while (i < n) {
+	//          if (i == k) {
+	//             break;
+	//          }
+	//          i++;
+	//       }
+	//       return i;
+	//    }
+	m := ir.NewModule()
+	n := ir.NewParam("n", types.I32)
+	k := ir.NewParam("k", types.I32)
+	f := m.NewFunc("f", types.I32, n, k)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	body := f.NewBlock("body")
+	latch := f.NewBlock("latch")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	loop.NewCondBr(loop.NewICmp(enum.IPredSLT, i, n), body, exit)
+	body.NewCondBr(body.NewICmp(enum.IPredEQ, i, k), exit, latch)
+	inc := latch.NewAdd(i, constant.NewInt(types.I32, 1))
+	latch.NewBr(loop)
+	i.Incs = append(i.Incs, ir.NewIncoming(inc, latch))
+	exit.NewRet(i)
+
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32, k int32) int32 {
+	var i int32
+	i = 0
+	for i < n {
+		_1 := i == k
+		if _1 {
+			break
+		}
+		_2 := i + 1
+		i = _2
+	}
+	return i
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPrimNestedLoopBreak(t *testing.T) {
+	// The break statement of the inner loop only exits the inner loop, and the
+	// skipped iteration of the outer loop is emitted as a continue statement.
+	//
+	//    void f(int n, int m) {
+	//       for (int i = 0; i < n; i++) {
+	//          if (g(i)) {
+	//             continue;
+	//          }
+	//          for (int j = 0; j < m; j++) {
+	//             if (g(j)) {
+	//                break;
+	//             }
+	//          }
+	//       }
+	//    }
+	mod := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	g := mod.NewFunc("g", types.I1, x)
+	n := ir.NewParam("n", types.I32)
+	m := ir.NewParam("m", types.I32)
+	f := mod.NewFunc("f", types.Void, n, m)
+	entry := f.NewBlock("entry")
+	outer := f.NewBlock("outer")
+	skip := f.NewBlock("skip")
+	pre := f.NewBlock("pre")
+	inner := f.NewBlock("inner")
+	body := f.NewBlock("body")
+	innerLatch := f.NewBlock("inner_latch")
+	outerLatch := f.NewBlock("outer_latch")
+	exit := f.NewBlock("exit")
+	entry.NewBr(outer)
+	i := outer.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	outer.NewCondBr(outer.NewICmp(enum.IPredSLT, i, n), skip, exit)
+	iInc := skip.NewAdd(i, constant.NewInt(types.I32, 1))
+	iInc.SetName("inc")
+	skip.NewCondBr(skip.NewCall(g, i), outer, pre)
+	pre.NewBr(inner)
+	j := inner.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), pre))
+	j.SetName("j")
+	inner.NewCondBr(inner.NewICmp(enum.IPredSLT, j, m), body, outerLatch)
+	body.NewCondBr(body.NewCall(g, j), outerLatch, innerLatch)
+	jInc := innerLatch.NewAdd(j, constant.NewInt(types.I32, 1))
+	innerLatch.NewBr(inner)
+	j.Incs = append(j.Incs, ir.NewIncoming(jInc, innerLatch))
+	outerLatch.NewBr(outer)
+	i.Incs = append(i.Incs, ir.NewIncoming(iInc, skip), ir.NewIncoming(iInc, outerLatch))
+	exit.NewRet(nil)
+
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32, m int32) {
+	var i int32
+	var j int32
+	i = 0
+	for i < n {
+		inc := i + 1
+		_1 := g(i)
+		i = inc
+		if _1 {
+			continue
+		}
+		j = 0
+		for j < m {
+			_3 := g(j)
+			if _3 {
+				break
+			}
+			_4 := j + 1
+			j = _4
+		}
+		i = inc
+	}
+	return
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPrimIfBreak(t *testing.T) {
+	// The body of the if statement branches to the loop exit, and is thus
+	// emitted as a break statement within the if statement, rather than being
+	// merged into the basic block following the loop.
+	//
+	//    int f(int n, int k) {
+	//       int i = 0;
+	//       while (i < n) {
+	//          if (i == k) {
+	//             g(i);
+	//             break;
+	//          }
+	//          i++;
+	//       }
+	//       return i;
+	//    }
+	mod := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	g := mod.NewFunc("g", types.Void, x)
+	n := ir.NewParam("n", types.I32)
+	k := ir.NewParam("k", types.I32)
+	f := mod.NewFunc("f", types.I32, n, k)
+	entry := f.NewBlock("entry")
+	loop := f.NewBlock("loop")
+	body := f.NewBlock("body")
+	found := f.NewBlock("found")
+	latch := f.NewBlock("latch")
+	exit := f.NewBlock("exit")
+	entry.NewBr(loop)
+	i := loop.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	loop.NewCondBr(loop.NewICmp(enum.IPredSLT, i, n), body, exit)
+	body.NewCondBr(body.NewICmp(enum.IPredEQ, i, k), found, latch)
+	found.NewCall(g, i)
+	found.NewBr(exit)
+	inc := latch.NewAdd(i, constant.NewInt(types.I32, 1))
+	latch.NewBr(loop)
+	i.Incs = append(i.Incs, ir.NewIncoming(inc, latch))
+	exit.NewRet(i)
+
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32, k int32) int32 {
+	var i int32
+	i = 0
+	for i < n {
+		_1 := i == k
+		if _1 {
+			g(i)
+			break
+		}
+		_2 := i + 1
+		i = _2
+	}
+	return i
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
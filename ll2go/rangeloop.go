@@ -0,0 +1,243 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// A rangeLoop is a pre-test loop which iterates over the indices of an array,
+// and may thus be rewritten into a for-range loop; e.g.
+//
+//    i = 0                  =>    for i := range a {
+//    for i < 10 {                    _0 := &a[i]
+//       _0 := &a[i]                  *_0 = 0
+//       *_0 = 0                   }
+//       _1 := i + 1
+//       i = _1
+//    }
+type rangeLoop struct {
+	// Induction variable; ranging from 0 to the array length.
+	phi *ir.InstPhi
+	// Increment of the induction variable.
+	inc *ir.InstAdd
+	// Go expression of the array ranged over.
+	x ast.Expr
+	// The loop has been rewritten into a for-range loop.
+	rewritten bool
+}
+
+// recordRangeLoop records the given for statement of a pre-test loop as a
+// candidate for-range loop, if the loop unambiguously iterates over the indices
+// of an array; i.e. the induction variable is a PHI instruction of the cond
+// basic block, starting at 0 and incremented by 1 while less than the array
+// length, and is only otherwise used to index into the same array within the
+// cond or body basic block.
+//
+// Candidate for-range loops are rewritten by rewriteRangeLoops, once the
+// statements preceding the loop are known.
+func (fc *funcContext) recordRangeLoop(stmt *ast.ForStmt, cond, body *basicBlock, term *ir.TermCondBr) error {
+	// The PHI variables of loops are only assigned next to their incoming
+	// values if PHI propagation is enabled; and the loop condition is only
+	// part of the for clause if the cond basic block has no other statements.
+	if !fc.RangeLoops || fc.NoPhiPropagation || stmt.Cond == nil || blockName(term.TargetTrue) != fc.entryName(body) {
+		return nil
+	}
+	header, entry := fc.entryName(cond), fc.entryName(body)
+	cmp, ok := term.Cond.(*ir.InstICmp)
+	if !ok || (cmp.Pred != enum.IPredSLT && cmp.Pred != enum.IPredULT && cmp.Pred != enum.IPredNE) {
+		return nil
+	}
+	phi, ok := cmp.X.(*ir.InstPhi)
+	if !ok || fc.parents[phi].Name() != header || len(phi.Incs) != 2 {
+		return nil
+	}
+	n, ok := cmp.Y.(*constant.Int)
+	if !ok || n.X.Sign() <= 0 || !n.X.IsInt64() {
+		return nil
+	}
+	// The induction variable starts at 0, and is incremented by 1.
+	var inc *ir.InstAdd
+	for _, in := range phi.Incs {
+		if isZero(in.X) {
+			continue
+		}
+		add, ok := in.X.(*ir.InstAdd)
+		if !ok || !((add.X == phi && isOne(add.Y)) || (add.Y == phi && isOne(add.X))) {
+			return nil
+		}
+		inc = add
+	}
+	if inc == nil || fc.numUses(inc) != 1 {
+		return nil
+	}
+	// The induction variable indexes into an array of the trip count, which is
+	// defined before the loop.
+	var src value.Value
+	geps := 0
+	for _, block := range fc.f.Blocks {
+		for _, inst := range block.Insts {
+			gep, ok := inst.(*ir.InstGetElementPtr)
+			if !ok || countUses(operands(gep), phi) == 0 {
+				continue
+			}
+			if (block.Name() != header && block.Name() != entry) || countUses(operands(gep), phi) != 1 {
+				return nil
+			}
+			if len(gep.Indices) < 2 || !isZero(gep.Indices[0]) || gep.Indices[1] != phi {
+				return nil
+			}
+			arr, ok := gep.ElemType.(*types.ArrayType)
+			if !ok || !n.X.IsUint64() || arr.Len != n.X.Uint64() || (src != nil && gep.Src != src) {
+				return nil
+			}
+			src = gep.Src
+			geps++
+		}
+	}
+	if src == nil || fc.numUses(phi) != geps+2 {
+		return nil
+	}
+	if inst, ok := src.(ir.Instruction); ok {
+		if parent := fc.parents[inst]; parent.Name() == header || parent.Name() == entry {
+			return nil
+		}
+	}
+	x, err := fc.Value(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Go implicitly dereferences pointers to arrays in range expressions.
+	if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		x = addr.X
+	}
+	if fc.ranges == nil {
+		fc.ranges = make(map[*ast.ForStmt]*rangeLoop)
+	}
+	fc.ranges[stmt] = &rangeLoop{phi: phi, inc: inc, x: x}
+	return nil
+}
+
+// rewriteRangeLoops rewrites the candidate for-range loops within the given
+// function body into for-range loops (see recordRangeLoop). Candidate loops are
+// only rewritten if preceded by the initial assignment of 0 to the induction
+// variable, and if the increment of the induction variable is located at the
+// top level of the loop body; otherwise the explicit loop is left as is.
+func (fc *funcContext) rewriteRangeLoops(body *ast.BlockStmt) {
+	if len(fc.ranges) == 0 {
+		return
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = fc.rangeStmts(n.List)
+		case *ast.CaseClause:
+			n.Body = fc.rangeStmts(n.Body)
+		}
+		return true
+	})
+	// The induction variables of rewritten loops are declared by the for-range
+	// loops, rather than at the start of the function body.
+	keys := make(map[string]bool)
+	for _, r := range fc.ranges {
+		if r != nil && r.rewritten {
+			keys[fc.local(r.phi.Name()).Name] = true
+		}
+	}
+	var list []ast.Stmt
+	for _, stmt := range body.List {
+		if names := declNames(stmt); len(names) == 1 && keys[names[0]] {
+			continue
+		}
+		list = append(list, stmt)
+	}
+	body.List = list
+}
+
+// rangeStmts returns the given list of statements, with the candidate for-range
+// loops rewritten into for-range loops.
+func (fc *funcContext) rangeStmts(stmts []ast.Stmt) []ast.Stmt {
+	for i := 0; i < len(stmts); i++ {
+		loop, ok := stmts[i].(*ast.ForStmt)
+		if !ok || fc.ranges[loop] == nil {
+			continue
+		}
+		r := fc.ranges[loop]
+		key, inc := fc.local(r.phi.Name()).Name, fc.local(r.inc.Name()).Name
+		// Locate the initial assignment of the induction variable, among the
+		// outgoing PHI assignments preceding the loop; skipping empty statements
+		// (e.g. the anchors of comments).
+		init := -1
+		for j := i - 1; j >= 0; j-- {
+			if _, ok := stmts[j].(*ast.EmptyStmt); ok {
+				continue
+			}
+			lhs, rhs, ok := simpleAssign(stmts[j], token.ASSIGN)
+			if !ok {
+				break
+			}
+			if lhs == key {
+				if lit, ok := rhs.(*ast.BasicLit); ok && lit.Value == "0" {
+					init = j
+				}
+				break
+			}
+		}
+		if init == -1 {
+			continue
+		}
+		// Locate the increment of the induction variable, and its assignment to
+		// the induction variable.
+		def, assign := -1, -1
+		for j, stmt := range loop.Body.List {
+			if lhs, _, ok := simpleAssign(stmt, token.DEFINE); ok && lhs == inc {
+				def = j
+			}
+			if lhs, rhs, ok := simpleAssign(stmt, token.ASSIGN); ok && lhs == key {
+				if ident, ok := rhs.(*ast.Ident); ok && ident.Name == inc {
+					assign = j
+				}
+			}
+		}
+		if def == -1 || assign == -1 {
+			continue
+		}
+		var list []ast.Stmt
+		for j, stmt := range loop.Body.List {
+			if j != def && j != assign {
+				list = append(list, stmt)
+			}
+		}
+		stmts[i] = &ast.RangeStmt{
+			Key:  ast.NewIdent(key),
+			Tok:  token.DEFINE,
+			X:    r.x,
+			Body: &ast.BlockStmt{List: list},
+		}
+		stmts = append(stmts[:init], stmts[init+1:]...)
+		r.rewritten = true
+		i--
+	}
+	return stmts
+}
+
+// simpleAssign returns the name of the identifier assigned to by the given
+// single-valued assignment statement of the given token, and the assigned
+// expression. The boolean return value indicates success.
+func simpleAssign(stmt ast.Stmt, tok token.Token) (string, ast.Expr, bool) {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || assign.Tok != tok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return "", nil, false
+	}
+	lhs, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return "", nil, false
+	}
+	return lhs.Name, assign.Rhs[0], true
+}
@@ -0,0 +1,80 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// newMemsetLoop returns a function which stores 0 to the first n elements of
+// the given global array, as by memset; e.g.
+//
+//    for (i = 0; i < n; i++) {
+//       a[i] = 0;
+//    }
+func newMemsetLoop(m *ir.Module, a *ir.Global, n int64) *ir.Func {
+	f := m.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	cond := f.NewBlock("cond")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	entry.NewBr(cond)
+	i := cond.NewPhi(ir.NewIncoming(constant.NewInt(types.I64, 0), entry))
+	i.SetName("i")
+	c := cond.NewICmp(enum.IPredSLT, i, constant.NewInt(types.I64, n))
+	cond.NewCondBr(c, body, exit)
+	p := body.NewGetElementPtr(elemType(a), a, constant.NewInt(types.I64, 0), i)
+	body.NewStore(constant.NewInt(types.I32, 0), p)
+	inc := body.NewAdd(i, constant.NewInt(types.I64, 1))
+	i.Incs = append(i.Incs, ir.NewIncoming(inc, body))
+	body.NewBr(cond)
+	exit.NewRet(nil)
+	return f
+}
+
+func TestRangeLoops(t *testing.T) {
+	golden := []struct {
+		n          int64
+		rangeLoops bool
+		want       string
+	}{
+		// Trip count matching the array length.
+		{
+			n:          10,
+			rangeLoops: true,
+			want:       "func f() {\n\tfor i := range a {\n\t\t_1 := &a[i]\n\t\t*_1 = 0\n\t}\n\treturn\n}",
+		},
+		// Range loops disabled.
+		{
+			n:    10,
+			want: "func f() {\n\tvar i int64\n\ti = 0\n\tfor i < 10 {\n\t\t_1 := &a[i]\n\t\t*_1 = 0\n\t\t_2 := i + 1\n\t\ti = _2\n\t}\n\treturn\n}",
+		},
+		// Trip count not matching the array length.
+		{
+			n:          5,
+			rangeLoops: true,
+			want:       "func f() {\n\tvar i int64\n\ti = 0\n\tfor i < 5 {\n\t\t_1 := &a[i]\n\t\t*_1 = 0\n\t\t_2 := i + 1\n\t\ti = _2\n\t}\n\treturn\n}",
+		},
+	}
+	for _, g := range golden {
+		m := ir.NewModule()
+		a := m.NewGlobalDef("a", constant.NewZeroInitializer(types.NewArray(10, types.I32)))
+		f := newMemsetLoop(m, a, g.n)
+		prims, err := RecoverPrims(f)
+		if err != nil {
+			t.Fatalf("unable to recover control flow primitives; %v", err)
+		}
+		d := NewDecompiler()
+		d.RangeLoops = g.rangeLoops
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Fatalf("unable to decompile function; %v", err)
+		}
+		if got := nodeString(t, fn); got != g.want {
+			t.Errorf("function mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// A FuncReport reports the LLVM IR constructs of a function which are not yet
+// supported by the decompiler.
+type FuncReport struct {
+	// Function name.
+	Func string `json:"func"`
+	// Unsupported LLVM IR constructs of the function, in order of first
+	// occurrence.
+	Unsupported []*Unsupported `json:"unsupported,omitempty"`
+	// Error encountered while decompiling the function, if any; e.g. an
+	// invalid control flow primitive.
+	Err string `json:"error,omitempty"`
+}
+
+// An Unsupported is an LLVM IR construct which is not yet supported by the
+// decompiler.
+type Unsupported struct {
+	// Kind of construct; e.g. "instruction", "terminator", "value", "constant
+	// expression", "type" or "zero value of type".
+	Kind string `json:"kind"`
+	// Go type of the construct; e.g. "*ir.InstFreeze".
+	Type string `json:"type"`
+	// Number of occurrences within the function.
+	Count int `json:"count"`
+}
+
+// Report reports the unsupported LLVM IR constructs of each function definition
+// of the given module, in the order of module.Funcs, without emitting Go source
+// code.
+//
+// Each function is decompiled as by Decompile, except that unsupported
+// instructions, terminators, values, constant expressions and types are
+// recorded instead of causing an error. Any other error or panic encountered
+// while decompiling a function is recorded in the report of the function.
+func (d *Decompiler) Report(module *ir.Module) []*FuncReport {
+	var reports []*FuncReport
+	for _, f := range module.Funcs {
+		// Skip function declarations.
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		reports = append(reports, d.reportFunc(f))
+	}
+	return reports
+}
+
+// reportFunc reports the unsupported LLVM IR constructs of the given function
+// definition.
+func (d *Decompiler) reportFunc(f *ir.Func) (report *FuncReport) {
+	report = &FuncReport{Func: f.Name()}
+	fd := *d
+	fd.report = report
+	if _, err := fd.decompileFunc(f, nil); err != nil {
+		report.Err = err.Error()
+	}
+	return report
+}
+
+// unsupported records the given unsupported LLVM IR construct of the specified
+// kind in the report of the function being decompiled and returns nil, or
+// returns an error if not reporting. The construct is recorded in the
+// decompilation coverage statistics as well, if recorded.
+func (d *Decompiler) unsupported(kind string, v interface{}) error {
+	typ := fmt.Sprintf("%T", v)
+	if d.Stats != nil {
+		d.Stats.addUnsupported(kind, typ)
+	}
+	if d.report == nil {
+		return errors.Errorf("support for %s %T not yet implemented", kind, v)
+	}
+	for _, u := range d.report.Unsupported {
+		if u.Kind == kind && u.Type == typ {
+			u.Count++
+			return nil
+		}
+	}
+	d.report.Unsupported = append(d.report.Unsupported, &Unsupported{Kind: kind, Type: typ, Count: 1})
+	return nil
+}
+
+// unsupportedExpr returns the placeholder Go expression of an unsupported LLVM
+// IR construct, which is recorded instead of causing an error when reporting
+// (see unsupported).
+func unsupportedExpr() ast.Expr {
+	return ast.NewIdent("_")
+}
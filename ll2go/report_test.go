@@ -0,0 +1,57 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestReport(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	fg := m.NewGlobalDef("fg", constant.NewFloat(types.Float, 0))
+	// Supported function.
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	// Function with unsupported constant expressions.
+	x := ir.NewParam("x", types.I32)
+	h := m.NewFunc("h", types.I32, x)
+	entry := h.NewBlock("entry")
+	p := constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))
+	entry.NewStore(x, p)
+	y := entry.NewLoad(elemType(p), p)
+	entry.NewStore(entry.NewAdd(x, y), p)
+	entry.NewStore(constant.NewFPTrunc(constant.NewFloat(types.Double, 1), types.Float), fg)
+	entry.NewRet(y)
+
+	d := NewDecompiler()
+	reports := d.Report(m)
+	if len(reports) != 2 {
+		t.Fatalf("number of function reports mismatch; expected 2, got %d", len(reports))
+	}
+	if r := reports[0]; r.Func != "f" || len(r.Unsupported) != 0 || len(r.Err) != 0 {
+		t.Errorf("%q: unexpected report of supported function; unsupported %v, error %q", r.Func, r.Unsupported, r.Err)
+	}
+	r := reports[1]
+	want := []Unsupported{
+		{Kind: "constant expression", Type: "*constant.ExprAddrSpaceCast", Count: 3},
+		{Kind: "constant expression", Type: "*constant.ExprFPTrunc", Count: 1},
+	}
+	if len(r.Unsupported) != len(want) {
+		t.Fatalf("%q: number of unsupported constructs mismatch; expected %d, got %d", r.Func, len(want), len(r.Unsupported))
+	}
+	for i, u := range r.Unsupported {
+		if *u != want[i] {
+			t.Errorf("%q: unsupported construct mismatch; expected %v, got %v", r.Func, want[i], *u)
+		}
+	}
+	if len(r.Err) != 0 {
+		t.Errorf("%q: unexpected error; %v", r.Func, r.Err)
+	}
+	// Unsupported constructs cause an error, unless reporting.
+	if _, err := d.FuncDecl(h, nil); err == nil {
+		t.Errorf("%q: expected error for unsupported constant expression", h.Name())
+	}
+}
@@ -0,0 +1,128 @@
+package ll2go
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// mainName is the Go identifier of the LLVM IR main function of runnable
+// programs, as the Go main function takes no arguments and returns no result.
+const mainName = "_main"
+
+// findMain returns the definition of the LLVM IR main function of the given
+// module. The boolean return value indicates success.
+func findMain(module *ir.Module) (*ir.Func, bool) {
+	for _, f := range module.Funcs {
+		if f.Name() == "main" && len(f.Blocks) > 0 {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// mainFunc returns the Go main function of a runnable program, which calls the
+// given LLVM IR main function and exits with its result as status code; e.g.
+//
+//    define i32 @main(i32 %argc, i8** %argv)
+//
+// is called from
+//
+//    func main() {
+//       argv := make([]*int8, 0, len(os.Args)+1)
+//       for _, arg := range os.Args {
+//          buf := append([]byte(arg), 0)
+//          argv = append(argv, (*int8)(unsafe.Pointer(&buf[0])))
+//       }
+//       argv = append(argv, nil)
+//       os.Exit(int(_main(int32(len(os.Args)), &argv[0])))
+//    }
+//
+// The LLVM IR main function either takes no parameters or the argc and argv
+// parameters of C, and returns either an integer or void.
+func (d *Decompiler) mainFunc(f *ir.Func) (*ast.FuncDecl, error) {
+	sig := f.Sig
+	var args []string
+	var body []string
+	switch len(sig.Params) {
+	case 0:
+		// nothing to do.
+	case 2:
+		if _, ok := sig.Params[0].(*types.IntType); !ok || !isArgv(sig.Params[1]) {
+			return nil, errors.Errorf("unable to generate Go main function; unsupported parameter types %v and %v of LLVM IR main function", sig.Params[0], sig.Params[1])
+		}
+		// C strings are NUL-terminated, and argv[argc] is a null pointer.
+		var arg string
+		switch d.I8Ptr {
+		case "[]byte":
+			arg = "buf"
+		case "unsafe.Pointer":
+			arg = nodeSrc(d.unsafeSel("Pointer")) + "(&buf[0])"
+		default:
+			arg = "(*int8)(" + nodeSrc(d.unsafeSel("Pointer")) + "(&buf[0]))"
+		}
+		body = append(body,
+			"argv := make([]"+nodeSrc(d.GoType(types.NewPointer(types.I8)))+", 0, len(os.Args)+1)",
+			"for _, arg := range os.Args {",
+			"buf := append([]byte(arg), 0)",
+			"argv = append(argv, "+arg+")",
+			"}",
+			"argv = append(argv, nil)",
+		)
+		args = append(args, nodeSrc(d.GoType(sig.Params[0]))+"(len(os.Args))", "&argv[0]")
+	default:
+		return nil, errors.Errorf("unable to generate Go main function; unsupported number of parameters of LLVM IR main function; expected 0 or 2, got %d", len(sig.Params))
+	}
+	call := mainName + "(" + strings.Join(args, ", ") + ")"
+	switch sig.RetType.(type) {
+	case *types.IntType:
+		call = "os.Exit(int(" + call + "))"
+		d.pkgSel("os", "Exit")
+	case *types.VoidType:
+		// nothing to do.
+	default:
+		return nil, errors.Errorf("unable to generate Go main function; unsupported return type %v of LLVM IR main function", sig.RetType)
+	}
+	if len(args) > 0 {
+		d.pkgSel("os", "Args")
+	}
+	body = append(body, call)
+	src := "package p\n\nfunc main() {\n" + strings.Join(body, "\n") + "\n}"
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse Go main function; %v", err))
+	}
+	return file.Decls[0].(*ast.FuncDecl), nil
+}
+
+// isArgv reports whether the given type is the type of the argv parameter of C
+// main functions; i.e. i8**.
+func isArgv(t types.Type) bool {
+	ptr, ok := t.(*types.PointerType)
+	if !ok {
+		return false
+	}
+	elem, ok := ptr.ElemType.(*types.PointerType)
+	if !ok {
+		return false
+	}
+	i, ok := elem.ElemType.(*types.IntType)
+	return ok && i.BitSize == 8
+}
+
+// nodeSrc returns the Go source code of the given node, formatted as by gofmt.
+func nodeSrc(node ast.Node) string {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, token.NewFileSet(), node); err != nil {
+		panic(fmt.Sprintf("unable to format Go node; %v", err))
+	}
+	return buf.String()
+}
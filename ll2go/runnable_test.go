@@ -0,0 +1,95 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestRunnable(t *testing.T) {
+	golden := []struct {
+		newModule func() *ir.Module
+		want      string
+	}{
+		// int main(int argc, char **argv) {
+		//    return argc - 1;
+		// }
+		{
+			newModule: func() *ir.Module {
+				m := ir.NewModule()
+				argc := ir.NewParam("argc", types.I32)
+				argv := ir.NewParam("argv", types.NewPointer(types.NewPointer(types.I8)))
+				f := m.NewFunc("main", types.I32, argc, argv)
+				entry := f.NewBlock("entry")
+				entry.NewRet(entry.NewSub(argc, constant.NewInt(types.I32, 1)))
+				return m
+			},
+			want: `package main
+
+import (
+	"os"
+	"unsafe"
+)
+
+func _main(argc int32, argv **int8) int32 {
+	_0 := argc - 1
+	return _0
+}
+func main() {
+	argv := make([]*int8, 0, len(os.Args)+1)
+	for _, arg := range os.Args {
+		buf := append([]byte(arg), 0)
+		argv = append(argv, (*int8)(unsafe.Pointer(&buf[0])))
+	}
+	argv = append(argv, nil)
+	os.Exit(int(_main(int32(len(os.Args)), &argv[0])))
+}
+`,
+		},
+		// void main(void) {
+		//    return;
+		// }
+		{
+			newModule: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("main", types.Void)
+				f.NewBlock("entry").NewRet(nil)
+				return m
+			},
+			want: `package main
+
+func _main() {
+	return
+}
+func main() {
+	_main()
+}
+`,
+		},
+	}
+	for i, g := range golden {
+		d := NewDecompiler()
+		d.Runnable = true
+		file, err := d.Decompile(g.newModule(), nil)
+		if err != nil {
+			t.Errorf("i=%d: unable to decompile module; %v", i, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, FileSet(), file); err != nil {
+			t.Errorf("i=%d: unable to format Go source file; %v", i, err)
+			continue
+		}
+		if got := buf.String(); got != g.want {
+			t.Errorf("i=%d: Go source mismatch; expected %q, got %q", i, g.want, got)
+			continue
+		}
+		if err := Verify("main.go", file); err != nil {
+			t.Errorf("i=%d: unable to type-check Go source file; %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ll2go
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Stats summarizes the decompilation coverage of the functions decompiled by a
+// decompiler, as recorded when set (see Decompiler.Stats).
+//
+// Stats may be updated concurrently by the goroutines of Decompile.
+type Stats struct {
+	// Number of function definitions attempted.
+	Funcs int `json:"funcs"`
+	// Number of functions whose control flow was fully recovered.
+	Recovered int `json:"recovered"`
+	// Number of functions whose control flow was partially recovered; i.e.
+	// which fell back to goto statements for the remaining basic blocks.
+	Partial int `json:"partial"`
+	// Number of functions replaced by stubs, as they exceed the maximum
+	// function size.
+	Stubbed int `json:"stubbed"`
+	// Number of functions which failed to decompile.
+	Failed int `json:"failed"`
+	// Unsupported LLVM IR constructs of all functions, in descending order of
+	// occurrences.
+	Unsupported []*Unsupported `json:"unsupported,omitempty"`
+
+	// Protects the fields above.
+	mu sync.Mutex
+}
+
+// Outcomes of decompiling a function, as recorded by Stats.
+const (
+	funcRecovered = iota
+	funcPartial
+	funcStubbed
+	funcFailed
+)
+
+// addFunc records the outcome of decompiling a function.
+func (s *Stats) addFunc(outcome int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Funcs++
+	switch outcome {
+	case funcRecovered:
+		s.Recovered++
+	case funcPartial:
+		s.Partial++
+	case funcStubbed:
+		s.Stubbed++
+	case funcFailed:
+		s.Failed++
+	default:
+		panic(fmt.Sprintf("support for function outcome %d not yet implemented", outcome))
+	}
+}
+
+// addUnsupported records an occurrence of the unsupported LLVM IR construct of
+// the given kind and Go type.
+func (s *Stats) addUnsupported(kind, typ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.Unsupported {
+		if u.Kind == kind && u.Type == typ {
+			u.Count++
+			s.sortUnsupported()
+			return
+		}
+	}
+	s.Unsupported = append(s.Unsupported, &Unsupported{Kind: kind, Type: typ, Count: 1})
+	s.sortUnsupported()
+}
+
+// sortUnsupported sorts the unsupported LLVM IR constructs in descending order
+// of occurrences, and by kind and type otherwise, to keep the order of
+// concurrently recorded constructs deterministic.
+func (s *Stats) sortUnsupported() {
+	sort.SliceStable(s.Unsupported, func(i, j int) bool {
+		a, b := s.Unsupported[i], s.Unsupported[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Type < b.Type
+	})
+}
@@ -0,0 +1,58 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestStats(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	// Fully recovered function.
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	// Partially recovered function, as no control flow primitives are given.
+	x := ir.NewParam("x", types.I1)
+	p := m.NewFunc("p", types.I32, x)
+	entry := p.NewBlock("entry")
+	a := p.NewBlock("a")
+	b := p.NewBlock("b")
+	entry.NewCondBr(x, a, b)
+	a.NewRet(constant.NewInt(types.I32, 1))
+	b.NewRet(constant.NewInt(types.I32, 2))
+	// Stubbed function.
+	s := m.NewFunc("s", types.I32)
+	entry = s.NewBlock("entry")
+	sum := entry.NewAdd(constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2))
+	sum = entry.NewAdd(sum, sum)
+	entry.NewRet(entry.NewAdd(sum, sum))
+	// Failed functions, with unsupported constant expressions.
+	for _, name := range []string{"h1", "h2"} {
+		h := m.NewFunc(name, types.I32)
+		entry := h.NewBlock("entry")
+		entry.NewRet(entry.NewLoad(types.I32, constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))))
+	}
+
+	d := NewDecompiler()
+	d.MaxFuncSize = 3
+	d.Stats = &Stats{}
+	prims := map[string][]*primitive.Primitive{"p": nil}
+	if _, err := d.Decompile(m, prims); err == nil {
+		t.Fatalf("expected error for unsupported constant expressions")
+	}
+	stats := d.Stats
+	if stats.Funcs != 5 || stats.Recovered != 1 || stats.Partial != 1 || stats.Stubbed != 1 || stats.Failed != 2 {
+		t.Errorf("function counts mismatch; expected 5 functions (1 recovered, 1 partial, 1 stubbed, 2 failed), got %d functions (%d recovered, %d partial, %d stubbed, %d failed)", stats.Funcs, stats.Recovered, stats.Partial, stats.Stubbed, stats.Failed)
+	}
+	want := Unsupported{Kind: "constant expression", Type: "*constant.ExprAddrSpaceCast", Count: 2}
+	if len(stats.Unsupported) != 1 {
+		t.Fatalf("number of unsupported constructs mismatch; expected 1, got %d", len(stats.Unsupported))
+	}
+	if got := *stats.Unsupported[0]; got != want {
+		t.Errorf("unsupported construct mismatch; expected %v, got %v", want, got)
+	}
+}
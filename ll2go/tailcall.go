@@ -0,0 +1,197 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// tailCallLabel is the label of the loop of functions with tail-recursive
+// self-calls, as targeted by continue statements within nested loops. Labels of
+// basic blocks have the "block_" prefix (see label), and thus never collide.
+const tailCallLabel = "tailcall"
+
+// rewriteTailCalls rewrites the tail-recursive self-calls of the given Go
+// function declaration into loops; i.e. the function body is wrapped in a for
+// statement, and each self-call directly followed by the return of its result
+// is replaced by an assignment of the call arguments to the parameters,
+// followed by a continue statement; e.g.
+//
+//    if n <= 1 {
+//       return acc
+//    }
+//    _2 := fact(n-1, acc*n)
+//    return _2
+//
+// is rewritten into
+//
+//    for {
+//       if n <= 1 {
+//          return acc
+//       }
+//       n, acc = n-1, acc*n
+//       continue
+//    }
+//
+// Variadic functions are left as is.
+func (fc *funcContext) rewriteTailCalls(fn *ast.FuncDecl) {
+	if !fc.TailCalls || fc.f.Sig.Variadic {
+		return
+	}
+	t := &tailCalls{name: fn.Name.Name}
+	for _, field := range fn.Type.Params.List {
+		for _, name := range field.Names {
+			t.params = append(t.params, ast.NewIdent(name.Name))
+		}
+	}
+	fn.Body.List = t.stmts(fn.Body.List, false)
+	if !t.found {
+		return
+	}
+	var loop ast.Stmt = &ast.ForStmt{Body: &ast.BlockStmt{List: fn.Body.List}}
+	if t.labeled {
+		loop = &ast.LabeledStmt{Label: ast.NewIdent(tailCallLabel), Stmt: loop}
+	}
+	fn.Body.List = []ast.Stmt{loop}
+}
+
+// tailCalls keeps track of the tail-recursive self-calls of a function being
+// rewritten into a loop.
+type tailCalls struct {
+	// Go function name.
+	name string
+	// Go function parameters.
+	params []ast.Expr
+	// Tail-recursive self-calls have been rewritten.
+	found bool
+	// Tail-recursive self-calls within nested loops have been rewritten, which
+	// continue the labeled loop of the function.
+	labeled bool
+}
+
+// stmts rewrites the tail-recursive self-calls of the given list of
+// statements, which are nested within a loop if nested is set.
+func (t *tailCalls) stmts(stmts []ast.Stmt, nested bool) []ast.Stmt {
+	var list []ast.Stmt
+	for i := 0; i < len(stmts); i++ {
+		if args, n, ok := t.tailCall(stmts[i:]); ok {
+			var repl []ast.Stmt
+			if len(t.params) > 0 {
+				repl = append(repl, &ast.AssignStmt{Lhs: t.params, Tok: token.ASSIGN, Rhs: args})
+			}
+			cont := &ast.BranchStmt{Tok: token.CONTINUE}
+			if nested {
+				cont.Label = ast.NewIdent(tailCallLabel)
+				t.labeled = true
+			}
+			repl = append(repl, cont)
+			// Preserve the label of the self-call.
+			if label, ok := stmts[i].(*ast.LabeledStmt); ok {
+				repl = labeled(label.Label, repl)
+			}
+			list = append(list, repl...)
+			t.found = true
+			i += n - 1
+			continue
+		}
+		t.stmt(stmts[i], nested)
+		list = append(list, stmts[i])
+	}
+	return list
+}
+
+// stmt rewrites the tail-recursive self-calls of the statement lists within
+// the given statement, which is nested within a loop if nested is set.
+func (t *tailCalls) stmt(stmt ast.Stmt, nested bool) {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStmt:
+		stmt.List = t.stmts(stmt.List, nested)
+	case *ast.LabeledStmt:
+		t.stmt(stmt.Stmt, nested)
+	case *ast.IfStmt:
+		t.stmt(stmt.Body, nested)
+		if stmt.Else != nil {
+			t.stmt(stmt.Else, nested)
+		}
+	case *ast.SwitchStmt:
+		t.stmt(stmt.Body, nested)
+	case *ast.CaseClause:
+		stmt.Body = t.stmts(stmt.Body, nested)
+	case *ast.ForStmt:
+		t.stmt(stmt.Body, true)
+	case *ast.RangeStmt:
+		t.stmt(stmt.Body, true)
+	}
+}
+
+// tailCall returns the arguments of the tail-recursive self-call at the start
+// of the given list of statements, and the number of statements of the
+// self-call and the return of its result, including the empty statements (e.g.
+// the anchors of comments) in between; i.e.
+//
+//    return f(args)
+//
+//    _0 := f(args)
+//    return _0
+//
+//    f(args)
+//    return
+//
+// The boolean return value indicates success.
+func (t *tailCalls) tailCall(stmts []ast.Stmt) ([]ast.Expr, int, bool) {
+	first := stmts[0]
+	if label, ok := first.(*ast.LabeledStmt); ok {
+		first = label.Stmt
+	}
+	// Index of the statement following the self-call.
+	next := 1
+	for next < len(stmts) {
+		if _, ok := stmts[next].(*ast.EmptyStmt); !ok {
+			break
+		}
+		next++
+	}
+	switch stmt := first.(type) {
+	case *ast.ReturnStmt:
+		if len(stmt.Results) == 1 {
+			if args, ok := t.selfCall(stmt.Results[0]); ok {
+				return args, 1, true
+			}
+		}
+	case *ast.AssignStmt:
+		if next >= len(stmts) || stmt.Tok != token.DEFINE || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+			break
+		}
+		args, ok := t.selfCall(stmt.Rhs[0])
+		lhs, isIdent := stmt.Lhs[0].(*ast.Ident)
+		ret, isRet := stmts[next].(*ast.ReturnStmt)
+		if !ok || !isIdent || !isRet || len(ret.Results) != 1 {
+			break
+		}
+		if result, ok := ret.Results[0].(*ast.Ident); ok && result.Name == lhs.Name {
+			return args, next + 1, true
+		}
+	case *ast.ExprStmt:
+		if next >= len(stmts) {
+			break
+		}
+		args, ok := t.selfCall(stmt.X)
+		if ret, isRet := stmts[next].(*ast.ReturnStmt); ok && isRet && len(ret.Results) == 0 {
+			return args, next + 1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// selfCall returns the arguments of the given expression, if a call to the
+// function. The boolean return value indicates success.
+func (t *tailCalls) selfCall(expr ast.Expr) ([]ast.Expr, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || call.Ellipsis.IsValid() || len(call.Args) != len(t.params) {
+		return nil, false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	if !ok || fun.Name != t.name {
+		return nil, false
+	}
+	return call.Args, true
+}
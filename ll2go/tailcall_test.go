@@ -0,0 +1,63 @@
+package ll2go
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestTailCalls(t *testing.T) {
+	// Tail-recursive factorial.
+	//
+	//    int32_t fact(int32_t n, int32_t acc) {
+	//       if (n <= 1) {
+	//          return acc;
+	//       }
+	//       return fact(n-1, acc*n);
+	//    }
+	m := ir.NewModule()
+	n := ir.NewParam("n", types.I32)
+	acc := ir.NewParam("acc", types.I32)
+	f := m.NewFunc("fact", types.I32, n, acc)
+	entry := f.NewBlock("entry")
+	base := f.NewBlock("base")
+	rec := f.NewBlock("rec")
+	cond := entry.NewICmp(enum.IPredSLE, n, constant.NewInt(types.I32, 1))
+	entry.NewCondBr(cond, base, rec)
+	base.NewRet(acc)
+	n1 := rec.NewSub(n, constant.NewInt(types.I32, 1))
+	acc1 := rec.NewMul(acc, n)
+	result := rec.NewCall(f, n1, acc1)
+	rec.NewRet(result)
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	golden := []struct {
+		tailCalls bool
+		want      string
+	}{
+		{
+			tailCalls: true,
+			want:      "func fact(n int32, acc int32) int32 {\n\tfor {\n\t\t_0 := n <= 1\n\t\tif _0 {\n\t\t\treturn acc\n\t\t}\n\t\t_1 := n - 1\n\t\t_2 := acc * n\n\t\tn, acc = _1, _2\n\t\tcontinue\n\t}\n}",
+		},
+		// Tail calls disabled.
+		{
+			want: "func fact(n int32, acc int32) int32 {\n\t_0 := n <= 1\n\tif _0 {\n\t\treturn acc\n\t}\n\t_1 := n - 1\n\t_2 := acc * n\n\t_3 := fact(_1, _2)\n\treturn _3\n}",
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.TailCalls = g.tailCalls
+		fn, err := d.FuncDecl(f, prims)
+		if err != nil {
+			t.Fatalf("unable to decompile function; %v", err)
+		}
+		if got := nodeString(t, fn); got != g.want {
+			t.Errorf("function mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// term converts the given LLVM IR terminator into a corresponding list of Go
+// statements.
+func (fc *funcContext) term(term ir.Terminator) ([]ast.Stmt, error) {
+	stmt, err := fc.termStmt(term)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(fc.instComments(term), stmt), nil
+}
+
+// termStmt converts the given LLVM IR terminator into a corresponding Go
+// statement.
+func (fc *funcContext) termStmt(term ir.Terminator) (ast.Stmt, error) {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		return fc.termRet(term)
+	case *ir.TermBr:
+		return fc.gotoStmt(blockName(term.Target)), nil
+	case *ir.TermCondBr:
+		return fc.termCondBr(term)
+	case *ir.TermSwitch:
+		return fc.termSwitch(term)
+	case *ir.TermIndirectBr:
+		return fc.termIndirectBr(term)
+	case *ir.TermResume:
+		return fc.termResume(term)
+	case *ir.TermUnreachable:
+		return fc.termUnreachable(), nil
+	default:
+		if err := fc.unsupported("terminator", term); err != nil {
+			return nil, err
+		}
+		return &ast.EmptyStmt{}, nil
+	}
+}
+
+// termOperands returns the operands of the given LLVM IR terminator.
+//
+// Terminators not known to the decompiler have no operands; they are reported
+// as unsupported when translated to Go (see term).
+func termOperands(term ir.Terminator) []value.Value {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X == nil {
+			return nil
+		}
+		return []value.Value{term.X}
+	case *ir.TermBr:
+		return nil
+	case *ir.TermCondBr:
+		return []value.Value{term.Cond}
+	case *ir.TermSwitch:
+		return []value.Value{term.X}
+	case *ir.TermIndirectBr:
+		if jt, ok := term.Addr.(*jumpTable); ok {
+			return []value.Value{jt.x}
+		}
+		return []value.Value{term.Addr}
+	case *ir.TermInvoke:
+		return append([]value.Value{term.Invokee}, term.Args...)
+	case *ir.TermResume:
+		return []value.Value{term.X}
+	case *ir.TermUnreachable:
+		return nil
+	case *ir.TermCallBr:
+		return append([]value.Value{term.Callee}, term.Args...)
+	case *ir.TermCatchSwitch:
+		return []value.Value{term.ParentPad}
+	case *ir.TermCatchRet:
+		return []value.Value{term.CatchPad}
+	case *ir.TermCleanupRet:
+		return []value.Value{term.CleanupPad}
+	default:
+		return nil
+	}
+}
+
+// termRet converts the given LLVM IR ret terminator into a corresponding Go
+// return statement.
+//
+// Returned literal structs are returned as multiple values, one per field (see
+// multiResults); e.g.
+//
+//    return x.Field0, x.Field1  // ret {i32, i32} x
+//    return 1, 2                // ret {i32, i32} {i32 1, i32 2}
+//
+// Undefined return values, as common in dead code of optimized LLVM IR, are
+// returned as the zero value of the return type; e.g.
+//
+//    return 0                   // ret i32 undef
+//    return 0, 0                // ret {i32, i32} undef
+func (fc *funcContext) termRet(term *ir.TermRet) (ast.Stmt, error) {
+	// Void return.
+	if term.X == nil {
+		return &ast.ReturnStmt{}, nil
+	}
+	_, isUndef := term.X.(*constant.Undef)
+	if fields, ok := multiResults(term.X.Type()); ok {
+		ret := &ast.ReturnStmt{}
+		c, isConst := term.X.(*constant.Struct)
+		for i, field := range fields {
+			if isUndef {
+				zero, err := fc.zeroValue(fc.GoType(field), field)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				ret.Results = append(ret.Results, zero)
+				continue
+			}
+			if isConst {
+				field, err := fc.typedValue(c.Fields[i])
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				ret.Results = append(ret.Results, field)
+				continue
+			}
+			x, err := fc.Value(term.X)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			ret.Results = append(ret.Results, &ast.SelectorExpr{X: x, Sel: fieldName(i)})
+		}
+		return ret, nil
+	}
+	if isUndef {
+		typ := term.X.Type()
+		zero, err := fc.zeroValue(fc.GoType(typ), typ)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &ast.ReturnStmt{Results: []ast.Expr{zero}}, nil
+	}
+	x, err := fc.typedValue(term.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.ReturnStmt{
+		Results: []ast.Expr{x},
+	}, nil
+}
+
+// termCondBr converts the given LLVM IR conditional br terminator into a
+// corresponding Go if-else statement, with goto statements to the target basic
+// blocks.
+func (fc *funcContext) termCondBr(term *ir.TermCondBr) (ast.Stmt, error) {
+	cond, err := fc.Value(term.Cond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.IfStmt{
+		Cond: cond,
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{fc.gotoStmt(blockName(term.TargetTrue))},
+		},
+		Else: &ast.BlockStmt{
+			List: []ast.Stmt{fc.gotoStmt(blockName(term.TargetFalse))},
+		},
+	}, nil
+}
+
+// termSwitch converts the given LLVM IR switch terminator into a corresponding
+// Go switch statement.
+//
+// Cases with the same target basic block are grouped into a single case
+// clause, and cases with the default target are subsumed by the default clause.
+// The statements of target basic blocks which have no other predecessors are
+// inlined into the corresponding case clause, and other targets are reached
+// through goto statements. LLVM IR switches never fall through, which matches
+// the semantics of Go case clauses without fallthrough statements.
+func (fc *funcContext) termSwitch(term *ir.TermSwitch) (ast.Stmt, error) {
+	var clauses []*ast.CaseClause
+	targetClause := make(map[string]*ast.CaseClause)
+	for _, c := range term.Cases {
+		target := blockName(c.Target)
+		if target == blockName(term.TargetDefault) {
+			continue
+		}
+		clause, ok := targetClause[target]
+		if !ok {
+			body, err := fc.caseBody(c.Target.(*ir.Block))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			clause = &ast.CaseClause{
+				Body: body,
+			}
+			targetClause[target] = clause
+			clauses = append(clauses, clause)
+		}
+		x, err := fc.Value(c.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		clause.List = append(clause.List, x)
+	}
+	defaultBody, err := fc.caseBody(term.TargetDefault.(*ir.Block))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defaultClause := &ast.CaseClause{
+		Body: defaultBody,
+	}
+	clauses = append(clauses, defaultClause)
+	body := &ast.BlockStmt{}
+	for _, clause := range clauses {
+		body.List = append(body.List, clause)
+	}
+	tag, err := fc.Value(term.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.SwitchStmt{
+		Tag:  tag,
+		Body: body,
+	}, nil
+}
+
+// caseBody returns the body of a case clause branching to the given target
+// basic block. The statements of the target basic block are inlined if the
+// switch is its only predecessor, and the target is otherwise reached through a
+// goto statement.
+func (fc *funcContext) caseBody(target *ir.Block) ([]ast.Stmt, error) {
+	block, ok := fc.blocks[target.Name()]
+	if !ok || fc.preds[target.Name()] != 1 || target == target.Parent.Blocks[0] {
+		return []ast.Stmt{fc.gotoStmt(target.Name())}, nil
+	}
+	fc.inlined[target.Name()] = true
+	stmts, err := fc.stmts(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	termStmts, err := fc.term(block.Term)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(stmts, termStmts...), nil
+}
+
+// termIndirectBr converts the given LLVM IR indirectbr terminator into a
+// corresponding Go switch statement, emulating a computed goto.
+//
+// Go has no computed goto statements; thus the address of each target basic
+// block is represented by the index of the basic block within its function
+// (see blockIndices), and the switch statement branches through goto statements
+// to the labeled target basic block of the index.
+//
+//    switch addr {
+//    case 1:
+//       goto block_foo
+//    case 2:
+//       goto block_bar
+//    default:
+//       panic("invalid indirectbr target")
+//    }
+//
+// Indirectbr terminators of jump tables are instead converted into a switch
+// statement on the value of the switch (see jumpTableSwitch).
+func (fc *funcContext) termIndirectBr(term *ir.TermIndirectBr) (ast.Stmt, error) {
+	if jt, ok := term.Addr.(*jumpTable); ok {
+		return fc.jumpTableSwitch(jt)
+	}
+	indices := blockIndices(fc.f)
+	body := &ast.BlockStmt{}
+	seen := make(map[string]bool)
+	for _, target := range term.ValidTargets {
+		name := blockName(target)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		clause := &ast.CaseClause{
+			List: []ast.Expr{intLit(int64(indices[name]))},
+			Body: []ast.Stmt{fc.gotoStmt(name)},
+		}
+		body.List = append(body.List, clause)
+	}
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("invalid indirectbr target")}},
+	}
+	body.List = append(body.List, &ast.CaseClause{Body: []ast.Stmt{&ast.ExprStmt{X: call}}})
+	tag, err := fc.Value(term.Addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ast.SwitchStmt{
+		Tag:  tag,
+		Body: body,
+	}, nil
+}
+
+// blockIndices returns the index of each basic block within the given
+// function, which identifies the address of the basic block; mapping from basic
+// block name to index. The index of a basic block is its position in the
+// function, and is thus stable across decompilations.
+func blockIndices(f *ir.Func) map[string]int {
+	indices := make(map[string]int)
+	for i, block := range f.Blocks {
+		indices[block.Name()] = i
+	}
+	return indices
+}
+
+// termUnreachable converts an LLVM IR unreachable terminator into a
+// corresponding Go panic statement; i.e. `panic("unreachable")`.
+func (fc *funcContext) termUnreachable() ast.Stmt {
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("panic"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("unreachable")}},
+	}
+	return &ast.ExprStmt{X: call}
+}
+
+// gotoStmt returns a goto statement to the basic block with the given name.
+//
+// Branches which are absorbed into recovered control flow primitives are
+// replaced by the structured Go statement of the primitive. Thus, goto
+// statements are only emitted as a fallback for branches of basic blocks which
+// remain after control flow recovery.
+func (fc *funcContext) gotoStmt(name string) ast.Stmt {
+	fc.labels[name]++
+	return &ast.BranchStmt{
+		Tok:   token.GOTO,
+		Label: fc.label(name),
+	}
+}
+
+// label returns the Go label of the basic block with the given name.
+func (d *Decompiler) label(name string) *ast.Ident {
+	return ast.NewIdent(Sanitize("block_" + name))
+}
+
+// labeled returns the given statements after labeling the first statement.
+func labeled(label *ast.Ident, stmts []ast.Stmt) []ast.Stmt {
+	if len(stmts) == 0 {
+		return []ast.Stmt{&ast.LabeledStmt{Label: label, Stmt: &ast.EmptyStmt{}}}
+	}
+	first := &ast.LabeledStmt{Label: label, Stmt: stmts[0]}
+	return append([]ast.Stmt{first}, stmts[1:]...)
+}
+
+// blockName returns the name of the given branch target basic block.
+func blockName(target value.Value) string {
+	return target.(*ir.Block).Name()
+}
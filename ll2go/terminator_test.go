@@ -0,0 +1,492 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestTermRet(t *testing.T) {
+	golden := []struct {
+		newFunc func(m *ir.Module) *ir.Func
+		want    string
+	}{
+		// Void return.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				entry.NewRet(nil)
+				return f
+			},
+			want: "func f() {\n\treturn\n}",
+		},
+		// Value return.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				x := ir.NewParam("x", types.I32)
+				f := m.NewFunc("f", types.I32, x)
+				entry := f.NewBlock("entry")
+				sum := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+				entry.NewRet(sum)
+				return f
+			},
+			want: "func f(x int32) int32 {\n\t_0 := x + 1\n\treturn _0\n}",
+		},
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				f := m.NewFunc("f", types.Double)
+				entry := f.NewBlock("entry")
+				entry.NewRet(constant.NewFloat(types.Double, 2))
+				return f
+			},
+			want: "func f() float64 {\n\treturn 2.0\n}",
+		},
+		// Null pointer return.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				typ := types.NewPointer(types.I32)
+				f := m.NewFunc("f", typ)
+				entry := f.NewBlock("entry")
+				entry.NewRet(constant.NewNull(typ))
+				return f
+			},
+			want: "func f() *int32 {\n\treturn (*int32)(nil)\n}",
+		},
+		// Undefined return values.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				f := m.NewFunc("f", types.I32)
+				entry := f.NewBlock("entry")
+				entry.NewRet(constant.NewUndef(types.I32))
+				return f
+			},
+			want: "func f() int32 {\n\treturn 0\n}",
+		},
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				typ := types.NewPointer(types.I32)
+				f := m.NewFunc("f", typ)
+				entry := f.NewBlock("entry")
+				entry.NewRet(constant.NewUndef(typ))
+				return f
+			},
+			want: "func f() *int32 {\n\treturn (*int32)(nil)\n}",
+		},
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				typ := types.NewStruct(types.I32, types.Double)
+				f := m.NewFunc("f", typ)
+				entry := f.NewBlock("entry")
+				entry.NewRet(constant.NewUndef(typ))
+				return f
+			},
+			want: "func f() (int32, float64) {\n\treturn 0, 0.0\n}",
+		},
+	}
+	for _, g := range golden {
+		f := g.newFunc(ir.NewModule())
+		d := NewDecompiler()
+		fn, err := d.FuncDecl(f, nil)
+		if err != nil {
+			t.Errorf("%q: unable to decompile function; %v", f.Name(), err)
+			continue
+		}
+		got := nodeString(t, fn)
+		if got != g.want {
+			t.Errorf("%q: function mismatch; expected %q, got %q", f.Name(), g.want, got)
+			continue
+		}
+		typeCheck(t, got)
+	}
+}
+
+func TestTermRetMulti(t *testing.T) {
+	// Functions returning {i32, i32} return (int32, int32).
+	//
+	//    struct divmod { int q, r; };
+	//
+	//    struct divmod divmod(int a, int b) {
+	//       return (struct divmod){a / b, a % b};
+	//    }
+	//
+	//    int f(int x) {
+	//       return divmod(x, 10).q;
+	//    }
+	m := ir.NewModule()
+	pair := types.NewStruct(types.I32, types.I32)
+	a := ir.NewParam("a", types.I32)
+	b := ir.NewParam("b", types.I32)
+	divmod := m.NewFunc("divmod", pair, a, b)
+	entry := divmod.NewBlock("entry")
+	q := entry.NewSDiv(a, b)
+	r := entry.NewSRem(a, b)
+	s := entry.NewInsertValue(constant.NewUndef(pair), q, 0)
+	s = entry.NewInsertValue(s, r, 1)
+	entry.NewRet(s)
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry = f.NewBlock("entry")
+	result := entry.NewCall(divmod, x, constant.NewInt(types.I32, 10))
+	entry.NewRet(entry.NewExtractValue(result, 0))
+	// Constant struct.
+	g := m.NewFunc("g", pair)
+	g.NewBlock("entry").NewRet(constant.NewStruct(types.NewStruct(types.I32, types.I32), constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2)))
+
+	d := withComments(NewDecompiler())
+	var got []string
+	for _, fn := range m.Funcs {
+		decl, err := d.FuncDecl(fn, nil)
+		if err != nil {
+			t.Fatalf("%q: unable to decompile function; %v", fn.Name(), err)
+		}
+		got = append(got, commentString(t, d, decl))
+	}
+	want := `func divmod(a int32, b int32) (int32, int32) {
+	_0 := a / b
+	_1 := a % b
+	_2 := struct {
+		Field0	int32
+		Field1	int32
+	}{}	/* undef */
+	_2.Field0 = _0
+	_3 := _2
+	_3.Field1 = _1
+	return _3.Field0, _3.Field1
+}
+func f(x int32) int32 {
+	var _0 struct {
+		Field0	int32
+		Field1	int32
+	}
+	_0.Field0, _0.Field1 = divmod(x, 10)
+	_1 := _0.Field0
+	return _1
+}
+func g() (int32, int32) {
+	return 1, 2
+}`
+	if src := strings.Join(got, "\n"); src != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, src)
+	}
+	typeCheck(t, strings.Join(got, "\n"))
+}
+
+func TestTermBrFallback(t *testing.T) {
+	// Control flow recovery is incomplete, as no control flow primitives are
+	// provided for the function.
+	//
+	//    int f(int x) {
+	//       int y = 0;
+	//       if (x < 10) {
+	//          y = x + 1;
+	//       }
+	//       return y;
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 10))
+	entry.NewCondBr(cond, body, exit)
+	sum := body.NewAdd(x, constant.NewInt(types.I32, 1))
+	body.NewBr(exit)
+	y := exit.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry), ir.NewIncoming(sum, body))
+	y.SetName("y")
+	exit.NewRet(y)
+
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(x int32) int32 {
+	var y int32
+	var _0 bool
+	var _1 int32
+	_0 = x < 10
+	y = 0
+	if _0 {
+		goto block_body
+	} else {
+		goto block_exit
+	}
+block_body:
+	_1 = x + 1
+	y = _1
+	goto block_exit
+block_exit:
+	return y
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestTermBrIrreducible(t *testing.T) {
+	// The loop has two entries, and is thus irreducible; the basic blocks are
+	// emitted as labeled statements joined by goto statements.
+	//
+	//    int f(int n) {
+	//       int i = 0;
+	//       if (n > 10) {
+	//          goto cond;
+	//       }
+	//    body:
+	//       i++;
+	//    cond:
+	//       if (i < n) {
+	//          goto body;
+	//       }
+	//       return i;
+	//    }
+	m := ir.NewModule()
+	n := ir.NewParam("n", types.I32)
+	f := m.NewFunc("f", types.I32, n)
+	entry := f.NewBlock("entry")
+	body := f.NewBlock("body")
+	cond := f.NewBlock("cond")
+	exit := f.NewBlock("exit")
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGT, n, constant.NewInt(types.I32, 10)), cond, body)
+	i := body.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	inc := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	body.NewBr(cond)
+	j := cond.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry), ir.NewIncoming(inc, body))
+	j.SetName("j")
+	cond.NewCondBr(cond.NewICmp(enum.IPredSLT, j, n), body, exit)
+	i.Incs = append(i.Incs, ir.NewIncoming(j, cond))
+	exit.NewRet(j)
+
+	prims, err := RecoverPrims(f)
+	if err != nil {
+		t.Fatalf("unable to recover control flow primitives; %v", err)
+	}
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, prims)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(n int32) int32 {
+	var i int32
+	var j int32
+	var _0 bool
+	var _1 int32
+	var _2 bool
+	_0 = n > 10
+	i = 0
+	j = 0
+	if _0 {
+		goto block_cond
+	} else {
+		goto block_body
+	}
+block_body:
+	_1 = i + 1
+	j = _1
+	goto block_cond
+block_cond:
+	i = j
+	_2 = j < n
+	if _2 {
+		goto block_body
+	} else {
+		goto block_exit
+	}
+block_exit:
+	return j
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestTermSwitch(t *testing.T) {
+	//    int f(int x) {
+	//       switch (x) {
+	//       case 1:
+	//          return 10;
+	//       case 2:
+	//       case 3:
+	//          return 20;
+	//       case 4:
+	//          return 30;
+	//       default:
+	//          return 0;
+	//       }
+	//    }
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	case1 := f.NewBlock("case1")
+	case2 := f.NewBlock("case2")
+	case4 := f.NewBlock("case4")
+	def := f.NewBlock("default")
+	i32 := func(x int64) *constant.Int {
+		return constant.NewInt(types.I32, x)
+	}
+	entry.NewSwitch(x, def,
+		ir.NewCase(i32(1), case1),
+		ir.NewCase(i32(2), case2),
+		ir.NewCase(i32(3), case2),
+		ir.NewCase(i32(4), case4),
+		ir.NewCase(i32(5), def),
+	)
+	case1.NewRet(i32(10))
+	case2.NewRet(i32(20))
+	case4.NewRet(i32(30))
+	def.NewRet(i32(0))
+
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(x int32) int32 {
+	switch x {
+	case 1:
+		return 10
+	case 2, 3:
+		return 20
+	case 4:
+		return 30
+	default:
+		return 0
+	}
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestTermUnreachable(t *testing.T) {
+	golden := []struct {
+		newFunc func(m *ir.Module) *ir.Func
+		want    string
+	}{
+		// Unreachable tail.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				abort := m.NewFunc("abort", types.Void)
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				entry.NewCall(abort)
+				entry.NewUnreachable()
+				return f
+			},
+			want: "func f() {\n\tabort()\n\tpanic(\"unreachable\")\n}",
+		},
+		// Unreachable switch default.
+		{
+			newFunc: func(m *ir.Module) *ir.Func {
+				x := ir.NewParam("x", types.I32)
+				f := m.NewFunc("f", types.I32, x)
+				entry := f.NewBlock("entry")
+				case1 := f.NewBlock("case1")
+				case2 := f.NewBlock("case2")
+				def := f.NewBlock("default")
+				entry.NewSwitch(x, def,
+					ir.NewCase(constant.NewInt(types.I32, 1), case1),
+					ir.NewCase(constant.NewInt(types.I32, 2), case2),
+				)
+				case1.NewRet(constant.NewInt(types.I32, 10))
+				case2.NewRet(constant.NewInt(types.I32, 20))
+				def.NewUnreachable()
+				return f
+			},
+			want: "func f(x int32) int32 {\n\tswitch x {\n\tcase 1:\n\t\treturn 10\n\tcase 2:\n\t\treturn 20\n\tdefault:\n\t\tpanic(\"unreachable\")\n\t}\n}",
+		},
+	}
+	for _, g := range golden {
+		m := ir.NewModule()
+		f := g.newFunc(m)
+		fc := NewDecompiler().newFuncContext()
+		fn, err := fc.funcDecl(f, nil)
+		if err != nil {
+			t.Errorf("%q: unable to decompile function; %v", f.Name(), err)
+			continue
+		}
+		got := nodeString(t, fn)
+		if got != g.want {
+			t.Errorf("%q: function mismatch; expected %q, got %q", f.Name(), g.want, got)
+			continue
+		}
+		if n := len(fc.blocks) - len(fc.inlined); n != 1 {
+			t.Errorf("%q: control flow recovery failed; expected 1 basic block, got %d", f.Name(), n)
+		}
+	}
+}
+
+func TestTermIndirectBr(t *testing.T) {
+	//    int f(void *addr) {
+	//       goto *addr;
+	//    foo:
+	//       return 1;
+	//    bar:
+	//       return 2;
+	//    }
+	m := ir.NewModule()
+	addr := ir.NewParam("addr", types.NewPointer(types.I8))
+	f := m.NewFunc("f", types.I32, addr)
+	entry := f.NewBlock("entry")
+	foo := f.NewBlock("foo")
+	bar := f.NewBlock("bar")
+	entry.NewIndirectBr(addr, foo, bar)
+	foo.NewRet(constant.NewInt(types.I32, 1))
+	bar.NewRet(constant.NewInt(types.I32, 2))
+
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(addr *int8) int32 {
+	switch addr {
+	case 1:
+		goto block_foo
+	case 2:
+		goto block_bar
+	default:
+		panic("invalid indirectbr target")
+	}
+block_foo:
+	return 1
+block_bar:
+	return 2
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+// typeCheck type-checks the given Go source code of top-level declarations.
+func typeCheck(t *testing.T, src string) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", "package p\n\n"+src, 0)
+	if err != nil {
+		t.Errorf("unable to parse Go source code; %v", err)
+		return
+	}
+	conf := &gotypes.Config{Importer: importer.Default()}
+	if _, err := conf.Check("p", fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("unable to type-check Go source code %q; %v", src, err)
+	}
+}
@@ -0,0 +1,503 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// GoType converts the given LLVM IR type into a corresponding Go type.
+//
+// Named LLVM IR types map to the Go identifier of their type definition (see
+// registerType).
+// Unsupported types map to the blank identifier; Decompile and FuncDecl return
+// an error for the first unsupported type of the module or function, unless
+// reporting (see Report).
+func (d *Decompiler) GoType(t types.Type) ast.Expr {
+	if name := t.Name(); name != "" {
+		if d.types != nil {
+			return ast.NewIdent(d.registerType(t))
+		}
+		return typeName(name)
+	}
+	return d.typeDef(t)
+}
+
+// typeDef converts the definition of the given LLVM IR type into a
+// corresponding Go type.
+func (d *Decompiler) typeDef(t types.Type) ast.Expr {
+	switch t := t.(type) {
+	case *types.FuncType:
+		sig := &ast.FuncType{
+			Params: &ast.FieldList{},
+		}
+		for _, param := range t.Params {
+			field := &ast.Field{
+				Type: d.GoType(param),
+			}
+			sig.Params.List = append(sig.Params.List, field)
+		}
+		// The types of variadic arguments are not known.
+		if t.Variadic {
+			field := &ast.Field{
+				Type: &ast.Ellipsis{Elt: ast.NewIdent("interface{}")},
+			}
+			sig.Params.List = append(sig.Params.List, field)
+		}
+		// Functions returning literal structs return the fields of the struct
+		// as multiple return values.
+		if fields, ok := multiResults(t.RetType); ok {
+			sig.Results = &ast.FieldList{}
+			for _, field := range fields {
+				result := &ast.Field{
+					Type: d.GoType(field),
+				}
+				sig.Results.List = append(sig.Results.List, result)
+			}
+		} else if !types.Equal(t.RetType, types.Void) {
+			result := &ast.Field{
+				Type: d.GoType(t.RetType),
+			}
+			sig.Results = &ast.FieldList{
+				List: []*ast.Field{result},
+			}
+		}
+		return sig
+	case *types.IntType:
+		return d.intType(t, "int")
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf, types.FloatKindFloat:
+			return ast.NewIdent("float32")
+		default:
+			// Go has no floating-point types with a precision larger than double
+			// precision.
+			return ast.NewIdent("float64")
+		}
+	case *types.PointerType:
+		return d.pointerType(t)
+	case *types.ArrayType:
+		return &ast.ArrayType{
+			Len: &ast.BasicLit{Kind: token.INT, Value: strconv.FormatUint(t.Len, 10)},
+			Elt: d.GoType(t.ElemType),
+		}
+	case *types.VectorType:
+		// Go has no notion of SIMD vectors; map vectors to arrays, which are
+		// marked as vectors so that later passes may specialize them.
+		typ := &ast.ArrayType{
+			Len: &ast.BasicLit{Kind: token.INT, Value: strconv.FormatUint(t.Len, 10)},
+			Elt: d.GoType(t.ElemType),
+		}
+		return d.commented(typ, "vector")
+	case *types.StructType:
+		st := &ast.StructType{
+			Fields: &ast.FieldList{},
+		}
+		for i, field := range t.Fields {
+			f := &ast.Field{
+				Names: []*ast.Ident{fieldName(i)},
+				Type:  d.GoType(field),
+			}
+			st.Fields.List = append(st.Fields.List, f)
+		}
+		// Go has no notion of packed structs.
+		if t.Packed {
+			return d.commented(st, "packed")
+		}
+		return st
+	default:
+		// Go types are converted throughout the decompiler without returning
+		// errors; thus unsupported types are recorded and returned as an error
+		// once the function or module has been decompiled.
+		if err := d.unsupported("type", t); err != nil && d.typeErr != nil && *d.typeErr == nil {
+			*d.typeErr = err
+		}
+		return unsupportedExpr()
+	}
+}
+
+// typeError returns the first unsupported type encountered while decompiling
+// the function or module, if any.
+func (d *Decompiler) typeError() error {
+	if d.typeErr == nil {
+		return nil
+	}
+	return *d.typeErr
+}
+
+// multiResults returns the field types of the given return type of a function,
+// if returned as multiple Go return values, and a boolean indicating success.
+//
+// Literal struct types with at least two fields (e.g. {i32, i32}) are returned
+// as multiple return values (e.g. (int32, int32)), as such return types are
+// common after undoing ABI lowering. Named struct types are returned as is.
+func multiResults(ret types.Type) ([]types.Type, bool) {
+	st, ok := ret.(*types.StructType)
+	if !ok || st.Name() != "" || st.Opaque || len(st.Fields) < 2 {
+		return nil, false
+	}
+	return st.Fields, true
+}
+
+// TypeDecl converts the given LLVM IR type definition into a corresponding Go
+// type declaration; e.g.
+//
+//    %struct.foo = type { i32, i8* }
+//
+// is converted into
+//
+//    type foo struct {
+//       Field0 int32
+//       Field1 *int8
+//    }
+func (d *Decompiler) TypeDecl(t types.Type) *ast.GenDecl {
+	spec := &ast.TypeSpec{
+		Name: typeName(t.Name()),
+		Type: d.typeDef(t),
+	}
+	return &ast.GenDecl{
+		Tok:   token.TYPE,
+		Specs: []ast.Spec{spec},
+	}
+}
+
+// A typeRegistry keeps track of the Go type declarations of the named LLVM IR
+// types referenced while decompiling a module, so that each named type is
+// declared exactly once.
+//
+// A typeRegistry may be used concurrently by multiple goroutines.
+type typeRegistry struct {
+	sync.Mutex
+	// Go type specifications; mapping from LLVM IR type name to Go type
+	// specification.
+	specs map[string]*ast.TypeSpec
+	// LLVM IR type names in order of registration.
+	names []string
+	// Allocated Go identifiers of type definitions.
+	taken map[string]bool
+}
+
+// newTypeRegistry returns a new type registry.
+func newTypeRegistry() *typeRegistry {
+	return &typeRegistry{
+		specs: make(map[string]*ast.TypeSpec),
+		taken: make(map[string]bool),
+	}
+}
+
+// registerType registers the given named LLVM IR type in the type registry of
+// the decompiler, unless already registered, and returns the Go identifier of
+// its type definition.
+//
+// LLVM IR type names which map to the same Go identifier (e.g. "struct.foo" and
+// "union.foo"; see typeName) are disambiguated by a numeric suffix in order of
+// registration (e.g. "foo" and "foo_1").
+func (d *Decompiler) registerType(t types.Type) string {
+	name := t.Name()
+	r := d.types
+	r.Lock()
+	if spec, ok := r.specs[name]; ok {
+		r.Unlock()
+		return spec.Name.Name
+	}
+	base := typeName(name).Name
+	ident := base
+	for i := 1; r.taken[ident]; i++ {
+		ident = fmt.Sprintf("%s_%d", base, i)
+	}
+	r.taken[ident] = true
+	spec := &ast.TypeSpec{
+		Name: ast.NewIdent(ident),
+	}
+	r.specs[name] = spec
+	r.names = append(r.names, name)
+	r.Unlock()
+	// The type definition is converted after registration, as it may refer to
+	// the named type itself.
+	typ := d.typeDef(t)
+	r.Lock()
+	spec.Type = typ
+	r.Unlock()
+	return ident
+}
+
+// decls returns a type declaration for each registered type. The first n types
+// are declared in order of registration, and the remaining types in
+// alphabetical order, as they may have been registered concurrently.
+func (r *typeRegistry) decls(n int) []ast.Decl {
+	r.Lock()
+	defer r.Unlock()
+	names := append([]string(nil), r.names...)
+	sort.Strings(names[n:])
+	var decls []ast.Decl
+	for _, name := range names {
+		decl := &ast.GenDecl{
+			Tok:   token.TYPE,
+			Specs: []ast.Spec{r.specs[name]},
+		}
+		decls = append(decls, decl)
+	}
+	return decls
+}
+
+// typeName returns the Go identifier of the type definition with the given
+// name, after dropping any "struct.", "union." or "class." prefix; reserved
+// identifiers (see isReserved) are prefixed with an underscore.
+func typeName(name string) *ast.Ident {
+	for _, prefix := range []string{"struct.", "union.", "class."} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	return ast.NewIdent(unreserved(Sanitize(name)))
+}
+
+// pointerType converts the given LLVM IR pointer type into a corresponding Go
+// type.
+//
+// Pointers to functions map to Go function types, as Go function values are
+// references. Pointers to opaque structs map to unsafe.Pointer, and pointers to
+// i8 map to the Go type specified by the `-i8ptr` flag (*int8, []byte or
+// unsafe.Pointer). Other pointers map to Go pointer types.
+func (d *Decompiler) pointerType(t *types.PointerType) ast.Expr {
+	switch elem := t.ElemType.(type) {
+	case *types.FuncType:
+		return d.GoType(elem)
+	case *types.StructType:
+		if elem.Opaque {
+			return d.unsafeSel("Pointer")
+		}
+	case *types.IntType:
+		if elem.BitSize == 8 {
+			switch d.I8Ptr {
+			case "[]byte":
+				return &ast.ArrayType{Elt: ast.NewIdent("byte")}
+			case "unsafe.Pointer":
+				return d.unsafeSel("Pointer")
+			}
+		}
+	}
+	return &ast.StarExpr{X: d.GoType(t.ElemType)}
+}
+
+// unsignedType returns the unsigned Go integer type of the same size as the
+// given LLVM IR integer type. Unsigned operations on other types (e.g. integer
+// vectors) are not yet supported.
+func (d *Decompiler) unsignedType(t types.Type) (ast.Expr, error) {
+	typ, ok := t.(*types.IntType)
+	if !ok {
+		if err := d.unsupported("unsigned type of", t); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	return d.intType(typ, "uint"), nil
+}
+
+// intType returns the Go integer type, with the given prefix ("int" or
+// "uint"), which corresponds to the given LLVM IR integer type.
+//
+// The i1 type maps to bool, and the i8, i16, i32 and i64 types map to the Go
+// integer types of the same size. Integer types of other sizes map to the next
+// larger Go integer type, with a comment noting that the value should be masked
+// to the original size (e.g. i24 maps to `int32 /* i24 */`); as Go has no
+// integer types larger than 64 bits, larger integer types map to the 64-bit Go
+// integer type.
+//
+// If a target platform is set, integer types of the pointer size of the target
+// map to int and uintptr (e.g. i64 maps to int on linux-amd64). The pointer
+// size of the target is taken from the module being decompiled, if specified
+// (see ptrSize).
+func (d *Decompiler) intType(t *types.IntType, prefix string) ast.Expr {
+	typ := d.intIdent(t, prefix)
+	if size := goIntSize(t.BitSize); t.BitSize != size && typ.Name == fmt.Sprintf("%s%d", prefix, size) {
+		return d.commented(typ, t.String())
+	}
+	return typ
+}
+
+// intIdent returns the identifier of the Go integer type, with the given prefix
+// ("int" or "uint"), which corresponds to the given LLVM IR integer type; i.e.
+// the Go type of intType without the comment noting the original size.
+func (d *Decompiler) intIdent(t *types.IntType, prefix string) *ast.Ident {
+	if ptrSize, ok := d.ptrSize(); ok && t.BitSize == ptrSize {
+		if prefix == "uint" {
+			return ast.NewIdent("uintptr")
+		}
+		return ast.NewIdent("int")
+	}
+	if t.BitSize == 1 && prefix == "int" {
+		return ast.NewIdent("bool")
+	}
+	return ast.NewIdent(fmt.Sprintf("%s%d", prefix, goIntSize(t.BitSize)))
+}
+
+// goIntSize returns the size in bits of the Go integer type corresponding to
+// LLVM IR integer types of the given size; i.e. the next larger size of 8, 16,
+// 32 and 64 bits.
+func goIntSize(size uint64) uint64 {
+	switch {
+	case size <= 8:
+		return 8
+	case size <= 16:
+		return 16
+	case size <= 32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+// fieldName returns the Go identifier of the struct field with the given index.
+func fieldName(index int) *ast.Ident {
+	return ast.NewIdent(fmt.Sprintf("Field%d", index))
+}
+
+// paramType returns the Go type of the given LLVM IR function parameter.
+//
+// If a target platform is set, zeroext integer parameters of the pointer size
+// of the target map to uintptr, as the zeroext attribute hints that the C type
+// of the original source is unsigned (e.g. size_t); see uintptrParam.
+func (d *Decompiler) paramType(param *ir.Param) ast.Expr {
+	if d.uintptrParam(param) {
+		return d.intType(param.Typ.(*types.IntType), "uint")
+	}
+	return d.GoType(param.Typ)
+}
+
+// uintptrParam reports whether the given LLVM IR function parameter is a
+// zeroext integer of the pointer size of the target platform, which maps to
+// uintptr. Such parameters are used as int within the function body, and the
+// corresponding arguments of calls are passed as uintptr.
+func (d *Decompiler) uintptrParam(param *ir.Param) bool {
+	t, ok := param.Typ.(*types.IntType)
+	if !ok {
+		return false
+	}
+	if ptrSize, ok := d.ptrSize(); !ok || t.BitSize != ptrSize {
+		return false
+	}
+	for _, attr := range param.Attrs {
+		if attr == enum.ParamAttrZeroExt {
+			return true
+		}
+	}
+	return false
+}
+
+// ptrSize returns the pointer size in bits of the target platform, and reports
+// whether a target platform is set. The pointer size of the module being
+// decompiled takes precedence over that of the architecture of the target, as
+// the C types of the original source are sized by the data layout of the
+// module (e.g. pointers of wasm32 modules are 32 bits); see modulePtrSize.
+func (d *Decompiler) ptrSize() (uint64, bool) {
+	if len(d.Target) == 0 {
+		return 0, false
+	}
+	if d.modulePtrSize != 0 {
+		return d.modulePtrSize, true
+	}
+	size, err := TargetPtrSize(d.Target)
+	return size, err == nil
+}
+
+// modulePtrSize returns the pointer size in bits of the given LLVM IR module,
+// as specified by its data layout (e.g. 32 for "e-m:e-p:32:32-i64:64") or by
+// the architecture of its target triple (e.g. 32 for "wasm32-unknown-unknown");
+// or 0 if unspecified.
+//
+// Data layouts without a pointer specification of address space 0 use the
+// default pointer size of 64 bits.
+func modulePtrSize(m *ir.Module) uint64 {
+	if len(m.DataLayout) > 0 {
+		for _, spec := range strings.Split(m.DataLayout, "-") {
+			if !strings.HasPrefix(spec, "p:") && !strings.HasPrefix(spec, "p0:") {
+				continue
+			}
+			fields := strings.Split(spec, ":")
+			if size, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				return size
+			}
+		}
+		return 64
+	}
+	arch := m.TargetTriple
+	if pos := strings.Index(arch, "-"); pos != -1 {
+		arch = arch[:pos]
+	}
+	for _, a := range tripleArchPtrSize {
+		if strings.HasPrefix(arch, a.prefix) {
+			return a.size
+		}
+	}
+	return 0
+}
+
+// tripleArchPtrSize maps from the prefix of the architecture of target triples
+// to the pointer size in bits of the architecture; 64-bit architectures precede
+// 32-bit architectures of the same prefix (e.g. mips64 and mips).
+var tripleArchPtrSize = []struct {
+	prefix string
+	size   uint64
+}{
+	{prefix: "aarch64", size: 64},
+	{prefix: "arm64", size: 64},
+	{prefix: "mips64", size: 64},
+	{prefix: "powerpc64", size: 64},
+	{prefix: "riscv64", size: 64},
+	{prefix: "s390x", size: 64},
+	{prefix: "sparcv9", size: 64},
+	{prefix: "wasm64", size: 64},
+	{prefix: "x86_64", size: 64},
+	{prefix: "arm", size: 32},
+	{prefix: "i386", size: 32},
+	{prefix: "i486", size: 32},
+	{prefix: "i586", size: 32},
+	{prefix: "i686", size: 32},
+	{prefix: "mips", size: 32},
+	{prefix: "powerpc", size: 32},
+	{prefix: "riscv32", size: 32},
+	{prefix: "sparc", size: 32},
+	{prefix: "thumb", size: 32},
+	{prefix: "wasm32", size: 32},
+}
+
+// TargetPtrSize returns the pointer size in bits of the given target platform,
+// as specified by "GOOS-GOARCH" (e.g. 64 for "linux-amd64").
+func TargetPtrSize(target string) (uint64, error) {
+	pos := strings.LastIndex(target, "-")
+	if pos == -1 {
+		return 0, errors.Errorf("invalid target %q; expected GOOS-GOARCH", target)
+	}
+	arch := target[pos+1:]
+	size, ok := archPtrSize[arch]
+	if !ok {
+		return 0, errors.Errorf("support for target architecture %q of target %q not yet implemented", arch, target)
+	}
+	return size, nil
+}
+
+// archPtrSize maps from GOARCH to the pointer size in bits of the C types of
+// the architecture; e.g. 32 for wasm, as C is compiled to wasm32 by default.
+var archPtrSize = map[string]uint64{
+	"386":      32,
+	"amd64":    64,
+	"arm":      32,
+	"arm64":    64,
+	"mips":     32,
+	"mipsle":   32,
+	"mips64":   64,
+	"mips64le": 64,
+	"ppc64":    64,
+	"ppc64le":  64,
+	"riscv64":  64,
+	"s390x":    64,
+	"wasm":     32,
+}
@@ -0,0 +1,286 @@
+package ll2go
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestGoTypeInt(t *testing.T) {
+	golden := []struct {
+		size int
+		want string
+	}{
+		{size: 1, want: "bool"},
+		{size: 8, want: "int8"},
+		{size: 16, want: "int16"},
+		{size: 32, want: "int32"},
+		{size: 64, want: "int64"},
+		// Arbitrary widths.
+		{size: 2, want: "int8 /* i2 */"},
+		{size: 12, want: "int16 /* i12 */"},
+		{size: 24, want: "int32 /* i24 */"},
+		{size: 48, want: "int64 /* i48 */"},
+		{size: 128, want: "int64 /* i128 */"},
+	}
+	for _, g := range golden {
+		d := withComments(NewDecompiler())
+		got := commentString(t, d, d.GoType(types.NewInt(uint64(g.size))))
+		if got != g.want {
+			t.Errorf("i%d: type mismatch; expected %q, got %q", g.size, g.want, got)
+		}
+	}
+}
+
+func TestGoTypeTarget(t *testing.T) {
+	golden := []struct {
+		in     *types.IntType
+		target string
+		want   string
+		// Unsigned Go type.
+		wantUnsigned string
+	}{
+		// Exact-width types.
+		{in: types.I32, want: "int32", wantUnsigned: "uint32"},
+		{in: types.I64, want: "int64", wantUnsigned: "uint64"},
+		// C mode on a 64-bit target.
+		{in: types.I32, target: "linux-amd64", want: "int32", wantUnsigned: "uint32"},
+		{in: types.I64, target: "linux-amd64", want: "int", wantUnsigned: "uintptr"},
+		// C mode on a 32-bit target.
+		{in: types.I32, target: "linux-386", want: "int", wantUnsigned: "uintptr"},
+		{in: types.I64, target: "linux-386", want: "int64", wantUnsigned: "uint64"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		d.Target = g.target
+		got := nodeString(t, d.GoType(g.in))
+		if got != g.want {
+			t.Errorf("%v (target %q): type mismatch; expected %q, got %q", g.in, g.target, g.want, got)
+		}
+		typ, err := d.unsignedType(g.in)
+		if err != nil {
+			t.Errorf("%v (target %q): unexpected error; %v", g.in, g.target, err)
+			continue
+		}
+		got = nodeString(t, typ)
+		if got != g.wantUnsigned {
+			t.Errorf("%v (target %q): unsigned type mismatch; expected %q, got %q", g.in, g.target, g.wantUnsigned, got)
+		}
+	}
+	if _, err := TargetPtrSize("linux-foo"); err == nil {
+		t.Errorf("expected error for unknown target architecture")
+	}
+}
+
+func TestModulePtrSize(t *testing.T) {
+	golden := []struct {
+		dataLayout, triple string
+		want               uint64
+	}{
+		// Unspecified.
+		{want: 0},
+		// Data layout.
+		{dataLayout: "e-m:e-p:32:32-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20", triple: "wasm32-unknown-unknown", want: 32},
+		{dataLayout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128", triple: "x86_64-pc-linux-gnu", want: 64},
+		// The data layout takes precedence over the target triple.
+		{dataLayout: "e-p:32:32", triple: "x86_64-pc-linux-gnu", want: 32},
+		// Target triple.
+		{triple: "wasm32-unknown-unknown", want: 32},
+		{triple: "wasm64-unknown-unknown", want: 64},
+		{triple: "i686-pc-linux-gnu", want: 32},
+		{triple: "mips64el-unknown-linux-gnu", want: 64},
+		{triple: "mipsel-unknown-linux-gnu", want: 32},
+		{triple: "foo-unknown-unknown", want: 0},
+	}
+	for _, g := range golden {
+		m := ir.NewModule()
+		m.DataLayout = g.dataLayout
+		m.TargetTriple = g.triple
+		if got := modulePtrSize(m); got != g.want {
+			t.Errorf("%q, %q: pointer size mismatch; expected %d, got %d", g.dataLayout, g.triple, g.want, got)
+		}
+	}
+}
+
+func TestDecompileTargetZeroExt(t *testing.T) {
+	// The pointer size of the wasm32 module takes precedence over that of the
+	// target architecture.
+	const src = `
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128"
+target triple = "wasm32-unknown-unknown"
+
+define i32 @f(i32 zeroext %n, i32 %x) {
+entry:
+	%y = add i32 %n, %x
+	ret i32 %y
+}
+
+define i32 @g(i32 %x) {
+entry:
+	%y = call i32 @f(i32 zeroext 5, i32 %x)
+	ret i32 %y
+}
+`
+	m, err := asm.ParseString("", src)
+	if err != nil {
+		t.Fatalf("unable to parse LLVM IR module; %v", err)
+	}
+	d := NewDecompiler()
+	d.Target = "linux-amd64"
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	var fns []string
+	for _, decl := range file.Decls {
+		fns = append(fns, nodeString(t, decl))
+	}
+	got := strings.Join(fns, "\n\n")
+	want := "func f(n uintptr, x int) int {\n\ty := int(n) + x\n\treturn y\n}\n\nfunc g(x int) int {\n\ty := f(uintptr(5), x)\n\treturn y\n}"
+	if got != want {
+		t.Errorf("Go source mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestGoTypePointer(t *testing.T) {
+	opaque := &types.StructType{TypeName: "FILE", Opaque: true}
+	golden := []struct {
+		in    types.Type
+		i8Ptr string
+		want  string
+	}{
+		// Pointer to int.
+		{in: types.NewPointer(types.I32), want: "*int32"},
+		{in: types.NewPointer(types.NewPointer(types.I64)), want: "**int64"},
+		// Pointer to struct.
+		{in: types.NewPointer(types.NewStruct(types.I32, types.Double)), want: "*struct {\n\tField0\tint32\n\tField1\tfloat64\n}"},
+		// Pointer to opaque struct.
+		{in: types.NewPointer(opaque), want: "unsafe.Pointer"},
+		// Pointer to function.
+		{in: types.NewPointer(types.NewFunc(types.I32, types.I8)), want: "func(int8) int32"},
+		{in: types.NewPointer(types.NewPointer(types.NewFunc(types.Void))), want: "*func()"},
+		// Pointer to i8.
+		{in: types.NewPointer(types.I8), want: "*int8"},
+		{in: types.NewPointer(types.I8), i8Ptr: "[]byte", want: "[]byte"},
+		{in: types.NewPointer(types.I8), i8Ptr: "unsafe.Pointer", want: "unsafe.Pointer"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		if len(g.i8Ptr) > 0 {
+			d.I8Ptr = g.i8Ptr
+		}
+		got := nodeString(t, d.GoType(g.in))
+		if got != g.want {
+			t.Errorf("%v: type mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestTypeDecl(t *testing.T) {
+	// %struct.point = type { i32, i32 }
+	// %struct.rect = type { %struct.point, %struct.point }
+	m := ir.NewModule()
+	point := m.NewTypeDef("struct.point", types.NewStruct(types.I32, types.I32))
+	rect := m.NewTypeDef("struct.rect", types.NewStruct(point, point))
+	p := ir.NewParam("p", types.NewPointer(point))
+	r := ir.NewParam("r", types.NewPointer(rect))
+	f := m.NewFunc("f", types.Void, p, r)
+	f.NewBlock("entry").NewRet(nil)
+
+	d := NewDecompiler()
+	var decls []string
+	for _, typ := range m.TypeDefs {
+		decls = append(decls, nodeString(t, d.TypeDecl(typ)))
+	}
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	decls = append(decls, nodeString(t, fn))
+	got := strings.Join(decls, "\n\n")
+	want := `type point struct {
+	Field0	int32
+	Field1	int32
+}
+
+type rect struct {
+	Field0	point
+	Field1	point
+}
+
+func f(p *point, r *rect) {
+	return
+}`
+	if got != want {
+		t.Errorf("declaration mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestGoTypePacked(t *testing.T) {
+	typ := &types.StructType{Fields: []types.Type{types.I8, types.I32}, Packed: true}
+	d := withComments(NewDecompiler())
+	want := "struct {\n\tField0\tint8\n\tField1\tint32\n} /* packed */"
+	if got := commentString(t, d, d.GoType(typ)); got != want {
+		t.Errorf("type mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestGoTypeArray(t *testing.T) {
+	golden := []struct {
+		in   types.Type
+		want string
+	}{
+		// [4 x i32]
+		{in: types.NewArray(4, types.I32), want: "[4]int32"},
+		// [2 x [3 x i8]]
+		{in: types.NewArray(2, types.NewArray(3, types.I8)), want: "[2][3]int8"},
+		// <4 x float>
+		{in: types.NewVector(4, types.Float), want: "[4]float32 /* vector */"},
+		// <2 x i64*>
+		{in: types.NewVector(2, types.NewPointer(types.I64)), want: "[2]*int64 /* vector */"},
+	}
+	for _, g := range golden {
+		d := withComments(NewDecompiler())
+		got := commentString(t, d, d.GoType(g.in))
+		if got != g.want {
+			t.Errorf("%v: type mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestTypeDeclDedup(t *testing.T) {
+	// Named types referenced by three functions, which are not part of the
+	// type definitions of the module.
+	//
+	// %struct.node = type { i32, %struct.node* }
+	node := &types.StructType{TypeName: "struct.node"}
+	node.Fields = []types.Type{types.I32, types.NewPointer(node)}
+	m := ir.NewModule()
+	for _, name := range []string{"f", "g", "h"} {
+		p := ir.NewParam("p", types.NewPointer(node))
+		f := m.NewFunc(name, types.Void, p)
+		f.NewBlock("entry").NewRet(nil)
+	}
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	var decls []string
+	for _, decl := range file.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+			decls = append(decls, nodeString(t, decl))
+		}
+	}
+	want := []string{"type node struct {\n\tField0\tint32\n\tField1\t*node\n}"}
+	if !reflect.DeepEqual(decls, want) {
+		t.Errorf("type declarations mismatch; expected %q, got %q", want, decls)
+	}
+}
@@ -0,0 +1,549 @@
+package ll2go
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// Value converts the given LLVM IR value into a corresponding Go expression.
+func (d *Decompiler) Value(v value.Value) (ast.Expr, error) {
+	switch v := v.(type) {
+	case *constant.Int:
+		return d.intConst(v), nil
+	case *constant.Float:
+		return d.floatLit(v)
+	case *constant.Null:
+		return ast.NewIdent("nil"), nil
+	case *constant.ZeroInitializer:
+		return d.zeroValue(d.GoType(v.Typ), v.Typ)
+	case *constant.Undef:
+		return d.undef(v)
+	case *constant.CharArray:
+		return byteLit(v.X), nil
+	case *constant.Array:
+		return d.array(v)
+	case *constant.Struct:
+		return d.aggregate(v.Typ, v.Fields)
+	case *constant.Vector:
+		return d.aggregate(v.Typ, v.Elems)
+	case *constant.BlockAddress:
+		return d.blockAddress(v)
+	case constant.Expression:
+		return d.constExpr(v)
+	case *returned:
+		return d.returnedCond(v), nil
+	case *laneValue:
+		x, err := d.Value(v.x)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &ast.IndexExpr{X: x, Index: v.lane}, nil
+	case *ir.Global:
+		// Global variables are addressed through pointers in LLVM IR.
+		return &ast.UnaryExpr{Op: token.AND, X: d.global(v.Name())}, nil
+	case *ir.Func:
+		return d.global(v.Name()), nil
+	case *ir.InlineAsm:
+		return d.inlineAsm(v)
+	case *ir.InstAlloca:
+		return d.alloca(v), nil
+	case *ir.Arg:
+		return d.Value(v.Value)
+	case *ir.Param:
+		// Pointer-sized zeroext parameters are declared as uintptr, and used as
+		// int (see uintptrParam).
+		if d.uintptrParam(v) {
+			return d.conv(d.GoType(v.Typ), d.local(v.Name())), nil
+		}
+		return d.local(v.Name()), nil
+	case value.Named:
+		return d.local(v.Name()), nil
+	default:
+		if err := d.unsupported("value", v); err != nil {
+			return nil, err
+		}
+		return unsupportedExpr(), nil
+	}
+}
+
+// values converts the given LLVM IR values into a corresponding list of Go
+// expressions.
+func (d *Decompiler) values(vs ...value.Value) ([]ast.Expr, error) {
+	var exprs []ast.Expr
+	for _, v := range vs {
+		expr, err := d.Value(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// constExpr converts the given LLVM IR constant expression into a
+// corresponding Go expression, recursively lowering the operands of the
+// constant expression as for the corresponding instruction; e.g.
+//
+//    &g.Field1      // getelementptr (%struct.s, %struct.s* @g, i32 0, i32 1)
+//    (*int8)(&g)    // bitcast (i32* @g to i8*)
+//    (1 + 2) * 3    // mul (i32 add (i32 1, i32 2), i32 3)
+func (d *Decompiler) constExpr(e constant.Expression) (ast.Expr, error) {
+	var (
+		expr ast.Expr
+		err  error
+	)
+	switch e := e.(type) {
+	// Binary expressions.
+	case *constant.ExprAdd:
+		expr, err = d.binaryOp(e.X, token.ADD, e.Y)
+	case *constant.ExprFAdd:
+		expr, err = d.binaryOp(e.X, token.ADD, e.Y)
+	case *constant.ExprSub:
+		expr, err = d.binaryOp(e.X, token.SUB, e.Y)
+	case *constant.ExprFSub:
+		expr, err = d.binaryOp(e.X, token.SUB, e.Y)
+	case *constant.ExprMul:
+		expr, err = d.binaryOp(e.X, token.MUL, e.Y)
+	case *constant.ExprFMul:
+		expr, err = d.binaryOp(e.X, token.MUL, e.Y)
+	case *constant.ExprUDiv:
+		expr, err = d.unsignedOp(e.X, token.QUO, e.Y)
+	case *constant.ExprSDiv:
+		expr, err = d.binaryOp(e.X, token.QUO, e.Y)
+	case *constant.ExprFDiv:
+		expr, err = d.binaryOp(e.X, token.QUO, e.Y)
+	case *constant.ExprURem:
+		expr, err = d.unsignedOp(e.X, token.REM, e.Y)
+	case *constant.ExprSRem:
+		expr, err = d.binaryOp(e.X, token.REM, e.Y)
+	// Bitwise expressions.
+	case *constant.ExprShl:
+		expr, err = d.binaryOp(e.X, token.SHL, e.Y)
+	case *constant.ExprLShr:
+		expr, err = d.unsignedOp(e.X, token.SHR, e.Y)
+	case *constant.ExprAShr:
+		expr, err = d.binaryOp(e.X, token.SHR, e.Y)
+	case *constant.ExprAnd:
+		expr, err = d.binaryOp(e.X, token.AND, e.Y)
+	case *constant.ExprOr:
+		expr, err = d.binaryOp(e.X, token.OR, e.Y)
+	case *constant.ExprXor:
+		expr, err = d.binaryOp(e.X, token.XOR, e.Y)
+	// Memory expressions.
+	case *constant.ExprGetElementPtr:
+		if g, ok := e.Src.(*ir.Global); ok && d.strs[g] {
+			buf, _ := charArray(g.Init)
+			return byteLit(buf), nil
+		}
+		var indices []value.Value
+		for _, index := range e.Indices {
+			indices = append(indices, index)
+		}
+		return d.gep(e.Src, e.ElemType, indices)
+	// Conversion expressions.
+	case *constant.ExprTrunc:
+		return d.trunc(e.From, e.To)
+	case *constant.ExprZExt:
+		return d.zext(e.From, e.To)
+	case *constant.ExprSExt:
+		return d.sext(e.From, e.To)
+	case *constant.ExprPtrToInt:
+		return d.ptrToInt(e.From, e.To)
+	case *constant.ExprIntToPtr:
+		return d.intToPtr(e.From, e.To)
+	case *constant.ExprBitCast:
+		return d.bitCast(e.From, e.To)
+	default:
+		if err := d.unsupported("constant expression", e); err != nil {
+			return nil, err
+		}
+		return unsupportedExpr(), nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Operands of nested constant expressions are parenthesized, as Go AST
+	// nodes without positions carry no parentheses of their own.
+	if bin, ok := expr.(*ast.BinaryExpr); ok {
+		bin.X, bin.Y = paren(bin.X), paren(bin.Y)
+	}
+	return expr, nil
+}
+
+// paren returns the given expression, parenthesized if a binary expression.
+func paren(expr ast.Expr) ast.Expr {
+	if _, ok := expr.(*ast.BinaryExpr); ok {
+		return &ast.ParenExpr{X: expr}
+	}
+	return expr
+}
+
+// blockAddress converts the given LLVM IR blockaddress constant into the index
+// of the basic block within its function (see blockIndices), as targeted by
+// indirectbr terminators; e.g.
+//
+//    2 /* blockaddress(@f, %bar) */
+func (d *Decompiler) blockAddress(c *constant.BlockAddress) (ast.Expr, error) {
+	f, ok := c.Func.(*ir.Func)
+	if !ok {
+		return nil, errors.Errorf("invalid blockaddress function type; expected *ir.Func, got %T", c.Func)
+	}
+	index, ok := blockIndices(f)[c.Block.Name()]
+	if !ok {
+		return nil, errors.Errorf("unable to locate basic block %q in function %q", c.Block.Name(), f.Name())
+	}
+	return d.commented(intLit(int64(index)), c.Ident()), nil
+}
+
+// floatLit converts the given LLVM IR floating-point constant into a
+// corresponding Go expression.
+//
+// Finite values are emitted as floating-point literals, using the shortest
+// decimal representation which uniquely identifies the value. Go constants are
+// exact and have no notion of negative zero, infinity or NaN; thus these values
+// are emitted as calls to the math package.
+//
+// Floating-point constants with a precision larger than double precision are
+// rounded to the nearest float64, as that is the Go type used to represent
+// them.
+func (d *Decompiler) floatLit(c *constant.Float) (ast.Expr, error) {
+	if c.NaN {
+		return d.mathCall(c.Typ, "NaN"), nil
+	}
+	x, _ := c.X.Float64()
+	switch {
+	case math.IsInf(x, 1):
+		return d.mathCall(c.Typ, "Inf", intLit(1)), nil
+	case math.IsInf(x, -1):
+		return d.mathCall(c.Typ, "Inf", intLit(-1)), nil
+	case x == 0 && c.X.Signbit():
+		return d.mathCall(c.Typ, "Copysign", intLit(0), intLit(-1)), nil
+	}
+	goType, ok := d.GoType(c.Typ).(*ast.Ident)
+	if !ok {
+		if err := d.unsupported("floating-point constant of type", c.Typ); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return unsupportedExpr(), nil
+	}
+	bitSize := 64
+	if goType.Name == "float32" {
+		bitSize = 32
+	}
+	s := strconv.FormatFloat(x, 'g', -1, bitSize)
+	// Ensure that the literal is not interpreted as an untyped integer constant.
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return &ast.BasicLit{Kind: token.FLOAT, Value: s}, nil
+}
+
+// array converts the given LLVM IR array constant into a corresponding Go
+// expression.
+func (d *Decompiler) array(c *constant.Array) (ast.Expr, error) {
+	if buf, ok := charArray(c); ok {
+		return byteLit(buf), nil
+	}
+	return d.aggregate(c.Typ, c.Elems)
+}
+
+// aggregate returns a Go composite literal of the given LLVM IR aggregate type,
+// recursively converting the given elements.
+func (d *Decompiler) aggregate(typ types.Type, elems []constant.Constant) (ast.Expr, error) {
+	lit := &ast.CompositeLit{
+		Type: d.GoType(typ),
+	}
+	for _, elem := range elems {
+		expr, err := d.Value(elem)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		lit.Elts = append(lit.Elts, expr)
+	}
+	return lit, nil
+}
+
+// charArray returns the bytes of the given character array constant; i.e. an
+// array of i8 constants, as used for C strings. The boolean return value
+// indicates success.
+func charArray(c constant.Constant) ([]byte, bool) {
+	switch c := c.(type) {
+	case *constant.CharArray:
+		return c.X, true
+	case *constant.Array:
+		return int8Array(c)
+	default:
+		return nil, false
+	}
+}
+
+// int8Array returns the bytes of the given array constant of i8 constants. The
+// boolean return value indicates success.
+func int8Array(c *constant.Array) ([]byte, bool) {
+	if elem, ok := c.Typ.ElemType.(*types.IntType); !ok || elem.BitSize != 8 {
+		return nil, false
+	}
+	buf := make([]byte, 0, len(c.Elems))
+	for _, elem := range c.Elems {
+		x, ok := elem.(*constant.Int)
+		if !ok {
+			return nil, false
+		}
+		buf = append(buf, byte(x.X.Int64()))
+	}
+	return buf, true
+}
+
+// byteLit returns a Go literal of the given character array contents.
+//
+// The contents are emitted as a string literal, with the trailing NUL byte of C
+// strings dropped, if the remaining contents are valid UTF-8 consisting of
+// printable characters, whitespace or embedded NUL bytes (which are escaped).
+// Otherwise, the contents are emitted as a []byte composite literal.
+func byteLit(buf []byte) ast.Expr {
+	s := string(buf)
+	if strings.HasSuffix(s, "\x00") {
+		s = s[:len(s)-1]
+	}
+	if isText(s) {
+		return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(s)}
+	}
+	lit := &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: ast.NewIdent("byte")},
+	}
+	for _, b := range buf {
+		elem := &ast.BasicLit{Kind: token.INT, Value: fmt.Sprintf("0x%02X", b)}
+		lit.Elts = append(lit.Elts, elem)
+	}
+	return lit
+}
+
+// isText reports whether the given string is valid UTF-8 consisting only of
+// printable characters, whitespace and NUL bytes.
+func isText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		switch r {
+		case '\x00', '\a', '\b', '\f', '\n', '\r', '\t', '\v':
+			continue
+		}
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// zeroValue returns the zero value of the given Go type, which corresponds to
+// the given LLVM IR type.
+//
+// The zero value policy is as follows; typed nil for pointers (e.g.
+// (*int32)(nil)), false for booleans
+// (i1), 0 for integers, 0.0 for floating-point values and an empty composite
+// literal for aggregates (arrays, vectors and structs). Named types follow the
+// policy of their underlying type, using the Go type of the named type (e.g.
+// point{}).
+func (d *Decompiler) zeroValue(typ ast.Expr, llType types.Type) (ast.Expr, error) {
+	switch llType := llType.(type) {
+	case *types.IntType:
+		if llType.BitSize == 1 {
+			return ast.NewIdent("false"), nil
+		}
+		return intLit(0), nil
+	case *types.FloatType:
+		return &ast.BasicLit{Kind: token.FLOAT, Value: "0.0"}, nil
+	case *types.PointerType:
+		return typedNil(typ), nil
+	case *types.ArrayType, *types.VectorType, *types.StructType:
+		return &ast.CompositeLit{Type: typ}, nil
+	default:
+		if err := d.unsupported("zero value of type", llType); err != nil {
+			return nil, err
+		}
+		return unsupportedExpr(), nil
+	}
+}
+
+// typedValue converts the given LLVM IR value into a corresponding Go
+// expression, for contexts where the type of the value is not inferred by the
+// Go type checker (e.g. return values, stored values and call arguments). Null
+// pointers are converted into typed nil values; e.g.
+//
+//    (*int32)(nil) // i32* null
+func (d *Decompiler) typedValue(v value.Value) (ast.Expr, error) {
+	if c, ok := v.(*constant.Null); ok {
+		return typedNil(d.GoType(c.Typ)), nil
+	}
+	return d.Value(v)
+}
+
+// typedNil returns the nil value of the given Go pointer type.
+func typedNil(typ ast.Expr) ast.Expr {
+	switch typ.(type) {
+	case *ast.StarExpr, *ast.FuncType:
+		// Parenthesize the type to disambiguate the conversion from a pointer
+		// dereference; e.g. (*T)(nil) rather than *T(nil).
+		typ = &ast.ParenExpr{X: typ}
+	}
+	return &ast.CallExpr{Fun: typ, Args: []ast.Expr{ast.NewIdent("nil")}}
+}
+
+// undef returns the Go expression of the given undefined value; i.e. the zero
+// value of its Go type, annotated with a comment to mark that the source value
+// was undefined.
+func (d *Decompiler) undef(c *constant.Undef) (ast.Expr, error) {
+	zero, err := d.zeroValue(d.GoType(c.Typ), c.Typ)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.commented(zero, "undef"), nil
+}
+
+// mathCall returns a call to the given function of the math package, converted
+// to the Go type of the given floating-point type if needed.
+func (d *Decompiler) mathCall(typ *types.FloatType, funcName string, args ...ast.Expr) ast.Expr {
+	var expr ast.Expr = &ast.CallExpr{
+		Fun:  d.mathSel(funcName),
+		Args: args,
+	}
+	goType := d.GoType(typ)
+	if ident, ok := goType.(*ast.Ident); !ok || ident.Name != "float64" {
+		expr = &ast.CallExpr{
+			Fun:  goType,
+			Args: []ast.Expr{expr},
+		}
+	}
+	return expr
+}
+
+// mathSel returns the selector expression of the given identifier of the math
+// package.
+func (d *Decompiler) mathSel(name string) ast.Expr {
+	return d.pkgSel("math", name)
+}
+
+// intConst returns the Go integer literal of the given LLVM IR integer
+// constant. As the i1 type maps to bool, i1 constants map to true and false.
+//
+// Go has no integer types larger than 64 bits, and larger LLVM IR integer types
+// map to the 64-bit Go integer type (see intType). Constants exceeding the
+// range of their Go type are thus truncated to the size of the Go type, and
+// annotated with a comment of the original value; e.g.
+//
+//    -1 /* i128 340282366920938463463374607431768211455 */
+func (d *Decompiler) intConst(c *constant.Int) ast.Expr {
+	if c.Typ.BitSize == 1 {
+		if c.X.Sign() != 0 {
+			return ast.NewIdent("true")
+		}
+		return ast.NewIdent("false")
+	}
+	lit := &ast.BasicLit{Kind: token.INT, Value: c.X.String()}
+	size := goIntSize(c.Typ.BitSize)
+	// Fast path; constants of less than size bits are within range.
+	if uint64(c.X.BitLen()) < size {
+		return lit
+	}
+	lo := new(big.Int).Lsh(big.NewInt(-1), uint(size-1))
+	hi := new(big.Int).Lsh(big.NewInt(1), uint(size-1))
+	if c.X.Cmp(lo) >= 0 && c.X.Cmp(hi) < 0 {
+		return lit
+	}
+	// Two's complement truncation to the size of the Go type.
+	mod := new(big.Int).Lsh(hi, 1)
+	x := new(big.Int).Mod(c.X, mod)
+	if x.Cmp(hi) >= 0 {
+		x.Sub(x, mod)
+	}
+	return d.commented(&ast.BasicLit{Kind: token.INT, Value: x.String()}, fmt.Sprintf("%v %v", c.Typ, c.X))
+}
+
+// intLit returns a Go integer literal of the given value.
+func intLit(x int64) ast.Expr {
+	if x < 0 {
+		return &ast.UnaryExpr{
+			Op: token.SUB,
+			X:  intLit(-x),
+		}
+	}
+	return &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(x, 10)}
+}
+
+// global returns a Go identifier for the given global name, as specified by
+// the symbol map of the decompiler, if any.
+func (d *Decompiler) global(name string) *ast.Ident {
+	if d.Names != nil {
+		if ident, ok := d.Names.Globals[name]; ok {
+			return ast.NewIdent(unreserved(Sanitize(ident)))
+		}
+	}
+	if name == "main" && d.Runnable {
+		return ast.NewIdent(mainName)
+	}
+	// Mangled C++ names are disambiguated within the module being decompiled,
+	// if tracked.
+	if d.Demangle {
+		ident, ok := d.demangled[name]
+		if d.demangled == nil {
+			ident, ok = demangleIdent(name)
+		}
+		if ok {
+			return ast.NewIdent(ident)
+		}
+	}
+	return newIdent(name)
+}
+
+// local returns a Go identifier for the given local name, which is unique
+// within the function being decompiled (see nameAllocator), and based on the
+// symbol map of the decompiler, if any.
+func (d *Decompiler) local(name string) *ast.Ident {
+	if d.names == nil {
+		return newIdent(name)
+	}
+	return ast.NewIdent(d.names.ident(name))
+}
+
+// newIdent returns a new identifier based on the given string after replacing
+// any illegal characters, including dots, with underscore (e.g. "i.0" => "i_0"
+// and "str.1" => "str_1"), so that distinct names map to distinct identifiers.
+// Unnamed values (e.g. "%3") and reserved identifiers (e.g. "%len"; see
+// isReserved) are prefixed with an underscore (e.g. "_3" and "_len").
+func newIdent(s string) *ast.Ident {
+	return ast.NewIdent(unreserved(Sanitize(s)))
+}
+
+// Sanitize returns a valid Go identifier based on the given string after
+// replacing any illegal characters with underscore, and prefixing the
+// identifier with an underscore if empty, starting with a digit or a Go keyword
+// (e.g. "_select" for "select").
+func Sanitize(s string) string {
+	f := func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r):
+			// valid rune in identifier.
+			return r
+		}
+		return '_'
+	}
+	s = strings.Map(f, s)
+	if len(s) == 0 || unicode.IsNumber(rune(s[0])) || token.Lookup(s).IsKeyword() {
+		s = "_" + s
+	}
+	return s
+}
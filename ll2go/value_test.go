@@ -0,0 +1,469 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestValueInt(t *testing.T) {
+	i128 := types.NewInt(128)
+	// 2^64 + 5
+	large, _ := new(big.Int).SetString("18446744073709551621", 10)
+	// 2^128 - 1
+	allOnes := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	golden := []struct {
+		in   *constant.Int
+		want string
+	}{
+		{in: constant.NewInt(types.I32, 42), want: "42"},
+		{in: constant.NewInt(types.I64, -7), want: "-7"},
+		// i1 constants map to bool.
+		{in: constant.NewInt(types.I1, 1), want: "true"},
+		{in: constant.NewInt(types.I1, 0), want: "false"},
+		{in: constant.True, want: "true"},
+		{in: constant.False, want: "false"},
+		{in: constant.NewInt(types.I1, -1), want: "true"},
+		// i128 constants within the range of int64.
+		{in: constant.NewInt(i128, -5), want: "-5"},
+		// i128 constants exceeding the range of int64.
+		{in: &constant.Int{Typ: i128, X: large}, want: "5 /* i128 18446744073709551621 */"},
+		{in: &constant.Int{Typ: i128, X: allOnes}, want: "-1 /* i128 340282366920938463463374607431768211455 */"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, g.in)
+		if got != g.want {
+			t.Errorf("%v: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+	// The truncated i128 constant type-checks as its Go type.
+	typeCheck(t, "var x int64 = "+valueString(t, NewDecompiler(), &constant.Int{Typ: i128, X: allOnes}))
+	// i1 constants type-check as bool.
+	typeCheck(t, "var x bool = "+valueString(t, NewDecompiler(), constant.True))
+}
+
+func TestValueFloat(t *testing.T) {
+	golden := []struct {
+		in   *constant.Float
+		want string
+	}{
+		{in: constant.NewFloat(types.Double, 1), want: "1.0"},
+		{in: constant.NewFloat(types.Double, -2.5), want: "-2.5"},
+		{in: constant.NewFloat(types.Float, 0.1), want: "0.1"},
+		{in: constant.NewFloat(types.Double, 1e300), want: "1e+300"},
+		{in: constant.NewFloat(types.Double, 5e-324), want: "5e-324"},
+		{in: constant.NewFloat(types.Float, math.MaxFloat32), want: "3.4028235e+38"},
+		{in: constant.NewFloat(types.Double, 0), want: "0.0"},
+		{in: constant.NewFloat(types.Double, math.Copysign(0, -1)), want: "math.Copysign(0, -1)"},
+		{in: constant.NewFloat(types.Double, math.Inf(1)), want: "math.Inf(1)"},
+		{in: constant.NewFloat(types.Float, math.Inf(-1)), want: "float32(math.Inf(-1))"},
+		{in: &constant.Float{Typ: types.Double, X: new(big.Float), NaN: true}, want: "math.NaN()"},
+		{in: &constant.Float{Typ: types.Float, X: new(big.Float), NaN: true}, want: "float32(math.NaN())"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, g.in)
+		if got != g.want {
+			t.Errorf("%v: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestValueZero(t *testing.T) {
+	golden := []struct {
+		in   constant.Constant
+		want string
+	}{
+		// Null pointer.
+		{in: constant.NewNull(types.NewPointer(types.Double)), want: "nil"},
+		// Zero pointer.
+		{in: constant.NewZeroInitializer(types.NewPointer(types.Double)), want: "(*float64)(nil)"},
+		// Zero floating-point value.
+		{in: constant.NewZeroInitializer(types.Double), want: "0.0"},
+		// Zero struct.
+		{in: constant.NewZeroInitializer(types.NewStruct(types.Double, types.Float)), want: "struct {\n\tField0\tfloat64\n\tField1\tfloat32\n}{}"},
+		// Zero array.
+		{in: constant.NewZeroInitializer(types.NewArray(4, types.Double)), want: "[4]float64{}"},
+		// Undefined values.
+		{in: constant.NewUndef(types.NewPointer(types.I32)), want: "(*int32)(nil) /* undef */"},
+		{in: constant.NewUndef(types.I1), want: "false /* undef */"},
+		{in: constant.NewUndef(types.I32), want: "0 /* undef */"},
+		{in: constant.NewUndef(types.Float), want: "0.0 /* undef */"},
+		{in: constant.NewUndef(types.NewArray(2, types.I8)), want: "[2]int8{} /* undef */"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, g.in)
+		if got != g.want {
+			t.Errorf("%v: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestValueCharArray(t *testing.T) {
+	golden := []struct {
+		in   []byte
+		want string
+	}{
+		// C string.
+		{in: []byte("hello\x00"), want: `"hello"`},
+		// Escape sequences.
+		{in: []byte("a\tb\n\"c\"\\\x00"), want: `"a\tb\n\"c\"\\"`},
+		// Embedded NUL bytes.
+		{in: []byte("foo\x00bar\x00"), want: `"foo\x00bar"`},
+		// Non-NUL terminated.
+		{in: []byte("abc"), want: `"abc"`},
+		// Empty string.
+		{in: []byte("\x00"), want: `""`},
+		// Non-printable contents.
+		{in: []byte{0x01, 0xFF, 0x00}, want: "[]byte{0x01, 0xFF, 0x00}"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, constant.NewCharArray(g.in))
+		if got != g.want {
+			t.Errorf("%q: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestValueAggregate(t *testing.T) {
+	i32 := func(x int64) constant.Constant {
+		return constant.NewInt(types.I32, x)
+	}
+	golden := []struct {
+		in   constant.Constant
+		want string
+	}{
+		// Flat int array.
+		{in: constant.NewArray(types.NewArray(3, types.I32), i32(1), i32(2), i32(3)), want: "[3]int32{1, 2, 3}"},
+		// Mixed-type struct.
+		{
+			in:   constant.NewStruct(types.NewStruct(types.I32, types.Double), i32(1), constant.NewFloat(types.Double, 2.5)),
+			want: "struct {\n\tField0\tint32\n\tField1\tfloat64\n}{1, 2.5}",
+		},
+		// Struct containing an array containing a struct.
+		{
+			in: constant.NewStruct(types.NewStruct(types.I32, types.NewArray(2, types.NewStruct(types.I32))),
+				i32(1),
+				constant.NewArray(types.NewArray(2, types.NewStruct(types.I32)),
+					constant.NewStruct(types.NewStruct(types.I32), i32(2)),
+					constant.NewStruct(types.NewStruct(types.I32), i32(3)),
+				),
+			),
+			want: "struct {\n\tField0\tint32\n\tField1\t[2]struct {\n\t\tField0 int32\n\t}\n}{1, [2]struct {\n\tField0 int32\n}{struct {\n\tField0 int32\n}{2}, struct {\n\tField0 int32\n}{3}}}",
+		},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, g.in)
+		if got != g.want {
+			t.Errorf("%v: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestValueBlockAddress(t *testing.T) {
+	// Distinct basic blocks of a function have distinct addresses.
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	foo := f.NewBlock("foo")
+	bar := f.NewBlock("bar")
+	entry.NewBr(foo)
+	foo.NewBr(bar)
+	bar.NewRet(nil)
+	d := NewDecompiler()
+	fooAddr := valueString(t, d, constant.NewBlockAddress(f, foo))
+	barAddr := valueString(t, d, constant.NewBlockAddress(f, bar))
+	if want := "1 /* blockaddress(@f, %foo) */"; fooAddr != want {
+		t.Errorf("block address mismatch; expected %q, got %q", want, fooAddr)
+	}
+	if want := "2 /* blockaddress(@f, %bar) */"; barAddr != want {
+		t.Errorf("block address mismatch; expected %q, got %q", want, barAddr)
+	}
+}
+
+func TestValueConstExpr(t *testing.T) {
+	i32 := func(x int64) constant.Constant {
+		return constant.NewInt(types.I32, x)
+	}
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewStruct(types.NewStruct(types.I32, types.I32), i32(1), i32(2)))
+	x := m.NewGlobalDef("x", i32(3))
+	golden := []struct {
+		in   constant.Constant
+		want string
+	}{
+		// Bitcast constant expression.
+		{in: constant.NewBitCast(x, types.NewPointer(types.I8)), want: "(*int8)(unsafe.Pointer(&x)) /* unsafe */"},
+		// GEP constant expression into a global.
+		{in: constant.NewGetElementPtr(elemType(g), g, i32(0), i32(1)), want: "&g.Field1"},
+		// Nested arithmetic constant expressions.
+		{in: constant.NewMul(constant.NewAdd(i32(1), i32(2)), i32(3)), want: "(1 + 2) * 3"},
+		{in: constant.NewUDiv(i32(-8), i32(2)), want: "int32(uint32(4294967288) / uint32(2))"},
+	}
+	for _, g := range golden {
+		d := NewDecompiler()
+		got := valueString(t, d, g.in)
+		if got != g.want {
+			t.Errorf("%v: expression mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestValueConstExprArg(t *testing.T) {
+	// A GEP constant expression used as a function argument.
+	//
+	//    struct { int a, b; } g = {1, 2};
+	//
+	//    void f(void) {
+	//       use(&g.b);
+	//    }
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewStruct(types.NewStruct(types.I32, types.I32), constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2)))
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	use := m.NewFunc("use", types.Void, p)
+	use.NewBlock("entry").NewRet(nil)
+	f := m.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	entry.NewCall(use, constant.NewGetElementPtr(elemType(g), g, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1)))
+	entry.NewRet(nil)
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f() {
+	use(&g.Field1)
+	return
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	if err := Verify("constexpr.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
+
+func TestLocalNames(t *testing.T) {
+	// Local names which are Go keywords, or which collide after sanitization.
+	//
+	//    int f(int range, int a.b) {
+	//       int a_b = range + a.b;
+	//       return a_b + a.b;
+	//    }
+	m := ir.NewModule()
+	r := ir.NewParam("range", types.I32)
+	ab := ir.NewParam("a.b", types.I32)
+	f := m.NewFunc("f", types.I32, r, ab)
+	entry := f.NewBlock("entry")
+	sum := entry.NewAdd(r, ab)
+	sum.SetName("a_b")
+	x := entry.NewAdd(sum, ab)
+	x.SetName("x")
+	entry.NewRet(x)
+	d := NewDecompiler()
+	fn, err := d.FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(_range int32, a_b int32) int32 {
+	a_b_1 := _range + a_b
+	x := a_b_1 + a_b
+	return x
+}`
+	if got := nodeString(t, fn); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	if err := Verify("names.go", file); err != nil {
+		t.Errorf("unable to type-check Go source file; %v", err)
+	}
+}
+
+func TestLocalNameVersions(t *testing.T) {
+	// Local names which only differ by numeric suffix are distinct SSA values,
+	// and are given distinct Go identifiers in order of definition.
+	//
+	//    define i32 @f(i32 %i) {
+	//    entry:
+	//       %i.1 = add i32 %i, 1
+	//       %i.0 = mul i32 %i.1, %i
+	//       %x = sub i32 %i.0, %i.1
+	//       ret i32 %x
+	//    }
+	m := ir.NewModule()
+	i := ir.NewParam("i", types.I32)
+	f := m.NewFunc("f", types.I32, i)
+	entry := f.NewBlock("entry")
+	i1 := entry.NewAdd(i, constant.NewInt(types.I32, 1))
+	i1.SetName("i.1")
+	i0 := entry.NewMul(i1, i)
+	i0.SetName("i.0")
+	x := entry.NewSub(i0, i1)
+	x.SetName("x")
+	entry.NewRet(x)
+	fn, err := NewDecompiler().FuncDecl(f, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile function; %v", err)
+	}
+	want := `func f(i int32) int32 {
+	i_1 := i + 1
+	i_2 := i_1 * i
+	x := i_2 - i_1
+	return x
+}`
+	got := nodeString(t, fn)
+	if got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+	typeCheck(t, got)
+}
+
+func TestSymbolMap(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g_42", constant.NewInt(types.I32, 0))
+	n := ir.NewParam("", types.I32)
+	f := m.NewFunc("func_1234", types.I32, n)
+	entry := f.NewBlock("entry")
+	x := entry.NewLoad(elemType(g), g)
+	sum := entry.NewAdd(x, n)
+	entry.NewRet(sum)
+	// Locals of other functions are not renamed.
+	h := m.NewFunc("h", types.I32, ir.NewParam("", types.I32))
+	h.NewBlock("entry").NewRet(h.Params[0])
+	d := NewDecompiler()
+	d.Names = &SymbolMap{
+		Globals: map[string]string{"g_42": "total", "func_1234": "ParseHeader"},
+		Locals: map[string]map[string]string{
+			"func_1234": {"0": "size", "2": "sum"},
+		},
+	}
+	file, err := d.Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	var got []string
+	for _, decl := range file.Decls {
+		got = append(got, nodeString(t, decl))
+	}
+	want := []string{
+		"var total int32 = 0",
+		"func ParseHeader(size int32) int32 {\n\t_1 := total\n\tsum := _1 + size\n\treturn sum\n}",
+		"func h(_0 int32) int32 {\n\treturn _0\n}",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("number of declarations mismatch; expected %d, got %d (%q)", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("declaration mismatch; expected %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestValueUnsupported(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewGlobalDef("g", constant.NewInt(types.I32, 0))
+	// Supported function.
+	f := m.NewFunc("f", types.I32)
+	f.NewBlock("entry").NewRet(constant.NewInt(types.I32, 1))
+	// Function with an unsupported constant expression.
+	h := m.NewFunc("h", types.I32)
+	entry := h.NewBlock("entry")
+	entry.NewRet(entry.NewLoad(types.I32, constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))))
+
+	d := NewDecompiler()
+	const want = "support for constant expression *constant.ExprAddrSpaceCast not yet implemented"
+	if _, err := d.Value(constant.NewAddrSpaceCast(g, types.NewPointer(types.I32))); err == nil || err.Error() != want {
+		t.Errorf("value error mismatch; expected %q, got %v", want, err)
+	}
+	if _, err := d.FuncDecl(f, nil); err != nil {
+		t.Errorf("%q: unable to decompile function; %v", f.Name(), err)
+	}
+	if _, err := d.FuncDecl(h, nil); err == nil || err.Error() != want {
+		t.Errorf("%q: function error mismatch; expected %q, got %v", h.Name(), want, err)
+	}
+	if _, err := Decompile(m, nil); err == nil {
+		t.Errorf("expected error for module with unsupported constant expression")
+	}
+}
+
+// nodeString returns the Go source code representation of the given node.
+func nodeString(t *testing.T, node ast.Node) string {
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, token.NewFileSet(), node); err != nil {
+		t.Fatalf("unable to print node; %v", err)
+	}
+	return buf.String()
+}
+
+// withComments returns the given decompiler, tracking the comments of the Go
+// nodes it decompiles.
+func withComments(d *Decompiler) *Decompiler {
+	if d.comments == nil {
+		d.comments = newCommentSet()
+	}
+	return d
+}
+
+// commentString returns the Go source code representation of the given node,
+// including the comments tracked by the given decompiler.
+func commentString(t *testing.T, d *Decompiler, node ast.Node) string {
+	l := newLayout(withComments(d).comments)
+	switch n := node.(type) {
+	case ast.Expr:
+		node = l.expr(n)
+	case ast.Stmt:
+		node = l.stmt(n, false)
+	case ast.Decl:
+		node = l.decl(n)
+	}
+	comments := l.resolve()
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, FileSet(), &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+		t.Fatalf("unable to print node; %v", err)
+	}
+	// go/printer omits the comments following the node.
+	for _, g := range comments {
+		if g.Pos() >= node.End() {
+			for _, c := range g.List {
+				buf.WriteString(" " + c.Text)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// valueString returns the Go source code representation of the given value,
+// including comments.
+func valueString(t *testing.T, d *Decompiler, v value.Value) string {
+	expr, err := withComments(d).Value(v)
+	if err != nil {
+		t.Fatalf("unable to decompile value %v; %v", v.Ident(), err)
+	}
+	return commentString(t, d, expr)
+}
+
+// elemType returns the element type of the given pointer value.
+func elemType(v value.Value) types.Type {
+	return v.Type().(*types.PointerType).ElemType
+}
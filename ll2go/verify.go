@@ -0,0 +1,39 @@
+package ll2go
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+
+	"github.com/pkg/errors"
+)
+
+// Verify type-checks the given decompiled Go source file, and returns the first
+// type error encountered, if any; e.g. mismatched return values or missing
+// conversions.
+//
+// Decompiled Go source files have no file names to position errors. Thus the
+// Go source file is formatted as by gofmt and re-parsed using the given file
+// name, so that the position of the type error refers to the formatted Go
+// source code. The imports of the Go source file are resolved from the
+// installed packages.
+func Verify(fileName string, file *ast.File) error {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, FileSet(), file); err != nil {
+		return errors.WithStack(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fileName, buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return errors.Wrapf(err, "invalid Go syntax in %q", fileName)
+	}
+	conf := &gotypes.Config{Importer: importer.Default()}
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		return errors.Wrapf(err, "type error in %q", fileName)
+	}
+	return nil
+}
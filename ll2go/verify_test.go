@@ -0,0 +1,43 @@
+package ll2go
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestVerify(t *testing.T) {
+	m := ir.NewModule()
+	x := ir.NewParam("x", types.I32)
+	f := m.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	sum := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+	entry.NewRet(sum)
+	file, err := Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	if err := Verify("foo.go", file); err != nil {
+		t.Errorf("unexpected type error; %v", err)
+	}
+
+	// Return value of mismatched type.
+	g := m.NewFunc("g", types.I64, x)
+	g.NewBlock("entry").NewRet(x)
+	file, err = Decompile(m, nil)
+	if err != nil {
+		t.Fatalf("unable to decompile module; %v", err)
+	}
+	err = Verify("foo.go", file)
+	if err == nil {
+		t.Fatalf("expected type error, got nil")
+	}
+	// The type error refers to the return statement of g.
+	const want = "foo.go:8:9"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("type error position mismatch; expected %q in %q", want, err)
+	}
+}